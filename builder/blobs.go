@@ -0,0 +1,84 @@
+package builder
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+type blob struct {
+	ObjectID string `yaml:"object_id"`
+	SHA      string `yaml:"sha"`
+	Size     int64  `yaml:"size"`
+}
+
+// SyncBlobs fetches every blob listed in config/blobs.yml that is missing from
+// the blobs directory of releaseDir, verifying each against its sha1.
+func SyncBlobs(releaseDir string, blobstore Blobstore) error {
+	blobs := map[string]blob{}
+	err := readYAML(filepath.Join(releaseDir, "config", "blobs.yml"), &blobs)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for path := range blobs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		blobPath := filepath.Join(releaseDir, "blobs", path)
+
+		digest, err := fileSHA1(blobPath)
+		if err == nil && digest == blobs[path].SHA {
+			continue
+		}
+
+		err = fetchBlob(blobstore, blobs[path], blobPath)
+		if err != nil {
+			return fmt.Errorf("could not fetch blob %s: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+func fetchBlob(blobstore Blobstore, b blob, path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), ".blob")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	hash := sha1.New()
+	err = blobstore.Fetch(b.ObjectID, io.MultiWriter(tempFile, hash))
+	if err != nil {
+		return err
+	}
+
+	digest := fmt.Sprintf("%x", hash.Sum(nil))
+	if digest != b.SHA {
+		return fmt.Errorf("expected sha1 %s but got %s", b.SHA, digest)
+	}
+
+	err = tempFile.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), path)
+}
@@ -0,0 +1,109 @@
+package builder_test
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/builder"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SyncBlobs", func() {
+	var (
+		releaseDir    string
+		blobstorePath string
+		blobstore     builder.Blobstore
+	)
+
+	BeforeEach(func() {
+		var err error
+		releaseDir, err = ioutil.TempDir("", "sync-blobs")
+		Expect(err).NotTo(HaveOccurred())
+
+		blobstorePath, err = ioutil.TempDir("", "local-blobstore")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.Mkdir(filepath.Join(releaseDir, "config"), 0755)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(blobstorePath, "some-object-id"), []byte("some-blob"), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(releaseDir, "config/blobs.yml"), []byte(fmt.Sprintf(`---
+some-package/some-blob.tgz:
+  size: 9
+  object_id: some-object-id
+  sha: %x
+`, sha1.Sum([]byte("some-blob")))), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		blobstore = builder.NewLocalBlobstore(blobstorePath)
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.RemoveAll(blobstorePath)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("fetches missing blobs into the blobs directory", func() {
+		err := builder.SyncBlobs(releaseDir, blobstore)
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := ioutil.ReadFile(filepath.Join(releaseDir, "blobs/some-package/some-blob.tgz"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("some-blob"))
+	})
+
+	It("keeps blobs that are already present", func() {
+		err := os.Remove(filepath.Join(blobstorePath, "some-object-id"))
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.MkdirAll(filepath.Join(releaseDir, "blobs/some-package"), 0755)
+		Expect(err).NotTo(HaveOccurred())
+		err = ioutil.WriteFile(filepath.Join(releaseDir, "blobs/some-package/some-blob.tgz"), []byte("some-blob"), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = builder.SyncBlobs(releaseDir, blobstore)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("does nothing when there is no config/blobs.yml", func() {
+		err := os.Remove(filepath.Join(releaseDir, "config/blobs.yml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		err = builder.SyncBlobs(releaseDir, blobstore)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("failure cases", func() {
+		Context("when a fetched blob does not match its sha1", func() {
+			It("returns an error and does not keep the blob", func() {
+				err := ioutil.WriteFile(filepath.Join(blobstorePath, "some-object-id"), []byte("tampered"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = builder.SyncBlobs(releaseDir, blobstore)
+				Expect(err).To(MatchError(fmt.Sprintf("could not fetch blob some-package/some-blob.tgz: expected sha1 %x but got %x",
+					sha1.Sum([]byte("some-blob")), sha1.Sum([]byte("tampered")))))
+				Expect(filepath.Join(releaseDir, "blobs/some-package/some-blob.tgz")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when a blob is missing from the blobstore", func() {
+			It("returns an error", func() {
+				err := os.Remove(filepath.Join(blobstorePath, "some-object-id"))
+				Expect(err).NotTo(HaveOccurred())
+
+				err = builder.SyncBlobs(releaseDir, blobstore)
+				Expect(err).To(MatchError(ContainSubstring("could not fetch blob some-package/some-blob.tgz")))
+			})
+		})
+	})
+})
@@ -0,0 +1,209 @@
+package builder
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type Blobstore interface {
+	Fetch(objectID string, destination io.Writer) error
+}
+
+type blobstoreConfig struct {
+	Blobstore struct {
+		Provider string                 `yaml:"provider"`
+		Options  map[string]interface{} `yaml:"options"`
+	} `yaml:"blobstore"`
+}
+
+type LocalBlobstore struct {
+	path string
+}
+
+type S3Blobstore struct {
+	bucketName      string
+	host            string
+	region          string
+	useSSL          bool
+	port            int
+	accessKeyID     string
+	secretAccessKey string
+}
+
+// NewBlobstore returns the blobstore configured in config/final.yml of
+// releaseDir, with any credentials merged in from privateYML, which has the
+// same layout as config/private.yml.
+func NewBlobstore(releaseDir, privateYML string) (Blobstore, error) {
+	var config blobstoreConfig
+	err := readYAML(filepath.Join(releaseDir, "config", "final.yml"), &config)
+	if err != nil {
+		return nil, err
+	}
+
+	var private blobstoreConfig
+	err = yaml.Unmarshal([]byte(privateYML), &private)
+	if err != nil {
+		return nil, err
+	}
+
+	options := map[string]interface{}{}
+	for key, value := range config.Blobstore.Options {
+		options[key] = value
+	}
+	for key, value := range private.Blobstore.Options {
+		options[key] = value
+	}
+
+	switch config.Blobstore.Provider {
+	case "local":
+		path := stringOption(options, "blobstore_path", "")
+		if path == "" {
+			return nil, fmt.Errorf("local blobstore is missing the blobstore_path option")
+		}
+
+		return NewLocalBlobstore(path), nil
+	case "s3":
+		bucketName := stringOption(options, "bucket_name", "")
+		if bucketName == "" {
+			return nil, fmt.Errorf("s3 blobstore is missing the bucket_name option")
+		}
+
+		port, err := strconv.Atoi(stringOption(options, "port", "0"))
+		if err != nil {
+			return nil, fmt.Errorf("s3 blobstore has an invalid port: %s", err)
+		}
+
+		return S3Blobstore{
+			bucketName:      bucketName,
+			host:            stringOption(options, "host", "s3.amazonaws.com"),
+			region:          stringOption(options, "region", "us-east-1"),
+			useSSL:          stringOption(options, "use_ssl", "true") != "false",
+			port:            port,
+			accessKeyID:     stringOption(options, "access_key_id", ""),
+			secretAccessKey: stringOption(options, "secret_access_key", ""),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported blobstore provider %q", config.Blobstore.Provider)
+	}
+}
+
+func stringOption(options map[string]interface{}, key, defaultValue string) string {
+	value, ok := options[key]
+	if !ok || value == nil {
+		return defaultValue
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+func NewLocalBlobstore(path string) LocalBlobstore {
+	return LocalBlobstore{
+		path: path,
+	}
+}
+
+func (b LocalBlobstore) Fetch(objectID string, destination io.Writer) error {
+	fd, err := os.Open(filepath.Join(b.path, objectID))
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	_, err = io.Copy(destination, fd)
+	return err
+}
+
+// Fetch downloads an object with a path-style request, so that S3-compatible
+// stores work as well as S3 itself. Requests are signed with AWS signature
+// version 4 when an access key is configured, and anonymous otherwise.
+func (b S3Blobstore) Fetch(objectID string, destination io.Writer) error {
+	scheme := "https"
+	if !b.useSSL {
+		scheme = "http"
+	}
+
+	host := b.host
+	if b.port != 0 {
+		host = fmt.Sprintf("%s:%d", host, b.port)
+	}
+
+	objectURL := url.URL{
+		Scheme: scheme,
+		Host:   host,
+		Path:   fmt.Sprintf("/%s/%s", b.bucketName, objectID),
+	}
+
+	request, err := http.NewRequest("GET", objectURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if b.accessKeyID != "" {
+		b.sign(request)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response fetching blob %s: %s", objectID, response.Status)
+	}
+
+	_, err = io.Copy(destination, response.Body)
+	return err
+}
+
+func (b S3Blobstore) sign(request *http.Request) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, b.region)
+
+	request.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	request.Header.Set("X-Amz-Date", amzDate)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		fmt.Sprintf("host:%s\nx-amz-content-sha256:UNSIGNED-PAYLOAD\nx-amz-date:%s\n", request.URL.Host, amzDate),
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		fmt.Sprintf("%x", sha256.Sum256([]byte(canonicalRequest))),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+b.secretAccessKey), date)
+	key = hmacSHA256(key, b.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%x",
+		b.accessKeyID, scope, signedHeaders, hmacSHA256(key, stringToSign)))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
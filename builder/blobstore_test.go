@@ -0,0 +1,186 @@
+package builder_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/builder"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Blobstore", func() {
+	var releaseDir string
+
+	BeforeEach(func() {
+		var err error
+		releaseDir, err = ioutil.TempDir("", "blobstore")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.Mkdir(filepath.Join(releaseDir, "config"), 0755)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	writeFinalConfig := func(content string) {
+		err := ioutil.WriteFile(filepath.Join(releaseDir, "config/final.yml"), []byte(content), 0644)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	Describe("a local blobstore", func() {
+		var blobstorePath string
+
+		BeforeEach(func() {
+			var err error
+			blobstorePath, err = ioutil.TempDir("", "local-blobstore")
+			Expect(err).NotTo(HaveOccurred())
+
+			err = ioutil.WriteFile(filepath.Join(blobstorePath, "some-object-id"), []byte("some-blob"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			writeFinalConfig(fmt.Sprintf(`---
+blobstore:
+  provider: local
+  options:
+    blobstore_path: %s
+`, blobstorePath))
+		})
+
+		AfterEach(func() {
+			err := os.RemoveAll(blobstorePath)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("fetches objects from the blobstore path", func() {
+			blobstore, err := builder.NewBlobstore(releaseDir, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			var contents bytes.Buffer
+			err = blobstore.Fetch("some-object-id", &contents)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contents.String()).To(Equal("some-blob"))
+		})
+
+		It("returns an error for missing objects", func() {
+			blobstore, err := builder.NewBlobstore(releaseDir, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			err = blobstore.Fetch("missing-object-id", &bytes.Buffer{})
+			Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+		})
+	})
+
+	Describe("an s3 blobstore", func() {
+		var (
+			server        *httptest.Server
+			requests      []*http.Request
+			serverAddress string
+			serverPort    string
+		)
+
+		BeforeEach(func() {
+			requests = nil
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r)
+				if r.URL.Path == "/some-bucket/some-object-id" {
+					w.Write([]byte("some-blob"))
+					return
+				}
+
+				w.WriteHeader(http.StatusNotFound)
+			}))
+
+			var err error
+			serverAddress, serverPort, err = net.SplitHostPort(server.Listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+
+			writeFinalConfig(fmt.Sprintf(`---
+blobstore:
+  provider: s3
+  options:
+    bucket_name: some-bucket
+    host: %s
+    port: %s
+    use_ssl: false
+`, serverAddress, serverPort))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("fetches objects anonymously without credentials", func() {
+			blobstore, err := builder.NewBlobstore(releaseDir, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			var contents bytes.Buffer
+			err = blobstore.Fetch("some-object-id", &contents)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contents.String()).To(Equal("some-blob"))
+
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].Header.Get("Authorization")).To(BeEmpty())
+		})
+
+		It("signs requests with the credentials from the private config", func() {
+			blobstore, err := builder.NewBlobstore(releaseDir, `---
+blobstore:
+  options:
+    access_key_id: some-access-key
+    secret_access_key: some-secret-key
+`)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = blobstore.Fetch("some-object-id", &bytes.Buffer{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].Header.Get("X-Amz-Content-Sha256")).To(Equal("UNSIGNED-PAYLOAD"))
+			Expect(requests[0].Header.Get("X-Amz-Date")).NotTo(BeEmpty())
+			Expect(requests[0].Header.Get("Authorization")).To(MatchRegexp(
+				`^AWS4-HMAC-SHA256 Credential=some-access-key/\d{8}/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$`))
+		})
+
+		It("returns an error when the object cannot be fetched", func() {
+			blobstore, err := builder.NewBlobstore(releaseDir, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			err = blobstore.Fetch("missing-object-id", &bytes.Buffer{})
+			Expect(err).To(MatchError("unexpected response fetching blob missing-object-id: 404 Not Found"))
+		})
+	})
+
+	Context("failure cases", func() {
+		It("returns an error for an unsupported provider", func() {
+			writeFinalConfig("---\nblobstore:\n  provider: gcs\n")
+
+			_, err := builder.NewBlobstore(releaseDir, "")
+			Expect(err).To(MatchError(`unsupported blobstore provider "gcs"`))
+		})
+
+		It("returns an error when the s3 bucket is not configured", func() {
+			writeFinalConfig("---\nblobstore:\n  provider: s3\n")
+
+			_, err := builder.NewBlobstore(releaseDir, "")
+			Expect(err).To(MatchError("s3 blobstore is missing the bucket_name option"))
+		})
+
+		It("returns an error when the private config is not YAML", func() {
+			writeFinalConfig("---\nblobstore:\n  provider: local\n")
+
+			_, err := builder.NewBlobstore(releaseDir, "%%%%%")
+			Expect(err).To(MatchError("yaml: could not find expected directive name"))
+		})
+	})
+})
@@ -0,0 +1,114 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	cliVersionRegex    = regexp.MustCompile(`(?:version|BOSH) (\d+)\.`)
+	v1TarballPathRegex = regexp.MustCompile(`Release tarball \(.*\): (\S+\.tgz)`)
+)
+
+type logger interface {
+	Println(v ...interface{})
+	Printf(format string, v ...interface{})
+}
+
+// CLIReleaseBuilder creates releases by shelling out to whichever bosh CLI is
+// on the PATH, preferring bosh2 over bosh.
+type CLIReleaseBuilder struct {
+	releaseDir string
+	logger     logger
+}
+
+func NewCLIReleaseBuilder(releaseDir string, logger logger) CLIReleaseBuilder {
+	return CLIReleaseBuilder{
+		releaseDir: releaseDir,
+		logger:     logger,
+	}
+}
+
+func (b CLIReleaseBuilder) Build(name, version string) (string, error) {
+	cliPath, majorVersion, err := b.detectCLI()
+	if err != nil {
+		return "", err
+	}
+
+	if majorVersion == "1" {
+		output, err := b.run(cliPath, "create", "release", "--force", "--name", name, "--version", version, "--with-tarball")
+		if err != nil {
+			return "", err
+		}
+
+		matches := v1TarballPathRegex.FindStringSubmatch(output)
+		if matches == nil {
+			return "", fmt.Errorf("could not find the release tarball path in the output of %s", cliPath)
+		}
+
+		return matches[1], nil
+	}
+
+	outputDir := filepath.Join(b.releaseDir, "dev_releases", name)
+	err = os.MkdirAll(outputDir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	tarballPath := filepath.Join(outputDir, fmt.Sprintf("%s-%s.tgz", name, version))
+	_, err = b.run(cliPath, "create-release", "--force", "--name", name, "--version", version, "--tarball="+tarballPath)
+	if err != nil {
+		return "", err
+	}
+
+	return tarballPath, nil
+}
+
+func (b CLIReleaseBuilder) detectCLI() (string, string, error) {
+	for _, name := range []string{"bosh2", "bosh"} {
+		cliPath, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+
+		output, err := exec.Command(cliPath, "--version").CombinedOutput()
+		if err != nil {
+			return "", "", fmt.Errorf("could not determine the version of %s: %s", cliPath, err)
+		}
+
+		matches := cliVersionRegex.FindStringSubmatch(string(output))
+		if matches == nil {
+			return "", "", fmt.Errorf("could not determine the version of %s from %q", cliPath, strings.TrimSpace(string(output)))
+		}
+
+		return cliPath, matches[1], nil
+	}
+
+	return "", "", fmt.Errorf("could not find a bosh CLI on the PATH")
+}
+
+func (b CLIReleaseBuilder) run(cliPath string, args ...string) (string, error) {
+	var output bytes.Buffer
+
+	command := exec.Command(cliPath, args...)
+	command.Dir = b.releaseDir
+	command.Stdout = &output
+	command.Stderr = &output
+
+	err := command.Run()
+	for _, line := range strings.Split(strings.TrimRight(output.String(), "\n"), "\n") {
+		if line != "" {
+			b.logger.Println(line)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s %s failed: %s", filepath.Base(cliPath), args[0], err)
+	}
+
+	return output.String(), nil
+}
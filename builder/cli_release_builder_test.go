@@ -0,0 +1,180 @@
+package builder_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/builder"
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func writeFakeCLI(binDir, name, script string) {
+	err := ioutil.WriteFile(filepath.Join(binDir, name), []byte("#!/bin/sh\n"+script), 0755)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+var _ = Describe("CLIReleaseBuilder", func() {
+	var (
+		binDir         string
+		releaseDir     string
+		argsFile       string
+		originalPath   string
+		logger         *fakes.Logger
+		releaseBuilder builder.CLIReleaseBuilder
+	)
+
+	BeforeEach(func() {
+		var err error
+		binDir, err = ioutil.TempDir("", "bin")
+		Expect(err).NotTo(HaveOccurred())
+
+		releaseDir, err = ioutil.TempDir("", "release")
+		Expect(err).NotTo(HaveOccurred())
+
+		argsFile = filepath.Join(binDir, "args")
+
+		originalPath = os.Getenv("PATH")
+		os.Setenv("PATH", binDir)
+
+		logger = &fakes.Logger{}
+		releaseBuilder = builder.NewCLIReleaseBuilder(releaseDir, logger)
+	})
+
+	AfterEach(func() {
+		os.Setenv("PATH", originalPath)
+
+		err := os.RemoveAll(binDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.RemoveAll(releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("when the v2 CLI is on the PATH", func() {
+		BeforeEach(func() {
+			writeFakeCLI(binDir, "bosh2", fmt.Sprintf(`
+if [ "$1" = "--version" ]; then
+  echo "version 2.0.48-e94aeeb-2018-01-09T23:08:07Z"
+  exit 0
+fi
+echo "$@" > %s
+for arg in "$@"; do
+  case "$arg" in
+    --tarball=*) echo "some-tarball" > "${arg#--tarball=}" ;;
+  esac
+done
+echo "Added job 'some-job/abc'"
+echo "some warning" >&2
+`, argsFile))
+		})
+
+		It("creates the release with create-release", func() {
+			tarballPath, err := releaseBuilder.Build("some-release", "42")
+			Expect(err).NotTo(HaveOccurred())
+
+			expectedPath := filepath.Join(releaseDir, "dev_releases/some-release/some-release-42.tgz")
+			Expect(tarballPath).To(Equal(expectedPath))
+			Expect(tarballPath).To(BeAnExistingFile())
+
+			args, err := ioutil.ReadFile(argsFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.TrimSpace(string(args))).To(Equal("create-release --force --name some-release --version 42 --tarball=" + expectedPath))
+		})
+
+		It("logs the output of the CLI", func() {
+			_, err := releaseBuilder.Build("some-release", "42")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(logger.Lines).To(Equal([]string{
+				"Added job 'some-job/abc'\n",
+				"some warning\n",
+			}))
+		})
+
+		It("prefers bosh2 over bosh", func() {
+			writeFakeCLI(binDir, "bosh", "exit 1\n")
+
+			_, err := releaseBuilder.Build("some-release", "42")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("when the v1 CLI is on the PATH", func() {
+		BeforeEach(func() {
+			writeFakeCLI(binDir, "bosh", fmt.Sprintf(`
+if [ "$1" = "--version" ]; then
+  echo "BOSH 1.3262.26.0"
+  exit 0
+fi
+echo "$@" > %s
+echo "Release name: some-release"
+echo "Release tarball (1.2K): /some/path/dev_releases/some-release/some-release-42.tgz"
+`, argsFile))
+		})
+
+		It("creates the release with create release and returns the tarball it reports", func() {
+			tarballPath, err := releaseBuilder.Build("some-release", "42")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tarballPath).To(Equal("/some/path/dev_releases/some-release/some-release-42.tgz"))
+
+			args, err := ioutil.ReadFile(argsFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.TrimSpace(string(args))).To(Equal("create release --force --name some-release --version 42 --with-tarball"))
+		})
+	})
+
+	Context("failure cases", func() {
+		Context("when there is no bosh CLI on the PATH", func() {
+			It("returns an error", func() {
+				_, err := releaseBuilder.Build("some-release", "42")
+				Expect(err).To(MatchError("could not find a bosh CLI on the PATH"))
+			})
+		})
+
+		Context("when the CLI version cannot be determined", func() {
+			It("returns an error", func() {
+				writeFakeCLI(binDir, "bosh", "echo 'something else'\n")
+
+				_, err := releaseBuilder.Build("some-release", "42")
+				Expect(err).To(MatchError(fmt.Sprintf("could not determine the version of %s from %q", filepath.Join(binDir, "bosh"), "something else")))
+			})
+		})
+
+		Context("when creating the release fails", func() {
+			It("returns an error and logs the output", func() {
+				writeFakeCLI(binDir, "bosh", `
+if [ "$1" = "--version" ]; then
+  echo "version 2.0.48"
+  exit 0
+fi
+echo "Release 'some-release' has uncommitted changes" >&2
+exit 1
+`)
+
+				_, err := releaseBuilder.Build("some-release", "42")
+				Expect(err).To(MatchError("bosh create-release failed: exit status 1"))
+				Expect(logger.Lines).To(Equal([]string{"Release 'some-release' has uncommitted changes\n"}))
+			})
+		})
+
+		Context("when the v1 CLI does not report a tarball", func() {
+			It("returns an error", func() {
+				writeFakeCLI(binDir, "bosh", `
+if [ "$1" = "--version" ]; then
+  echo "BOSH 1.3262.26.0"
+  exit 0
+fi
+`)
+
+				_, err := releaseBuilder.Build("some-release", "42")
+				Expect(err).To(MatchError(fmt.Sprintf("could not find the release tarball path in the output of %s", filepath.Join(binDir, "bosh"))))
+			})
+		})
+	})
+})
@@ -0,0 +1,163 @@
+package builder
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// releaseFile is a file of a job or package. ExcludeMode leaves its mode out
+// of the fingerprint, as the bosh CLI does for packaging, pre_packaging and
+// job files.
+type releaseFile struct {
+	Path         string
+	RelativePath string
+	ExcludeMode  bool
+}
+
+// fingerprint mirrors the v2 scheme used by the bosh CLI: the sha1 of "v2"
+// followed by each file's relative path, sha1 and git-style mode, with any
+// additional chunks (package dependencies) appended comma separated.
+func fingerprint(files []releaseFile, additionalChunks []string) (string, error) {
+	sorted := make([]releaseFile, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].RelativePath < sorted[j].RelativePath
+	})
+
+	chunks := []string{"v2"}
+	for _, file := range sorted {
+		digest, err := fileSHA1(file.Path)
+		if err != nil {
+			return "", err
+		}
+
+		chunks = append(chunks, file.RelativePath, digest)
+
+		if !file.ExcludeMode {
+			mode, err := fileMode(file.Path)
+			if err != nil {
+				return "", err
+			}
+
+			chunks = append(chunks, mode)
+		}
+	}
+
+	if len(additionalChunks) > 0 {
+		chunks = append(chunks, strings.Join(additionalChunks, ","))
+	}
+
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(chunks, "")))), nil
+}
+
+func fileSHA1(path string) (string, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close()
+
+	hash := sha1.New()
+	_, err = io.Copy(hash, fd)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+func fileMode(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if info.Mode()&0111 != 0 {
+		return "100755", nil
+	}
+
+	return "100644", nil
+}
+
+// globFiles returns the regular files under root matching pattern, which may
+// use "**" to match any number of directories as bosh package specs do.
+func globFiles(root, pattern string) ([]string, error) {
+	var matches []string
+
+	_, err := os.Stat(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			info, err = os.Stat(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		matched, err := matchGlob(strings.Split(pattern, "/"), strings.Split(filepath.ToSlash(relativePath), "/"))
+		if err != nil {
+			return err
+		}
+
+		if matched {
+			matches = append(matches, filepath.ToSlash(relativePath))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+func matchGlob(pattern, path []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				matched, err := matchGlob(pattern[1:], path[i:])
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+
+			return false, nil
+		}
+
+		if len(path) == 0 {
+			return false, nil
+		}
+
+		matched, err := filepath.Match(pattern[0], path[0])
+		if err != nil || !matched {
+			return false, err
+		}
+
+		pattern = pattern[1:]
+		path = path[1:]
+	}
+
+	return len(path) == 0, nil
+}
@@ -0,0 +1 @@
+some source
@@ -0,0 +1,98 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// prePackage stages the package files in a directory under workDir and runs
+// the package's pre_packaging script there as the bosh CLI does: with bash,
+// from the staging directory, with BUILD_DIR set to it and RELEASE_DIR to the
+// release directory. It returns the staged files, without the script, for the
+// package archive.
+func (b ReleaseBuilder) prePackage(workDir, name string, files []releaseFile) ([]releaseFile, error) {
+	stagingDir, err := ioutil.TempDir(workDir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var script string
+	for _, file := range files {
+		if file.RelativePath == "pre_packaging" {
+			script = file.Path
+			continue
+		}
+
+		err = stageFile(file.Path, filepath.Join(stagingDir, filepath.FromSlash(file.RelativePath)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	command := exec.Command("bash", "-x", script)
+	command.Dir = stagingDir
+	command.Env = append(os.Environ(), "BUILD_DIR="+stagingDir, "RELEASE_DIR="+b.releaseDir)
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("pre_packaging of package %s failed: %s\n%s", name, err, output)
+	}
+
+	var staged []releaseFile
+	err = filepath.Walk(stagingDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(stagingDir, path)
+		if err != nil {
+			return err
+		}
+
+		staged = append(staged, releaseFile{Path: path, RelativePath: filepath.ToSlash(relativePath)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return staged, nil
+}
+
+func stageFile(source, destination string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(destination), 0755)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
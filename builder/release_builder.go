@@ -0,0 +1,418 @@
+package builder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type ReleaseBuilder struct {
+	releaseDir string
+}
+
+type ReleaseManifest struct {
+	Name               string           `yaml:"name"`
+	Version            string           `yaml:"version"`
+	CommitHash         string           `yaml:"commit_hash"`
+	UncommittedChanges bool             `yaml:"uncommitted_changes"`
+	Jobs               []ReleaseJob     `yaml:"jobs"`
+	Packages           []ReleasePackage `yaml:"packages"`
+}
+
+type ReleaseJob struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
+	Fingerprint string `yaml:"fingerprint"`
+	SHA1        string `yaml:"sha1"`
+}
+
+type ReleasePackage struct {
+	Name         string   `yaml:"name"`
+	Version      string   `yaml:"version"`
+	Fingerprint  string   `yaml:"fingerprint"`
+	SHA1         string   `yaml:"sha1"`
+	Dependencies []string `yaml:"dependencies"`
+}
+
+type packageSpec struct {
+	Name          string   `yaml:"name"`
+	Dependencies  []string `yaml:"dependencies"`
+	Files         []string `yaml:"files"`
+	ExcludedFiles []string `yaml:"excluded_files"`
+}
+
+type jobSpec struct {
+	Name      string            `yaml:"name"`
+	Templates map[string]string `yaml:"templates"`
+	Packages  []string          `yaml:"packages"`
+}
+
+func NewReleaseBuilder(releaseDir string) ReleaseBuilder {
+	return ReleaseBuilder{
+		releaseDir: releaseDir,
+	}
+}
+
+// Build writes a dev release tarball for the release directory to
+// dev_releases/<name>/<name>-<version>.tgz and returns its path. When name is
+// empty it is read from the release config with ReleaseName.
+func (b ReleaseBuilder) Build(name, version string) (string, error) {
+	return b.build(name, version, "dev_releases")
+}
+
+// BuildFinal fetches any missing blobs from the blobstore and writes a final
+// release tarball to releases/<name>/<name>-<version>.tgz.
+func (b ReleaseBuilder) BuildFinal(name, version string, blobstore Blobstore) (string, error) {
+	err := SyncBlobs(b.releaseDir, blobstore)
+	if err != nil {
+		return "", err
+	}
+
+	return b.build(name, version, "releases")
+}
+
+func (b ReleaseBuilder) build(name, version, releasesDir string) (string, error) {
+	if name == "" {
+		var err error
+		name, err = ReleaseName(b.releaseDir)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	workDir, err := ioutil.TempDir("", "release-builder")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(workDir)
+
+	for _, dir := range []string{"jobs", "packages"} {
+		err = os.Mkdir(filepath.Join(workDir, dir), 0755)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	packages, err := b.buildPackages(workDir)
+	if err != nil {
+		return "", err
+	}
+
+	jobs, err := b.buildJobs(workDir, packages)
+	if err != nil {
+		return "", err
+	}
+
+	commitHash, uncommittedChanges := GitState(b.releaseDir)
+
+	manifest := ReleaseManifest{
+		Name:               name,
+		Version:            version,
+		CommitHash:         commitHash,
+		UncommittedChanges: uncommittedChanges,
+		Jobs:               jobs,
+		Packages:           packages,
+	}
+
+	manifestYAML, err := yaml.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(workDir, "release.MF"), manifestYAML, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	entries := []tarballEntry{{Name: "release.MF", Path: filepath.Join(workDir, "release.MF")}}
+	for _, job := range jobs {
+		entries = append(entries, tarballEntry{
+			Name: fmt.Sprintf("jobs/%s.tgz", job.Name),
+			Path: filepath.Join(workDir, "jobs", job.Name+".tgz"),
+		})
+	}
+	for _, pkg := range packages {
+		entries = append(entries, tarballEntry{
+			Name: fmt.Sprintf("packages/%s.tgz", pkg.Name),
+			Path: filepath.Join(workDir, "packages", pkg.Name+".tgz"),
+		})
+	}
+
+	outputDir := filepath.Join(b.releaseDir, releasesDir, name)
+	err = os.MkdirAll(outputDir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	tarballPath := filepath.Join(outputDir, fmt.Sprintf("%s-%s.tgz", name, version))
+	err = writeTarball(tarballPath, entries)
+	if err != nil {
+		return "", err
+	}
+
+	return tarballPath, nil
+}
+
+func (b ReleaseBuilder) buildPackages(workDir string) ([]ReleasePackage, error) {
+	specPaths, err := filepath.Glob(filepath.Join(b.releaseDir, "packages", "*", "spec"))
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []ReleasePackage
+	for _, specPath := range specPaths {
+		var spec packageSpec
+		err = readYAML(specPath, &spec)
+		if err != nil {
+			return nil, err
+		}
+
+		files, err := b.packageFiles(filepath.Dir(specPath), spec)
+		if err != nil {
+			return nil, err
+		}
+
+		archived := files
+		if hasFile(files, "pre_packaging") {
+			archived, err = b.prePackage(workDir, spec.Name, files)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		dependencies := append([]string{}, spec.Dependencies...)
+		sort.Strings(dependencies)
+
+		pkg, err := buildArtifact(filepath.Join(workDir, "packages"), spec.Name, files, archived, dependencies)
+		if err != nil {
+			return nil, err
+		}
+
+		packages = append(packages, ReleasePackage{
+			Name:         spec.Name,
+			Version:      pkg.Fingerprint,
+			Fingerprint:  pkg.Fingerprint,
+			SHA1:         pkg.SHA1,
+			Dependencies: dependencies,
+		})
+	}
+
+	names := map[string]bool{}
+	for _, pkg := range packages {
+		names[pkg.Name] = true
+	}
+
+	for _, pkg := range packages {
+		for _, dependency := range pkg.Dependencies {
+			if !names[dependency] {
+				return nil, fmt.Errorf("package %s depends on missing package %s", pkg.Name, dependency)
+			}
+		}
+	}
+
+	return packages, nil
+}
+
+func (b ReleaseBuilder) packageFiles(packageDir string, spec packageSpec) ([]releaseFile, error) {
+	files := []releaseFile{{
+		Path:         filepath.Join(packageDir, "packaging"),
+		RelativePath: "packaging",
+		ExcludeMode:  true,
+	}}
+
+	prePackagingPath := filepath.Join(packageDir, "pre_packaging")
+	_, err := os.Stat(prePackagingPath)
+	if err == nil {
+		files = append(files, releaseFile{
+			Path:         prePackagingPath,
+			RelativePath: "pre_packaging",
+			ExcludeMode:  true,
+		})
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	excluded := map[string]bool{}
+	for _, pattern := range spec.ExcludedFiles {
+		matches, err := globFiles(filepath.Join(b.releaseDir, "src"), pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, match := range matches {
+			excluded[match] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, pattern := range spec.Files {
+		var found bool
+		for _, root := range []string{"src", "blobs"} {
+			matches, err := globFiles(filepath.Join(b.releaseDir, root), pattern)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, match := range matches {
+				found = true
+				if excluded[match] || seen[match] {
+					continue
+				}
+
+				seen[match] = true
+				files = append(files, releaseFile{
+					Path:         filepath.Join(b.releaseDir, root, match),
+					RelativePath: match,
+				})
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("package %s has no files matching %q", spec.Name, pattern)
+		}
+	}
+
+	return files, nil
+}
+
+func (b ReleaseBuilder) buildJobs(workDir string, packages []ReleasePackage) ([]ReleaseJob, error) {
+	specPaths, err := filepath.Glob(filepath.Join(b.releaseDir, "jobs", "*", "spec"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, pkg := range packages {
+		names[pkg.Name] = true
+	}
+
+	var jobs []ReleaseJob
+	for _, specPath := range specPaths {
+		jobDir := filepath.Dir(specPath)
+
+		var spec jobSpec
+		err = readYAML(specPath, &spec)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pkg := range spec.Packages {
+			if !names[pkg] {
+				return nil, fmt.Errorf("job %s depends on missing package %s", spec.Name, pkg)
+			}
+		}
+
+		files := []releaseFile{
+			{Path: specPath, RelativePath: "spec", ExcludeMode: true},
+			{Path: filepath.Join(jobDir, "monit"), RelativePath: "monit", ExcludeMode: true},
+		}
+		for template := range spec.Templates {
+			files = append(files, releaseFile{
+				Path:         filepath.Join(jobDir, "templates", template),
+				RelativePath: filepath.ToSlash(filepath.Join("templates", template)),
+				ExcludeMode:  true,
+			})
+		}
+
+		job, err := buildArtifact(filepath.Join(workDir, "jobs"), spec.Name, files, files, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, ReleaseJob{
+			Name:        spec.Name,
+			Version:     job.Fingerprint,
+			Fingerprint: job.Fingerprint,
+			SHA1:        job.SHA1,
+		})
+	}
+
+	return jobs, nil
+}
+
+type artifact struct {
+	Fingerprint string
+	SHA1        string
+}
+
+// buildArtifact fingerprints the files of a job or package and archives the
+// archived files, which differ from them once a pre_packaging script has run,
+// into <dir>/<name>.tgz. A job's spec is stored in the archive as job.MF, as
+// the director expects.
+func buildArtifact(dir, name string, files, archived []releaseFile, additionalChunks []string) (artifact, error) {
+	digest, err := fingerprint(files, additionalChunks)
+	if err != nil {
+		return artifact{}, err
+	}
+
+	var entries []tarballEntry
+	for _, file := range archived {
+		entryName := file.RelativePath
+		if entryName == "spec" {
+			entryName = "job.MF"
+		}
+
+		entries = append(entries, tarballEntry{Name: entryName, Path: file.Path})
+	}
+
+	path := filepath.Join(dir, name+".tgz")
+	err = writeTarball(path, entries)
+	if err != nil {
+		return artifact{}, err
+	}
+
+	checksum, err := fileSHA1(path)
+	if err != nil {
+		return artifact{}, err
+	}
+
+	return artifact{
+		Fingerprint: digest,
+		SHA1:        checksum,
+	}, nil
+}
+
+func hasFile(files []releaseFile, relativePath string) bool {
+	for _, file := range files {
+		if file.RelativePath == relativePath {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GitState reports the short commit hash and whether the release directory
+// has uncommitted changes, falling back to "non-git" outside of a repository.
+func GitState(releaseDir string) (string, bool) {
+	revParse := exec.Command("git", "rev-parse", "--short", "HEAD")
+	revParse.Dir = releaseDir
+	output, err := revParse.Output()
+	if err != nil {
+		return "non-git", false
+	}
+
+	status := exec.Command("git", "status", "--porcelain")
+	status.Dir = releaseDir
+	changes, err := status.Output()
+	if err != nil {
+		return "non-git", false
+	}
+
+	return strings.TrimSpace(string(output)), len(strings.TrimSpace(string(changes))) > 0
+}
+
+func readYAML(path string, v interface{}) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(content, v)
+}
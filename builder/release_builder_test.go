@@ -0,0 +1,357 @@
+package builder_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/builder"
+	"gopkg.in/yaml.v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func sha1Of(content []byte) string {
+	return fmt.Sprintf("%x", sha1.Sum(content))
+}
+
+var _ = Describe("ReleaseBuilder", func() {
+	var (
+		releaseDir     string
+		releaseBuilder builder.ReleaseBuilder
+	)
+
+	BeforeEach(func() {
+		var err error
+		releaseDir, err = ioutil.TempDir("", "release-builder")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = copyDir("fixtures/some-release", releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		releaseBuilder = builder.NewReleaseBuilder(releaseDir)
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("Build", func() {
+		It("writes a dev release tarball named after config/final.yml", func() {
+			tarballPath, err := releaseBuilder.Build("", "42")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tarballPath).To(Equal(filepath.Join(releaseDir, "dev_releases/some-release/some-release-42.tgz")))
+
+			files, err := readTarball(tarballPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(HaveKey("release.MF"))
+			Expect(files).To(HaveKey("jobs/some-job.tgz"))
+			Expect(files).To(HaveKey("packages/some-package.tgz"))
+			Expect(files).To(HaveKey("packages/other-package.tgz"))
+		})
+
+		It("uses the given name over config/final.yml", func() {
+			tarballPath, err := releaseBuilder.Build("other-name", "1.2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tarballPath).To(Equal(filepath.Join(releaseDir, "dev_releases/other-name/other-name-1.2.tgz")))
+		})
+
+		It("describes the jobs and packages in the release manifest", func() {
+			tarballPath, err := releaseBuilder.Build("", "42")
+			Expect(err).NotTo(HaveOccurred())
+
+			files, err := readTarball(tarballPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			var manifest builder.ReleaseManifest
+			err = yaml.Unmarshal(files["release.MF"], &manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifest.Name).To(Equal("some-release"))
+			Expect(manifest.Version).To(Equal("42"))
+			Expect(manifest.CommitHash).To(Equal("non-git"))
+
+			Expect(manifest.Jobs).To(HaveLen(1))
+			Expect(manifest.Jobs[0].Name).To(Equal("some-job"))
+			Expect(manifest.Jobs[0].Version).To(Equal(manifest.Jobs[0].Fingerprint))
+			Expect(manifest.Jobs[0].SHA1).To(Equal(sha1Of(files["jobs/some-job.tgz"])))
+
+			Expect(manifest.Packages).To(HaveLen(2))
+			Expect(manifest.Packages[0].Name).To(Equal("other-package"))
+			Expect(manifest.Packages[0].Dependencies).To(BeEmpty())
+			Expect(manifest.Packages[1].Name).To(Equal("some-package"))
+			Expect(manifest.Packages[1].Dependencies).To(Equal([]string{"other-package"}))
+			Expect(manifest.Packages[1].SHA1).To(Equal(sha1Of(files["packages/some-package.tgz"])))
+		})
+
+		// The expected fingerprints follow the bosh CLI's v2 scheme: the sha1 of
+		// "v2", then each file's path, sha1 and mode in path order, then the
+		// package dependencies. The CLI leaves the mode out for packaging,
+		// pre_packaging and job files.
+		It("fingerprints jobs from their spec, monit and templates", func() {
+			tarballPath, err := releaseBuilder.Build("", "42")
+			Expect(err).NotTo(HaveOccurred())
+
+			files, err := readTarball(tarballPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			var manifest builder.ReleaseManifest
+			err = yaml.Unmarshal(files["release.MF"], &manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			jobDir := filepath.Join(releaseDir, "jobs/some-job")
+			monit, err := ioutil.ReadFile(filepath.Join(jobDir, "monit"))
+			Expect(err).NotTo(HaveOccurred())
+			spec, err := ioutil.ReadFile(filepath.Join(jobDir, "spec"))
+			Expect(err).NotTo(HaveOccurred())
+			template, err := ioutil.ReadFile(filepath.Join(jobDir, "templates/ctl.erb"))
+			Expect(err).NotTo(HaveOccurred())
+
+			expected := sha1Of([]byte("v2" +
+				"monit" + sha1Of(monit) +
+				"spec" + sha1Of(spec) +
+				"templates/ctl.erb" + sha1Of(template)))
+			Expect(manifest.Jobs[0].Fingerprint).To(Equal(expected))
+		})
+
+		It("fingerprints packages from their files, modes and dependencies", func() {
+			tarballPath, err := releaseBuilder.Build("", "42")
+			Expect(err).NotTo(HaveOccurred())
+
+			files, err := readTarball(tarballPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			var manifest builder.ReleaseManifest
+			err = yaml.Unmarshal(files["release.MF"], &manifest)
+			Expect(err).NotTo(HaveOccurred())
+
+			main, err := ioutil.ReadFile(filepath.Join(releaseDir, "src/some-package/main.c"))
+			Expect(err).NotTo(HaveOccurred())
+			script, err := ioutil.ReadFile(filepath.Join(releaseDir, "src/some-package/nested/build.sh"))
+			Expect(err).NotTo(HaveOccurred())
+			packaging, err := ioutil.ReadFile(filepath.Join(releaseDir, "packages/some-package/packaging"))
+			Expect(err).NotTo(HaveOccurred())
+			prePackaging, err := ioutil.ReadFile(filepath.Join(releaseDir, "packages/some-package/pre_packaging"))
+			Expect(err).NotTo(HaveOccurred())
+
+			expected := sha1Of([]byte("v2" +
+				"packaging" + sha1Of(packaging) +
+				"pre_packaging" + sha1Of(prePackaging) +
+				"some-package/main.c" + sha1Of(main) + "100644" +
+				"some-package/nested/build.sh" + sha1Of(script) + "100755" +
+				"other-package"))
+			Expect(manifest.Packages[1].Fingerprint).To(Equal(expected))
+		})
+
+		It("runs the pre_packaging script of a package in its staging directory", func() {
+			tarballPath, err := releaseBuilder.Build("", "42")
+			Expect(err).NotTo(HaveOccurred())
+
+			files, err := readTarball(tarballPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			packageFiles, err := readTarballFrom(bytes.NewReader(files["packages/some-package.tgz"]))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(packageFiles).To(HaveKeyWithValue("some-package/generated.txt", []byte("generated\n")))
+			Expect(packageFiles).NotTo(HaveKey("pre_packaging"))
+			Expect(filepath.Join(releaseDir, "src/some-package/generated.txt")).NotTo(BeAnExistingFile())
+		})
+
+		It("returns an error when the pre_packaging script fails", func() {
+			err := ioutil.WriteFile(filepath.Join(releaseDir, "packages/some-package/pre_packaging"), []byte("exit 3\n"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = releaseBuilder.Build("", "42")
+			Expect(err).To(MatchError(ContainSubstring("pre_packaging of package some-package failed: exit status 3")))
+		})
+
+		It("archives the job spec as job.MF", func() {
+			tarballPath, err := releaseBuilder.Build("", "42")
+			Expect(err).NotTo(HaveOccurred())
+
+			files, err := readTarball(tarballPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			jobFiles, err := readTarballFrom(bytes.NewReader(files["jobs/some-job.tgz"]))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jobFiles).To(HaveLen(3))
+			Expect(jobFiles).To(HaveKey("job.MF"))
+			Expect(jobFiles).To(HaveKey("monit"))
+			Expect(jobFiles).To(HaveKey("templates/ctl.erb"))
+		})
+
+		It("archives package files from src and blobs without excluded files", func() {
+			tarballPath, err := releaseBuilder.Build("", "42")
+			Expect(err).NotTo(HaveOccurred())
+
+			files, err := readTarball(tarballPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			packageFiles, err := readTarballFrom(bytes.NewReader(files["packages/some-package.tgz"]))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(packageFiles).To(HaveLen(4))
+			Expect(packageFiles).To(HaveKey("packaging"))
+			Expect(packageFiles).To(HaveKey("some-package/main.c"))
+			Expect(packageFiles).To(HaveKey("some-package/nested/build.sh"))
+
+			blobFiles, err := readTarballFrom(bytes.NewReader(files["packages/other-package.tgz"]))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blobFiles).To(HaveKey("other-package/other-1.0.tgz"))
+		})
+
+		It("produces the same fingerprints when rebuilt", func() {
+			firstPath, err := releaseBuilder.Build("", "1")
+			Expect(err).NotTo(HaveOccurred())
+			secondPath, err := releaseBuilder.Build("", "2")
+			Expect(err).NotTo(HaveOccurred())
+
+			first, err := readTarball(firstPath)
+			Expect(err).NotTo(HaveOccurred())
+			second, err := readTarball(secondPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			var firstManifest, secondManifest builder.ReleaseManifest
+			Expect(yaml.Unmarshal(first["release.MF"], &firstManifest)).To(Succeed())
+			Expect(yaml.Unmarshal(second["release.MF"], &secondManifest)).To(Succeed())
+			Expect(firstManifest.Packages[1].Fingerprint).To(Equal(secondManifest.Packages[1].Fingerprint))
+			Expect(firstManifest.Jobs[0].Fingerprint).To(Equal(secondManifest.Jobs[0].Fingerprint))
+		})
+
+		Context("when building a final release", func() {
+			var blobstorePath string
+
+			BeforeEach(func() {
+				var err error
+				blobstorePath, err = ioutil.TempDir("", "local-blobstore")
+				Expect(err).NotTo(HaveOccurred())
+
+				blobPath := filepath.Join(releaseDir, "blobs/other-package/other-1.0.tgz")
+				blob, err := ioutil.ReadFile(blobPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = ioutil.WriteFile(filepath.Join(blobstorePath, "other-object-id"), blob, 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = ioutil.WriteFile(filepath.Join(releaseDir, "config/blobs.yml"), []byte(fmt.Sprintf(`---
+other-package/other-1.0.tgz:
+  size: %d
+  object_id: other-object-id
+  sha: %s
+`, len(blob), sha1Of(blob))), 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = os.RemoveAll(filepath.Join(releaseDir, "blobs"))
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				err := os.RemoveAll(blobstorePath)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("fetches the blobs and writes the tarball to releases", func() {
+				tarballPath, err := releaseBuilder.BuildFinal("", "7", builder.NewLocalBlobstore(blobstorePath))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tarballPath).To(Equal(filepath.Join(releaseDir, "releases/some-release/some-release-7.tgz")))
+
+				files, err := readTarball(tarballPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				blobFiles, err := readTarballFrom(bytes.NewReader(files["packages/other-package.tgz"]))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(blobFiles["other-package/other-1.0.tgz"])).To(Equal("not really a tarball\n"))
+			})
+		})
+
+		Context("failure cases", func() {
+			Context("when config/final.yml has no name", func() {
+				It("returns an error", func() {
+					err := ioutil.WriteFile(filepath.Join(releaseDir, "config/final.yml"), []byte("---\n{}\n"), 0644)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = releaseBuilder.Build("", "42")
+					Expect(err).To(MatchError(fmt.Sprintf("could not find a release name in %q or %q", filepath.Join(releaseDir, "config/final.yml"), filepath.Join(releaseDir, "config/dev.yml"))))
+				})
+			})
+
+			Context("when a package depends on a missing package", func() {
+				It("returns an error", func() {
+					err := os.RemoveAll(filepath.Join(releaseDir, "packages/other-package"))
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = releaseBuilder.Build("", "42")
+					Expect(err).To(MatchError("package some-package depends on missing package other-package"))
+				})
+			})
+
+			Context("when a job depends on a missing package", func() {
+				It("returns an error", func() {
+					err := ioutil.WriteFile(filepath.Join(releaseDir, "jobs/some-job/spec"), []byte(`---
+name: some-job
+templates:
+  ctl.erb: bin/ctl
+packages:
+- missing-package
+`), 0644)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = releaseBuilder.Build("", "42")
+					Expect(err).To(MatchError("job some-job depends on missing package missing-package"))
+				})
+			})
+
+			Context("when a package file pattern matches nothing", func() {
+				It("returns an error", func() {
+					err := os.RemoveAll(filepath.Join(releaseDir, "blobs/other-package"))
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = releaseBuilder.Build("", "42")
+					Expect(err).To(MatchError(`package other-package has no files matching "other-package/*.tgz"`))
+				})
+			})
+		})
+	})
+
+	Describe("GitState", func() {
+		git := func(args ...string) {
+			command := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+			command.Dir = releaseDir
+			output, err := command.CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(output))
+		}
+
+		It("reports non-git outside of a repository", func() {
+			commitHash, dirty := builder.GitState(releaseDir)
+			Expect(commitHash).To(Equal("non-git"))
+			Expect(dirty).To(BeFalse())
+		})
+
+		Context("inside a repository", func() {
+			BeforeEach(func() {
+				git("init", "-q")
+				git("add", ".")
+				git("commit", "-q", "-m", "initial")
+			})
+
+			It("reports the short commit hash of a clean tree", func() {
+				commitHash, dirty := builder.GitState(releaseDir)
+				Expect(commitHash).To(MatchRegexp(`^[0-9a-f]{7,}$`))
+				Expect(dirty).To(BeFalse())
+			})
+
+			It("reports uncommitted changes", func() {
+				err := ioutil.WriteFile(filepath.Join(releaseDir, "src/some-package/main.c"), []byte("changed"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, dirty := builder.GitState(releaseDir)
+				Expect(dirty).To(BeTrue())
+			})
+		})
+	})
+})
@@ -0,0 +1,40 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type releaseConfig struct {
+	Name      string `yaml:"name"`
+	FinalName string `yaml:"final_name"`
+	DevName   string `yaml:"dev_name"`
+}
+
+// ReleaseName reads the name of the release in releaseDir from the final_name
+// or name in config/final.yml, falling back to the dev_name or name in
+// config/dev.yml.
+func ReleaseName(releaseDir string) (string, error) {
+	finalPath := filepath.Join(releaseDir, "config", "final.yml")
+	devPath := filepath.Join(releaseDir, "config", "dev.yml")
+
+	for _, path := range []string{finalPath, devPath} {
+		var config releaseConfig
+		err := readYAML(path, &config)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		for _, name := range []string{config.FinalName, config.DevName, config.Name} {
+			if name != "" {
+				return name, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("could not find a release name in %q or %q", finalPath, devPath)
+}
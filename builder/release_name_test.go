@@ -0,0 +1,90 @@
+package builder_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/builder"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReleaseName", func() {
+	var releaseDir string
+
+	BeforeEach(func() {
+		var err error
+		releaseDir, err = ioutil.TempDir("", "release-name")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.Mkdir(filepath.Join(releaseDir, "config"), 0755)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	writeConfig := func(name, content string) {
+		err := ioutil.WriteFile(filepath.Join(releaseDir, "config", name), []byte(content), 0644)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("reads final_name from config/final.yml", func() {
+		writeConfig("final.yml", "---\nfinal_name: some-final-name\nname: some-name\n")
+		writeConfig("dev.yml", "---\ndev_name: some-dev-name\n")
+
+		name, err := builder.ReleaseName(releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("some-final-name"))
+	})
+
+	It("reads name from config/final.yml", func() {
+		writeConfig("final.yml", "---\nname: some-name\n")
+
+		name, err := builder.ReleaseName(releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("some-name"))
+	})
+
+	It("falls back to config/dev.yml", func() {
+		writeConfig("final.yml", "---\nblobstore:\n  provider: local\n")
+		writeConfig("dev.yml", "---\ndev_name: some-dev-name\n")
+
+		name, err := builder.ReleaseName(releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("some-dev-name"))
+	})
+
+	It("falls back to config/dev.yml when there is no config/final.yml", func() {
+		writeConfig("dev.yml", "---\nname: some-name\n")
+
+		name, err := builder.ReleaseName(releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("some-name"))
+	})
+
+	Context("failure cases", func() {
+		Context("when neither config names the release", func() {
+			It("returns an error", func() {
+				_, err := builder.ReleaseName(releaseDir)
+				Expect(err).To(MatchError(fmt.Sprintf("could not find a release name in %q or %q",
+					filepath.Join(releaseDir, "config/final.yml"),
+					filepath.Join(releaseDir, "config/dev.yml"))))
+			})
+		})
+
+		Context("when the config is not YAML", func() {
+			It("returns an error", func() {
+				writeConfig("final.yml", "%%%%%")
+
+				_, err := builder.ReleaseName(releaseDir)
+				Expect(err).To(MatchError("yaml: could not find expected directive name"))
+			})
+		})
+	})
+})
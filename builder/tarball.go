@@ -0,0 +1,71 @@
+package builder
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+type tarballEntry struct {
+	Name string
+	Path string
+}
+
+func writeTarball(path string, entries []tarballEntry) error {
+	tarball, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer tarball.Close()
+
+	gw := gzip.NewWriter(tarball)
+	tw := tar.NewWriter(gw)
+
+	for _, entry := range entries {
+		err = addToTarball(tw, entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	err = gw.Close()
+	if err != nil {
+		return err
+	}
+
+	return tarball.Close()
+}
+
+func addToTarball(tw *tar.Writer, entry tarballEntry) error {
+	fd, err := os.Open(entry.Path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	info, err := fd.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{
+		Name:    "./" + entry.Name,
+		Size:    info.Size(),
+		Mode:    int64(info.Mode().Perm()),
+		ModTime: info.ModTime(),
+	}
+
+	err = tw.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, fd)
+	return err
+}
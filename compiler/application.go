@@ -3,20 +3,40 @@ package compiler
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/pivotal-cf-experimental/bosh-test/bosh"
+	"gopkg.in/yaml.v2"
 )
 
 type Application struct {
-	ReleaseTarballPath  string
-	StemcellTarballPath string
-	OutputDirectory     string
-	BOSHClient          boshClient
-	ManifestGenerator   manifestGenerator
-	GUIDGenerator       func() (string, error)
-	Logger              logger
+	ReleaseTarballPath         string
+	ReleaseURL                 string
+	ReleaseSHA1                string
+	ReleaseManifestPath        string
+	StemcellTarballPath        string
+	StemcellURL                string
+	StemcellSHA1               string
+	StemcellManifestPath       string
+	OutputDirectory            string
+	ForceUpload                bool
+	AllowDirty                 bool
+	Deployment                 DeploymentSettings
+	ManifestTemplatePath       string
+	OpsFilePaths               []string
+	ExcludeRuntimeConfigAddons bool
+	DryRun                     bool
+	SigningKey                 string
+	BOSHClient                 boshClient
+	DirectorClient             directorClient
+	ManifestGenerator          manifestGenerator
+	GUIDGenerator              func() (string, error)
+	Logger                     logger
+	EventLogger                eventLogger
 }
 
 type boshClient interface {
@@ -24,15 +44,37 @@ type boshClient interface {
 	ExportRelease(deploymentName, releaseName, releaseVersion, stemcellName, stemcellVersion string) (resourceID string, err error)
 	Deploy(manifest []byte) (taskID int, err error)
 	UploadStemcell(stemcell bosh.SizeReader) (taskID int, err error)
+	UploadStemcellURL(url, sha1 string) (taskID int, err error)
 	UploadRelease(release bosh.SizeReader) (taskID int, err error)
-	Info() (bosh.DirectorInfo, error)
+	UploadReleaseURL(url, sha1 string) (taskID int, err error)
 	DeleteDeployment(name string) error
 	Cleanup() (taskID int, err error)
 	Deployments() (deploymentList []bosh.Deployment, err error)
+	Stemcell(name string) (bosh.Stemcell, error)
+	Release(name string) (bosh.Release, error)
+}
+
+type directorClient interface {
+	Info() (info DirectorInfo, err error)
+	Configs(configType string) (configs []Config, err error)
+	UpdateConfig(config Config) error
+	Stemcells() (stemcells []DirectorStemcell, err error)
+	ReleaseVersion(name, version string) (release DirectorRelease, err error)
 }
 
 type manifestGenerator interface {
-	Generate(directorUUID, deploymentName string, release Release, stemcell Stemcell) (manifest []byte, err error)
+	Generate(directorUUID, deploymentName string, release Release, stemcell Stemcell, settings DeploymentSettings) (manifest []byte, err error)
+}
+
+type Result struct {
+	CompiledReleasePath string
+	ReportPath          string
+	SignaturePath       string
+	SBOMPath            string
+	CommitHash          string
+	SkippedUploads      []string
+	RuntimeConfigAddons []string
+	Plan                []string
 }
 
 type logger interface {
@@ -40,110 +82,750 @@ type logger interface {
 	Printf(format string, v ...interface{})
 }
 
-func (a Application) Run() error {
-	a.Logger.Println("deleting existing deployments")
-	deploymentList, err := a.BOSHClient.Deployments()
+type eventLogger interface {
+	Event(event Event)
+}
+
+// Event describes a step of a Run once it has finished. Its outcome is one of
+// "succeeded", "failed", "skipped" or, in a dry run, "planned".
+type Event struct {
+	Step       string
+	Release    string
+	Stemcell   string
+	Deployment string
+	TaskID     int
+	Duration   time.Duration
+	Outcome    string
+	Error      string
+}
+
+func (a Application) Run() (result Result, err error) {
+	c := &compilation{result: &result}
+
+	start := time.Now()
+	defer func() {
+		event := c.event("compile")
+		if a.DryRun {
+			event.Outcome = "planned"
+		}
+		a.report(c, event, start, err)
+	}()
+
+	// The release and stemcell are checked before the director is changed, so
+	// that a release or stemcell that cannot be compiled leaves its deployments
+	// in place.
+	a.Logger.Println("parsing release details")
+	var release Release
+	err = a.track(c, "parse_release", func(*Event) error {
+		var err error
+		release, err = a.release()
+		return err
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	c.release = fmt.Sprintf("%s/%s", release.Name, release.Version)
+
+	if release.UncommittedChanges && !a.AllowDirty {
+		return Result{}, fmt.Errorf("release %s %s was built with uncommitted changes, set allow_dirty to compile it anyway", release.Name, release.Version)
+	}
+
+	result.CommitHash = release.CommitHash
+
+	a.Logger.Println("fetching bosh director information")
+	var directorInfo DirectorInfo
+	err = a.track(c, "director_info", func(*Event) error {
+		var err error
+		directorInfo, err = a.DirectorClient.Info()
+		return err
+	})
 	if err != nil {
+		return Result{}, err
+	}
+
+	a.Logger.Println("parsing stemcell details")
+	var stemcell Stemcell
+	err = a.track(c, "parse_stemcell", func(*Event) error {
+		var err error
+		stemcell, err = a.stemcell()
 		return err
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	c.stemcell = fmt.Sprintf("%s/%s", stemcell.Name, stemcell.Version)
+
+	a.Logger.Println("checking director compatibility")
+	err = a.track(c, "check_compatibility", func(*Event) error {
+		return CheckCompatibility(directorInfo, stemcell)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	a.Logger.Println("deleting existing deployments")
+	var deploymentList []bosh.Deployment
+	err = a.track(c, "list_deployments", func(*Event) error {
+		var err error
+		deploymentList, err = a.BOSHClient.Deployments()
+		return err
+	})
+	if err != nil {
+		return Result{}, err
 	}
 
 	for _, deployment := range deploymentList {
-		err = a.BOSHClient.DeleteDeployment(deployment.Name)
+		name := deployment.Name
+		err = a.perform(c, "delete_deployment", fmt.Sprintf("delete deployment %s", name), func(event *Event) error {
+			event.Deployment = name
+			return a.BOSHClient.DeleteDeployment(name)
+		})
 		if err != nil {
-			return err
+			return Result{}, err
 		}
 	}
 
-	a.Logger.Println("preparing compiler")
-	_, err = a.BOSHClient.Cleanup()
-	if err != nil {
+	err = a.perform(c, "cleanup", "clean up the director", func(event *Event) error {
+		a.Logger.Println("preparing compiler")
+		var err error
+		event.TaskID, err = a.BOSHClient.Cleanup()
 		return err
+	})
+	if err != nil {
+		return Result{}, err
 	}
 
-	a.Logger.Println("fetching bosh director information")
-	directorInfo, err := a.BOSHClient.Info()
-	if err != nil {
+	a.Logger.Println("checking the cloud config")
+	var settings DeploymentSettings
+	err = a.track(c, "cloud_config", func(*Event) error {
+		var err error
+		settings, err = a.deploymentSettings()
 		return err
+	})
+	if err != nil {
+		return Result{}, err
 	}
 
 	a.Logger.Println("generating deployment name")
 	guid, err := a.GUIDGenerator()
 	if err != nil {
-		return err
+		return Result{}, err
 	}
 
 	deploymentName := fmt.Sprintf("compile-release-%s", guid)
+	c.deployment = deploymentName
 
-	a.Logger.Println("parsing release details")
-	release, err := NewRelease(a.ReleaseTarballPath)
+	skipped, err := a.uploadStemcell(c, stemcell)
 	if err != nil {
-		return err
+		return Result{}, err
 	}
 
-	a.Logger.Println("parsing stemcell details")
-	stemcell, err := NewStemcell(a.StemcellTarballPath)
+	if skipped {
+		result.SkippedUploads = append(result.SkippedUploads, fmt.Sprintf("stemcell %s %s", stemcell.Name, stemcell.Version))
+	}
+
+	skipped, err = a.uploadRelease(c, release)
 	if err != nil {
-		return err
+		return Result{}, err
+	}
+
+	if skipped {
+		result.SkippedUploads = append(result.SkippedUploads, fmt.Sprintf("release %s %s", release.Name, release.Version))
 	}
 
-	a.Logger.Printf("uploading stemcell %s %s\n", stemcell.Name, stemcell.Version)
-	_, err = a.BOSHClient.UploadStemcell(stemcell)
+	a.Logger.Println("generating deployment manifest")
+	var manifest []byte
+	err = a.track(c, "generate_manifest", func(*Event) error {
+		var err error
+		manifest, err = a.ManifestGenerator.Generate(directorInfo.UUID, deploymentName, release, stemcell, settings)
+		if err != nil {
+			return err
+		}
+
+		manifest, err = a.customizeManifest(manifest)
+		return err
+	})
 	if err != nil {
+		return Result{}, err
+	}
+
+	a.Logger.Println("checking runtime config addons")
+	var (
+		runtimeConfigs []Config
+		addons         []RuntimeConfigAddon
+	)
+	err = a.track(c, "runtime_config", func(*Event) error {
+		var err error
+		runtimeConfigs, addons, err = a.runtimeConfigAddons(manifest)
 		return err
+	})
+	if err != nil {
+		return Result{}, err
 	}
 
-	a.Logger.Printf("uploading release %s %s\n", release.Name, release.Version)
-	_, err = a.BOSHClient.UploadRelease(release)
+	for _, addon := range addons {
+		a.Logger.Printf("runtime config %s colocates addon %s on the compile deployment\n", addon.Config, addon.Addon)
+		result.RuntimeConfigAddons = append(result.RuntimeConfigAddons, addon.String())
+	}
+
+	if a.ExcludeRuntimeConfigAddons && len(addons) > 0 {
+		// The restore is deferred first so that the configs already excluded
+		// from are restored when excluding from a later one fails.
+		var exclusions []runtimeConfigExclusion
+		defer func() {
+			restoreErr := a.restoreRuntimeConfigs(c, exclusions)
+			if restoreErr != nil && err == nil {
+				result, err = Result{}, restoreErr
+			}
+		}()
+
+		exclusions, err = a.excludeFromRuntimeConfigs(c, runtimeConfigs, addons, deploymentName)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	err = a.perform(c, "deploy", fmt.Sprintf("deploy %s", deploymentName), func(event *Event) error {
+		a.Logger.Println("deploying to bosh director")
+		var err error
+		event.TaskID, err = a.BOSHClient.Deploy(manifest)
+		return err
+	})
 	if err != nil {
+		return Result{}, err
+	}
+
+	var resourceID string
+	err = a.perform(c, "export_release", fmt.Sprintf("export release %s %s compiled against stemcell %s %s", release.Name, release.Version, stemcell.Name, stemcell.Version), func(*Event) error {
+		a.Logger.Println("compiling the release")
+		var err error
+		resourceID, err = a.BOSHClient.ExportRelease(deploymentName, release.Name, release.Version, stemcell.Name, stemcell.Version)
 		return err
+	})
+	if err != nil {
+		return Result{}, err
 	}
 
-	a.Logger.Println("generating deployment manifest")
-	manifest, err := a.ManifestGenerator.Generate(directorInfo.UUID, deploymentName, release, stemcell)
+	compiledTarballPath := filepath.Join(a.OutputDirectory, fmt.Sprintf("%s-%s-%s.tgz", release.Name, release.Semver, stemcell.Semver))
+	err = a.perform(c, "download", fmt.Sprintf("download the compiled release to %s", compiledTarballPath), func(*Event) error {
+		a.Logger.Println("downloading the compiled release")
+		return a.download(resourceID, compiledTarballPath)
+	})
 	if err != nil {
-		return err
+		return Result{}, err
 	}
 
-	a.Logger.Println("deploying to bosh director")
-	_, err = a.BOSHClient.Deploy(manifest)
+	err = a.perform(c, "delete_deployment", fmt.Sprintf("delete deployment %s", deploymentName), func(*Event) error {
+		a.Logger.Println("deleting the deployment")
+		return a.BOSHClient.DeleteDeployment(deploymentName)
+	})
 	if err != nil {
+		return Result{}, err
+	}
+
+	err = a.perform(c, "cleanup", "clean up the director", func(event *Event) error {
+		a.Logger.Println("cleaning up")
+		var err error
+		event.TaskID, err = a.BOSHClient.Cleanup()
 		return err
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	if a.DryRun {
+		return result, nil
 	}
 
-	a.Logger.Println("compiling the release")
-	resourceID, err := a.BOSHClient.ExportRelease(deploymentName, release.Name, release.Version, stemcell.Name, stemcell.Version)
+	result.CompiledReleasePath = compiledTarballPath
+
+	compiled, err := ReadReleaseManifest(compiledTarballPath)
 	if err != nil {
-		return err
+		return Result{}, fmt.Errorf("could not read the compiled release: %s", err)
 	}
 
-	a.Logger.Println("downloading the compiled release")
-	compiledTarballPath := filepath.Join(a.OutputDirectory, fmt.Sprintf("%s-%s-%s.tgz", release.Name, release.Semver, stemcell.Semver))
-	fd, err := os.OpenFile(compiledTarballPath, os.O_CREATE|os.O_WRONLY, 0644)
+	a.Logger.Println("verifying the compiled release")
+	err = a.track(c, "verify", func(*Event) error {
+		return VerifyCompiledRelease(release, compiled)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	a.Logger.Println("writing the sbom")
+	err = a.track(c, "sbom", func(*Event) error {
+		result.SBOMPath = strings.TrimSuffix(compiledTarballPath, ".tgz") + ".cdx.json"
+		return NewSBOM(compiled, stemcell).Write(result.SBOMPath)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	if a.SigningKey != "" {
+		a.Logger.Println("signing the compiled release")
+		err = a.track(c, "sign", func(*Event) error {
+			var err error
+			result.SignaturePath, err = SignTarball(compiledTarballPath, a.SigningKey)
+			return err
+		})
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	a.Logger.Println("writing the compilation report")
+	result.ReportPath, err = a.writeReport(c, compiled, compiledTarballPath, directorInfo.UUID, stemcell, time.Since(start))
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result, nil
+}
+
+// compilation is the state of a Run that its steps share: the result so far
+// and the release, stemcell and deployment that its events describe.
+type compilation struct {
+	result     *Result
+	release    string
+	stemcell   string
+	deployment string
+	events     []Event
+}
+
+func (c *compilation) event(step string) Event {
+	return Event{
+		Step:       step,
+		Release:    c.release,
+		Stemcell:   c.stemcell,
+		Deployment: c.deployment,
+	}
+}
+
+// track runs a step and reports it to the EventLogger, if any.
+func (a Application) track(c *compilation, step string, run func(event *Event) error) error {
+	event := c.event(step)
+	start := time.Now()
+	err := run(&event)
+	a.report(c, event, start, err)
+
+	return err
+}
+
+// report records the finished step for the compilation report and passes it
+// to the EventLogger, if any.
+func (a Application) report(c *compilation, event Event, start time.Time, err error) {
+	event.Duration = time.Since(start)
+	if err != nil {
+		event.Outcome = "failed"
+		event.Error = err.Error()
+	} else if event.Outcome == "" {
+		event.Outcome = "succeeded"
+	}
+
+	c.events = append(c.events, event)
+
+	if a.EventLogger != nil {
+		a.EventLogger.Event(event)
+	}
+}
+
+// perform runs a step that changes the director or the output directory. In a
+// dry run it logs the action and adds it to the plan instead.
+func (a Application) perform(c *compilation, step, action string, run func(event *Event) error) error {
+	if a.DryRun {
+		a.Logger.Printf("would %s\n", action)
+		c.result.Plan = append(c.result.Plan, action)
+
+		return a.track(c, step, func(event *Event) error {
+			event.Outcome = "planned"
+			return nil
+		})
+	}
+
+	return a.track(c, step, run)
+}
+
+func (a Application) download(resourceID, path string) error {
+	fd, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 
 	resource, err := a.BOSHClient.Resource(resourceID)
 	if err != nil {
+		fd.Close()
 		return err
 	}
+	defer resource.Close()
 
 	_, err = io.Copy(fd, resource)
 	if err != nil {
+		fd.Close()
 		return err
 	}
 
-	a.Logger.Println("deleting the deployment")
-	err = a.BOSHClient.DeleteDeployment(deploymentName)
+	return fd.Close()
+}
+
+// writeReport writes report.json next to the compiled release tarball.
+func (a Application) writeReport(c *compilation, manifest ReleaseManifest, compiledTarballPath, directorUUID string, stemcell Stemcell, duration time.Duration) (string, error) {
+	path := filepath.Join(filepath.Dir(compiledTarballPath), "report.json")
+	err := NewReport(manifest, stemcell, directorUUID, c.deployment, c.events, duration).Write(path)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	a.Logger.Println("cleaning up")
-	_, err = a.BOSHClient.Cleanup()
+	return path, nil
+}
+
+func (a Application) deploymentSettings() (DeploymentSettings, error) {
+	configs, err := a.DirectorClient.Configs("cloud")
 	if err != nil {
+		return DeploymentSettings{}, err
+	}
+
+	cloudConfig, err := ParseCloudConfig(configs)
+	if err != nil {
+		return DeploymentSettings{}, err
+	}
+
+	return cloudConfig.Resolve(a.Deployment)
+}
+
+// customizeManifest applies the manifest template and ops files, if any, over
+// the generated manifest.
+func (a Application) customizeManifest(manifest []byte) ([]byte, error) {
+	if a.ManifestTemplatePath == "" && len(a.OpsFilePaths) == 0 {
+		return manifest, nil
+	}
+
+	var template []byte
+	if a.ManifestTemplatePath != "" {
+		var err error
+		template, err = ioutil.ReadFile(a.ManifestTemplatePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var ops []Op
+	for _, path := range a.OpsFilePaths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		fileOps, err := ParseOps(content)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse ops file %q: %s", path, err)
+		}
+
+		ops = append(ops, fileOps...)
+	}
+
+	return CustomizeManifest(manifest, template, ops)
+}
+
+func (a Application) runtimeConfigAddons(manifestYAML []byte) ([]Config, []RuntimeConfigAddon, error) {
+	configs, err := a.DirectorClient.Configs("runtime")
+	if err != nil || len(configs) == 0 {
+		return nil, nil, err
+	}
+
+	var manifest Manifest
+	err = yaml.Unmarshal(manifestYAML, &manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addons, err := ApplyingAddons(configs, manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return configs, addons, nil
+}
+
+// runtimeConfigExclusion is a runtime config as it was before the compile
+// deployment was excluded from it and the content that excluded it.
+type runtimeConfigExclusion struct {
+	Original Config
+	Excluded string
+}
+
+// excludeFromRuntimeConfigs excludes the deployment from the applying addons
+// of each runtime config and returns the exclusions to restore.
+//
+// This changes the director's runtime configs, which every deploy on the
+// director picks up, for as long as the compilation runs. They are restored
+// when it ends, but a process that is killed meanwhile never restores them
+// and they keep excluding the compile deployment until restored by hand.
+// Reporting the addons, without excluding them, is the safe default.
+func (a Application) excludeFromRuntimeConfigs(c *compilation, configs []Config, addons []RuntimeConfigAddon, deploymentName string) ([]runtimeConfigExclusion, error) {
+	var exclusions []runtimeConfigExclusion
+	for _, config := range configs {
+		var names []string
+		for _, addon := range addons {
+			if addon.Config == config.Name {
+				names = append(names, addon.Addon)
+			}
+		}
+
+		if len(names) == 0 {
+			continue
+		}
+
+		content, err := ExcludeDeployment(config.Content, names, deploymentName)
+		if err != nil {
+			return exclusions, fmt.Errorf("could not exclude the compile deployment from runtime config %s: %s", config.Name, err)
+		}
+
+		err = a.perform(c, "exclude_runtime_config", fmt.Sprintf("exclude deployment %s from runtime config %s", deploymentName, config.Name), func(*Event) error {
+			a.Logger.Printf("excluding the compile deployment from runtime config %s\n", config.Name)
+			return a.DirectorClient.UpdateConfig(Config{Name: config.Name, Type: "runtime", Content: content})
+		})
+		if err != nil {
+			return exclusions, err
+		}
+
+		exclusions = append(exclusions, runtimeConfigExclusion{Original: config, Excluded: content})
+	}
+
+	return exclusions, nil
+}
+
+// restoreRuntimeConfigs puts back each runtime config as it was, unless it
+// has been changed on the director since it was excluded from: restoring it
+// then would drop the other change, so it fails and leaves that to be
+// resolved by hand. It restores the others either way and returns the first
+// error.
+func (a Application) restoreRuntimeConfigs(c *compilation, exclusions []runtimeConfigExclusion) error {
+	var restoreErr error
+	for _, exclusion := range exclusions {
+		config := exclusion.Original
+		excluded := exclusion.Excluded
+		err := a.perform(c, "restore_runtime_config", fmt.Sprintf("restore runtime config %s", config.Name), func(*Event) error {
+			current, err := a.DirectorClient.Configs("runtime")
+			if err != nil {
+				return err
+			}
+
+			var unchanged bool
+			for _, currentConfig := range current {
+				if currentConfig.Name == config.Name {
+					unchanged = currentConfig.Content == excluded
+					break
+				}
+			}
+			if !unchanged {
+				return fmt.Errorf("runtime config %s changed on the director while compiling, not restoring it; it was:\n%s", config.Name, config.Content)
+			}
+
+			a.Logger.Printf("restoring runtime config %s\n", config.Name)
+			return a.DirectorClient.UpdateConfig(Config{Name: config.Name, Type: "runtime", Content: config.Content})
+		})
+		if err != nil && restoreErr == nil {
+			restoreErr = err
+		}
+	}
+
+	return restoreErr
+}
+
+func (a Application) release() (Release, error) {
+	if a.ReleaseURL != "" {
+		return NewRemoteRelease(a.ReleaseURL, a.ReleaseSHA1, a.ReleaseManifestPath)
+	}
+
+	return NewRelease(a.ReleaseTarballPath)
+}
+
+func (a Application) stemcell() (Stemcell, error) {
+	if a.StemcellURL != "" {
+		return NewRemoteStemcell(a.StemcellURL, a.StemcellSHA1, a.StemcellManifestPath)
+	}
+
+	return NewStemcell(a.StemcellTarballPath)
+}
+
+func (a Application) uploadStemcell(c *compilation, stemcell Stemcell) (bool, error) {
+	if !a.ForceUpload {
+		existingStemcell, err := a.BOSHClient.Stemcell(stemcell.Name)
+		if err != nil && !isNotFound(err) {
+			return false, err
+		}
+
+		if err == nil && existsInSlice(existingStemcell.Versions, stemcell.Version) {
+			directorStemcells, err := a.DirectorClient.Stemcells()
+			if err != nil {
+				return false, err
+			}
+
+			if sameStemcell(stemcell, directorStemcells) {
+				a.Logger.Printf("stemcell %s %s already uploaded, skipping\n", stemcell.Name, stemcell.Version)
+				return true, a.track(c, "upload_stemcell", func(event *Event) error {
+					event.Outcome = "skipped"
+					return nil
+				})
+			}
+
+			a.Logger.Printf("stemcell %s %s on the director differs, uploading it again\n", stemcell.Name, stemcell.Version)
+		}
+	}
+
+	action := fmt.Sprintf("upload stemcell %s %s", stemcell.Name, stemcell.Version)
+	if stemcell.URL != "" {
+		action = fmt.Sprintf("%s from %s", action, stemcell.URL)
+	}
+
+	err := a.perform(c, "upload_stemcell", action, func(event *Event) error {
+		var err error
+		if stemcell.URL != "" {
+			a.Logger.Printf("uploading stemcell %s %s from %s\n", stemcell.Name, stemcell.Version, stemcell.URL)
+			event.TaskID, err = a.BOSHClient.UploadStemcellURL(stemcell.URL, stemcell.SHA1)
+		} else {
+			a.Logger.Printf("uploading stemcell %s %s\n", stemcell.Name, stemcell.Version)
+			event.TaskID, err = a.BOSHClient.UploadStemcell(stemcell)
+		}
+
 		return err
+	})
+
+	return false, err
+}
+
+// uploadRelease skips the upload when the director already has the release
+// version with the same job and package fingerprints, unless the release was
+// built from uncommitted changes, in which case the same version can carry
+// different packages.
+func (a Application) uploadRelease(c *compilation, release Release) (bool, error) {
+	if !a.ForceUpload && !release.UncommittedChanges {
+		existingRelease, err := a.BOSHClient.Release(release.Name)
+		if err != nil && !isNotFound(err) {
+			return false, err
+		}
+
+		if err == nil && existsInSlice(existingRelease.Versions, release.Version) {
+			directorRelease, err := a.DirectorClient.ReleaseVersion(release.Name, release.Version)
+			if err != nil {
+				return false, err
+			}
+
+			if sameRelease(release, directorRelease) {
+				a.Logger.Printf("release %s %s already uploaded, skipping\n", release.Name, release.Version)
+				return true, a.track(c, "upload_release", func(event *Event) error {
+					event.Outcome = "skipped"
+					return nil
+				})
+			}
+
+			a.Logger.Printf("release %s %s on the director has other jobs or packages, uploading it again\n", release.Name, release.Version)
+		}
+	}
+
+	action := fmt.Sprintf("upload release %s %s", release.Name, release.Version)
+	if release.URL != "" {
+		action = fmt.Sprintf("%s from %s", action, release.URL)
+	}
+
+	err := a.perform(c, "upload_release", action, func(event *Event) error {
+		var err error
+		if release.URL != "" {
+			a.Logger.Printf("uploading release %s %s from %s\n", release.Name, release.Version, release.URL)
+			event.TaskID, err = a.BOSHClient.UploadReleaseURL(release.URL, release.SHA1)
+		} else {
+			a.Logger.Printf("uploading release %s %s\n", release.Name, release.Version)
+			event.TaskID, err = a.BOSHClient.UploadRelease(release)
+		}
+
+		return err
+	})
+
+	return false, err
+}
+
+// sameStemcell reports whether the director has the stemcell version with the
+// same operating system and api_version. The director does not report the
+// sha1 of a stemcell's image, so these are all it can be compared on.
+func sameStemcell(stemcell Stemcell, directorStemcells []DirectorStemcell) bool {
+	for _, directorStemcell := range directorStemcells {
+		if directorStemcell.Version != stemcell.Version {
+			continue
+		}
+
+		if directorStemcell.Name != stemcell.Name && directorStemcell.OperatingSystem != stemcell.Name {
+			continue
+		}
+
+		return directorStemcell.OperatingSystem == stemcell.Name &&
+			apiVersion(directorStemcell.APIVersion) == apiVersion(stemcell.APIVersion)
+	}
+
+	return false
+}
+
+// apiVersion treats a stemcell without an api_version as version 1.
+func apiVersion(version int) int {
+	if version == 0 {
+		return 1
+	}
+
+	return version
+}
+
+// sameRelease reports whether the release version on the director has the
+// jobs and packages of the release, by fingerprint.
+func sameRelease(release Release, directorRelease DirectorRelease) bool {
+	if len(release.Jobs) != len(directorRelease.Jobs) || len(release.Packages) != len(directorRelease.Packages) {
+		return false
+	}
+
+	for _, job := range release.Jobs {
+		if !containsFingerprint(directorRelease.Jobs, job.Name, fingerprintOf(job.Fingerprint, job.Version)) {
+			return false
+		}
+	}
+
+	for _, pkg := range release.Packages {
+		if !containsFingerprint(directorRelease.Packages, pkg.Name, fingerprintOf(pkg.Fingerprint, pkg.Version)) {
+			return false
+		}
 	}
 
-	return nil
+	return true
+}
+
+func containsFingerprint(entries []DirectorReleaseEntry, name, fingerprint string) bool {
+	for _, entry := range entries {
+		if entry.Name == name && fingerprintOf(entry.Fingerprint, entry.Version) == fingerprint {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fingerprintOf falls back to the version, which is the fingerprint of jobs
+// and packages in releases that do not list fingerprints separately.
+func fingerprintOf(fingerprint, version string) string {
+	if fingerprint != "" {
+		return fingerprint
+	}
+
+	return version
+}
+
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "could not be found")
+}
+
+func existsInSlice(slice []string, str string) bool {
+	for _, x := range slice {
+		if x == str {
+			return true
+		}
+	}
+	return false
 }
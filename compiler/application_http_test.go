@@ -0,0 +1,274 @@
+package compiler_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler/fakes"
+	"github.com/aditya87/precompiled-bosh-release-resource/internal/testhelpers"
+	"github.com/pivotal-cf-experimental/bosh-test/bosh"
+	"gopkg.in/yaml.v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Application against a director", func() {
+	var (
+		director        *fakes.Director
+		app             compiler.Application
+		compiledTempDir string
+		tempDir         string
+	)
+
+	newApplication := func(password string) compiler.Application {
+		config := bosh.Config{
+			URL:                 director.URL(),
+			Username:            "some-user",
+			Password:            password,
+			TaskPollingInterval: time.Millisecond,
+		}
+
+		return compiler.Application{
+			ReleaseTarballPath:  filepath.Join(tempDir, "some-release-42.tgz"),
+			StemcellTarballPath: filepath.Join(tempDir, "some-stemcell-1.2.3.tgz"),
+			OutputDirectory:     compiledTempDir,
+			BOSHClient:          compiler.NewBOSHClient(config),
+			DirectorClient:      compiler.NewDirectorClient(config),
+			ManifestGenerator:   compiler.NewManifestGenerator(),
+			GUIDGenerator:       func() (string, error) { return "some-guid", nil },
+			Logger:              &fakes.Logger{},
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		compiledTempDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		tempDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = createReleaseTarball(filepath.Join(tempDir, "some-release-42.tgz"), bytes.NewBuffer([]byte(`---
+name: some-release
+version: 42
+packages:
+- name: some-package
+  version: some-fingerprint
+  fingerprint: some-fingerprint
+  sha1: some-source-sha1
+`)))
+		Expect(err).NotTo(HaveOccurred())
+
+		err = createStemcellTarball(filepath.Join(tempDir, "some-stemcell-1.2.3.tgz"), bytes.NewBuffer([]byte(`---
+operating_system: some-stemcell
+version: 1.2.3
+`)))
+		Expect(err).NotTo(HaveOccurred())
+
+		director = fakes.NewDirector("some-user", "some-password")
+		director.ExportedRelease = testhelpers.Tarball(map[string]string{
+			"release.MF": `---
+name: some-release
+version: "42"
+compiled_packages:
+- name: some-package
+  version: some-fingerprint
+  fingerprint: some-fingerprint
+  sha1: some-sha1
+  stemcell: some-stemcell/1.2.3
+`,
+			"compiled_packages/some-package.tgz": "some-compiled-package",
+		})
+
+		app = newApplication("some-password")
+	})
+
+	AfterEach(func() {
+		director.Close()
+
+		err := os.RemoveAll(compiledTempDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.RemoveAll(tempDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("compiles the release over the director api", func() {
+		director.AddDeployment("some-old-deployment", []byte("name: some-old-deployment"))
+
+		result, err := app.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		compiledRelease, err := ioutil.ReadFile(result.CompiledReleasePath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(compiledRelease).To(Equal(director.ExportedRelease))
+		Expect(result.ReportPath).To(BeAnExistingFile())
+
+		Expect(director.Stemcells()).To(Equal([]fakes.DirectorStemcell{
+			{Name: "some-stemcell", OS: "some-stemcell", Version: "1.2.3"},
+		}))
+		Expect(director.Releases()).To(Equal(map[string][]string{"some-release": {"42"}}))
+		Expect(director.Deployments()).To(BeEmpty())
+
+		Expect(director.Requests()).To(Equal([]string{
+			"GET /info",
+			"GET /deployments",
+			"DELETE /deployments/some-old-deployment",
+			"GET /tasks/1",
+			"POST /cleanup",
+			"GET /tasks/2",
+			"GET /configs",
+			"GET /stemcells",
+			"POST /stemcells",
+			"GET /tasks/3",
+			"GET /releases/some-release",
+			"POST /releases",
+			"GET /tasks/4",
+			"GET /configs",
+			"POST /deployments",
+			"GET /tasks/5",
+			"POST /releases/export",
+			"GET /tasks/6",
+			"GET /tasks/6/output",
+			"GET /resources/exported-release-1",
+			"DELETE /deployments/compile-release-some-guid",
+			"GET /tasks/7",
+			"POST /cleanup",
+			"GET /tasks/8",
+		}))
+	})
+
+	It("deploys a manifest for the director", func() {
+		_, err := app.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(director.DeployedManifests()).To(HaveLen(1))
+
+		var manifest compiler.Manifest
+		err = yaml.Unmarshal(director.DeployedManifests()[0], &manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Name).To(Equal("compile-release-some-guid"))
+		Expect(manifest.DirectorUUID).To(Equal("fake-director-uuid"))
+		Expect(manifest.Releases).To(Equal([]compiler.ManifestRelease{{Name: "some-release", Version: "42"}}))
+	})
+
+	It("polls tasks until they finish", func() {
+		director.ScriptTask("deploy", fakes.TaskScript{States: []string{"queued", "processing", "processing", "done"}})
+
+		_, err := app.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		requests := director.Requests()
+		polls := 0
+		for i, request := range requests {
+			if request == "POST /deployments" {
+				for _, poll := range requests[i+1:] {
+					if poll != "GET /tasks/4" {
+						break
+					}
+					polls++
+				}
+			}
+		}
+		Expect(polls).To(Equal(4))
+	})
+
+	It("skips uploading the stemcell and release the director already has", func() {
+		director.AddStemcell("some-stemcell", "some-stemcell", "1.2.3")
+		director.AddReleaseVersion("some-release", "42", compiler.DirectorRelease{
+			Packages: []compiler.DirectorReleaseEntry{
+				{Name: "some-package", Version: "some-fingerprint", Fingerprint: "some-fingerprint", SHA1: "some-source-sha1"},
+			},
+		})
+
+		result, err := app.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(result.SkippedUploads).To(Equal([]string{"stemcell some-stemcell 1.2.3", "release some-release 42"}))
+		Expect(director.Requests()).NotTo(ContainElement("POST /stemcells"))
+		Expect(director.Requests()).NotTo(ContainElement("POST /releases"))
+	})
+
+	It("uploads a release version that the director has with other packages", func() {
+		director.AddReleaseVersion("some-release", "42", compiler.DirectorRelease{
+			Packages: []compiler.DirectorReleaseEntry{
+				{Name: "some-package", Version: "other-fingerprint", Fingerprint: "other-fingerprint", SHA1: "other-sha1"},
+			},
+		})
+
+		result, err := app.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(result.SkippedUploads).To(BeEmpty())
+		Expect(director.Requests()).To(ContainElement("POST /releases"))
+	})
+
+	It("places the deployment on the director's cloud config", func() {
+		director.AddConfig(compiler.Config{Name: "default", Type: "cloud", Content: `---
+vm_types: [{name: some-vm-type}]
+networks: [{name: some-network}]
+azs: [{name: z1}]
+compilation: {workers: 2, az: z1, vm_type: some-vm-type, network: some-network}
+`})
+
+		_, err := app.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		var manifest compiler.Manifest
+		err = yaml.Unmarshal(director.DeployedManifests()[0], &manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.InstanceGroups).To(HaveLen(1))
+		Expect(manifest.InstanceGroups[0].VMType).To(Equal("some-vm-type"))
+		Expect(manifest.InstanceGroups[0].Networks).To(Equal([]compiler.InstanceGroupNetwork{{Name: "some-network"}}))
+	})
+
+	Context("when a task fails", func() {
+		It("returns the error of the task", func() {
+			director.ScriptTask("deploy", fakes.TaskScript{
+				States: []string{"processing", "error"},
+				Error:  "some compilation error",
+			})
+
+			_, err := app.Run()
+			Expect(err).To(MatchError(ContainSubstring("some compilation error")))
+		})
+	})
+
+	Context("when the credentials are wrong", func() {
+		It("returns an error", func() {
+			app = newApplication("some-wrong-password")
+
+			_, err := app.Run()
+			Expect(err).To(HaveOccurred())
+			Expect(director.Requests()).To(Equal([]string{"GET /info", "GET /deployments"}))
+		})
+	})
+
+	Context("when the runtime config addons are excluded", func() {
+		It("restores the runtime config after deploying", func() {
+			runtimeConfig := `addons:
+- name: some-addon
+  jobs: []
+`
+			director.AddConfig(compiler.Config{Name: "default", Type: "runtime", Content: runtimeConfig})
+			app.ExcludeRuntimeConfigAddons = true
+
+			_, err := app.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			updates := 0
+			for _, request := range director.Requests() {
+				if request == "POST /configs" {
+					updates++
+				}
+			}
+			Expect(updates).To(Equal(2))
+			Expect(director.Configs()[0].Content).To(Equal(runtimeConfig))
+		})
+	})
+})
@@ -2,15 +2,20 @@ package compiler_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler/fakes"
+	"github.com/aditya87/precompiled-bosh-release-resource/internal/testhelpers"
 	"github.com/pivotal-cf-experimental/bosh-test/bosh"
-	"github.com/pivotal-cf/pcf-releng-ci/tasks/future/compile-release/compiler"
-	"github.com/pivotal-cf/pcf-releng-ci/tasks/future/compile-release/compiler/fakes"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -19,6 +24,7 @@ import (
 var _ = Describe("Application", func() {
 	var (
 		boshClient          *fakes.BOSHClient
+		directorClient      *fakes.DirectorClient
 		manifestGenerator   *fakes.ManifestGenerator
 		logger              *fakes.Logger
 		app                 compiler.Application
@@ -26,6 +32,8 @@ var _ = Describe("Application", func() {
 		tempDir             string
 		releaseTarballPath  string
 		stemcellTarballPath string
+		releaseManifest     string
+		compiledRelease     []byte
 	)
 
 	BeforeEach(func() {
@@ -37,10 +45,21 @@ var _ = Describe("Application", func() {
 		Expect(err).NotTo(HaveOccurred())
 
 		releaseTarballPath = filepath.Join(tempDir, "some-release-42.tgz")
-		err = createReleaseTarball(releaseTarballPath, bytes.NewBuffer([]byte(`---
+		releaseManifest = `---
 name: some-release
 version: 42
-`)))
+packages:
+- name: some-package
+  version: some-package-fingerprint
+  fingerprint: some-package-fingerprint
+  sha1: some-package-source-sha1
+  dependencies: [other-package]
+- name: other-package
+  version: other-package-fingerprint
+  fingerprint: other-package-fingerprint
+  sha1: other-package-source-sha1
+`
+		err = createReleaseTarball(releaseTarballPath, bytes.NewBufferString(releaseManifest))
 		Expect(err).NotTo(HaveOccurred())
 
 		stemcellTarballPath = filepath.Join(tempDir, "some-stemcell-1.2.3.tgz")
@@ -51,6 +70,16 @@ version: 1.2.3
 		Expect(err).NotTo(HaveOccurred())
 
 		boshClient = &fakes.BOSHClient{}
+		directorClient = &fakes.DirectorClient{}
+		directorClient.StemcellsCall.Returns.Stemcells = []compiler.DirectorStemcell{
+			{Name: "some-stemcell", OperatingSystem: "some-stemcell", Version: "1.2.3"},
+		}
+		directorClient.ReleaseVersionCall.Returns.Release = compiler.DirectorRelease{
+			Packages: []compiler.DirectorReleaseEntry{
+				{Name: "some-package", Version: "some-package-fingerprint", Fingerprint: "some-package-fingerprint"},
+				{Name: "other-package", Version: "other-package-fingerprint", Fingerprint: "other-package-fingerprint"},
+			},
+		}
 		manifestGenerator = &fakes.ManifestGenerator{}
 		logger = &fakes.Logger{}
 
@@ -59,6 +88,7 @@ version: 1.2.3
 			StemcellTarballPath: stemcellTarballPath,
 			OutputDirectory:     compiledTempDir,
 			BOSHClient:          boshClient,
+			DirectorClient:      directorClient,
 			ManifestGenerator:   manifestGenerator,
 			GUIDGenerator:       func() (string, error) { return "some-guid", nil },
 			Logger:              logger,
@@ -75,12 +105,33 @@ version: 1.2.3
 
 	Describe("Run", func() {
 		BeforeEach(func() {
-			boshClient.InfoCall.Returns.DirectorInfo = bosh.DirectorInfo{
+			directorClient.InfoCall.Returns.DirectorInfo = compiler.DirectorInfo{
 				UUID: "some-director-uuid",
 			}
 			manifestGenerator.GenerateCall.Returns.Manifest = []byte("deployment-manifest")
 			boshClient.ExportReleaseCall.Returns.ResourceID = "some-resource-guid"
-			boshClient.ResourceCall.Returns.Resource = ioutil.NopCloser(strings.NewReader("compiled-release-contents"))
+			compiledRelease = testhelpers.Tarball(map[string]string{
+				"release.MF": `---
+name: some-release
+version: "42"
+commit_hash: abc1234
+compiled_packages:
+- name: some-package
+  version: some-package-fingerprint
+  fingerprint: some-package-fingerprint
+  sha1: some-package-sha1
+  stemcell: some-stemcell/1.2.3
+  dependencies: [other-package]
+- name: other-package
+  version: other-package-fingerprint
+  fingerprint: other-package-fingerprint
+  sha1: other-package-sha1
+  stemcell: some-stemcell/1.2.3
+`,
+				"compiled_packages/some-package.tgz":  "some-compiled-package",
+				"compiled_packages/other-package.tgz": "other-compiled-package-contents",
+			})
+			boshClient.ResourceCall.Returns.Resource = ioutil.NopCloser(bytes.NewReader(compiledRelease))
 		})
 
 		It("deletes any pre-existing deployments", func() {
@@ -88,16 +139,37 @@ version: 1.2.3
 				{Name: "dep1"},
 				{Name: "dep2"},
 			}
-			err := app.Run()
+			_, err := app.Run()
 			Expect(err).NotTo(HaveOccurred())
 			Expect(boshClient.DeploymentsCall.CallCount).To(Equal(1))
 			Expect(len(boshClient.DeleteDeploymentCall.Receives.Name)).To(Equal(3))
 			Expect(boshClient.DeleteDeploymentCall.Receives.Name[0]).To(Equal("dep1"))
 			Expect(boshClient.DeleteDeploymentCall.Receives.Name[1]).To(Equal("dep2"))
+			Expect(boshClient.Calls).To(fakes.HaveCalledBefore("DeleteDeployment", "Cleanup"))
+		})
+
+		It("talks to the bosh director in order", func() {
+			_, err := app.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(boshClient.Calls).To(fakes.HaveCalledInOrder(
+				"Deployments",
+				"Cleanup",
+				"Stemcell",
+				"UploadStemcell",
+				"Release",
+				"UploadRelease",
+				"Deploy",
+				"ExportRelease",
+				"Resource",
+				"DeleteDeployment",
+				"Cleanup",
+			))
+			Expect(boshClient.Calls).NotTo(fakes.HaveCalledInOrder("Deploy", "UploadRelease"))
 		})
 
 		It("uploads the stemcell to the bosh director", func() {
-			err := app.Run()
+			_, err := app.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(boshClient.UploadStemcellCall.Receives.Contents).NotTo(BeNil())
@@ -111,7 +183,7 @@ version: 1.2.3
 		})
 
 		It("uploads the release to the bosh director", func() {
-			err := app.Run()
+			_, err := app.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(boshClient.UploadReleaseCall.Receives.Contents).NotTo(BeNil())
@@ -124,8 +196,204 @@ version: 1.2.3
 			Expect(actualContents).To(Equal(expectedContents))
 		})
 
+		It("returns the path of the compiled release", func() {
+			result, err := app.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.CompiledReleasePath).To(Equal(filepath.Join(compiledTempDir, "some-release-42.0.0-1.2.3.tgz")))
+			Expect(result.SkippedUploads).To(BeEmpty())
+		})
+
+		It("returns the commit hash of the release", func() {
+			err := createReleaseTarball(releaseTarballPath, bytes.NewBufferString(releaseManifest+"commit_hash: abc1234\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := app.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.CommitHash).To(Equal("abc1234"))
+		})
+
+		Context("when the director already has the stemcell and release", func() {
+			BeforeEach(func() {
+				boshClient.StemcellCall.Returns.Stemcell = bosh.Stemcell{
+					Name:     "some-stemcell",
+					Versions: []string{"1.2.2", "1.2.3"},
+				}
+				boshClient.ReleaseCall.Returns.Release = bosh.Release{
+					Name:     "some-release",
+					Versions: []string{"42"},
+				}
+			})
+
+			It("skips the uploads and reports them in the result", func() {
+				result, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(boshClient.StemcellCall.Receives).To(Equal("some-stemcell"))
+				Expect(boshClient.ReleaseCall.Receives).To(Equal("some-release"))
+				Expect(boshClient.UploadStemcellCall.CallCount).To(Equal(0))
+				Expect(boshClient.UploadReleaseCall.Receives.Contents).To(BeNil())
+				Expect(result.SkippedUploads).To(Equal([]string{
+					"stemcell some-stemcell 1.2.3",
+					"release some-release 42",
+				}))
+				Expect(logger.Lines).To(ContainElement("stemcell some-stemcell 1.2.3 already uploaded, skipping\n"))
+				Expect(logger.Lines).To(ContainElement("release some-release 42 already uploaded, skipping\n"))
+				Expect(directorClient.ReleaseVersionCall.Receives.Name).To(Equal("some-release"))
+				Expect(directorClient.ReleaseVersionCall.Receives.Version).To(Equal("42"))
+			})
+
+			Context("when the release version on the director has other packages", func() {
+				It("uploads the release again", func() {
+					directorClient.ReleaseVersionCall.Returns.Release.Packages[0].Fingerprint = "rebuilt-package-fingerprint"
+
+					result, err := app.Run()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(boshClient.UploadReleaseCall.Receives.Contents).NotTo(BeNil())
+					Expect(result.SkippedUploads).To(Equal([]string{"stemcell some-stemcell 1.2.3"}))
+					Expect(logger.Lines).To(ContainElement("release some-release 42 on the director has other jobs or packages, uploading it again\n"))
+				})
+
+				It("uploads the release again when a package is missing", func() {
+					directorClient.ReleaseVersionCall.Returns.Release.Packages = directorClient.ReleaseVersionCall.Returns.Release.Packages[:1]
+
+					_, err := app.Run()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(boshClient.UploadReleaseCall.Receives.Contents).NotTo(BeNil())
+				})
+			})
+
+			Context("when the stemcell version on the director is another stemcell", func() {
+				It("uploads the stemcell again", func() {
+					directorClient.StemcellsCall.Returns.Stemcells[0].APIVersion = 2
+
+					result, err := app.Run()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(boshClient.UploadStemcellCall.CallCount).To(Equal(1))
+					Expect(result.SkippedUploads).To(Equal([]string{"release some-release 42"}))
+					Expect(logger.Lines).To(ContainElement("stemcell some-stemcell 1.2.3 on the director differs, uploading it again\n"))
+				})
+			})
+
+			It("returns an error when the release version on the director cannot be fetched", func() {
+				directorClient.ReleaseVersionCall.Returns.Error = errors.New("failed to fetch release version")
+
+				_, err := app.Run()
+				Expect(err).To(MatchError("failed to fetch release version"))
+			})
+
+			Context("when the upload is forced", func() {
+				It("uploads the stemcell and release anyway", func() {
+					app.ForceUpload = true
+
+					result, err := app.Run()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(boshClient.StemcellCall.CallCount).To(Equal(0))
+					Expect(boshClient.ReleaseCall.CallCount).To(Equal(0))
+					Expect(boshClient.UploadStemcellCall.CallCount).To(Equal(1))
+					Expect(boshClient.UploadReleaseCall.Receives.Contents).NotTo(BeNil())
+					Expect(result.SkippedUploads).To(BeEmpty())
+				})
+			})
+
+			Context("when the release has uncommitted changes", func() {
+				It("uploads the release anyway", func() {
+					err := createReleaseTarball(releaseTarballPath, bytes.NewBufferString(releaseManifest+"uncommitted_changes: true\n"))
+					Expect(err).NotTo(HaveOccurred())
+
+					app.AllowDirty = true
+
+					result, err := app.Run()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(boshClient.ReleaseCall.CallCount).To(Equal(0))
+					Expect(boshClient.UploadReleaseCall.Receives.Contents).NotTo(BeNil())
+					Expect(result.SkippedUploads).To(Equal([]string{"stemcell some-stemcell 1.2.3"}))
+				})
+			})
+		})
+
+		Context("when the director has other versions of the stemcell and release", func() {
+			It("uploads the stemcell and release", func() {
+				boshClient.StemcellCall.Returns.Stemcell = bosh.Stemcell{
+					Name:     "some-stemcell",
+					Versions: []string{"1.2.2"},
+				}
+				boshClient.ReleaseCall.Returns.Error = errors.New("release some-release could not be found")
+
+				_, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(boshClient.UploadStemcellCall.CallCount).To(Equal(1))
+				Expect(boshClient.UploadReleaseCall.Receives.Contents).NotTo(BeNil())
+			})
+		})
+
+		Context("when the stemcell and release are given by url", func() {
+			var server *httptest.Server
+
+			BeforeEach(func() {
+				server = httptest.NewServer(http.FileServer(http.Dir(tempDir)))
+
+				app.ReleaseTarballPath = ""
+				app.ReleaseURL = server.URL + "/some-release-42.tgz"
+				app.ReleaseSHA1 = "some-release-sha1"
+				app.StemcellTarballPath = ""
+				app.StemcellURL = server.URL + "/some-stemcell-1.2.3.tgz"
+				app.StemcellSHA1 = "some-stemcell-sha1"
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("has the director fetch the stemcell and release itself", func() {
+				_, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(boshClient.UploadStemcellCall.CallCount).To(Equal(0))
+				Expect(boshClient.UploadStemcellURLCall.Receives.URL).To(Equal(server.URL + "/some-stemcell-1.2.3.tgz"))
+				Expect(boshClient.UploadStemcellURLCall.Receives.SHA1).To(Equal("some-stemcell-sha1"))
+
+				Expect(boshClient.UploadReleaseCall.Receives.Contents).To(BeNil())
+				Expect(boshClient.UploadReleaseURLCall.Receives.URL).To(Equal(server.URL + "/some-release-42.tgz"))
+				Expect(boshClient.UploadReleaseURLCall.Receives.SHA1).To(Equal("some-release-sha1"))
+			})
+
+			It("exports the release using the remote metadata", func() {
+				_, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(boshClient.ExportReleaseCall.Receives.ReleaseName).To(Equal("some-release"))
+				Expect(boshClient.ExportReleaseCall.Receives.StemcellName).To(Equal("some-stemcell"))
+			})
+
+			Context("when the bosh client cannot upload the stemcell by url", func() {
+				It("returns an error", func() {
+					boshClient.UploadStemcellURLCall.Returns.Error = errors.New("failed to upload stemcell")
+
+					_, err := app.Run()
+					Expect(err).To(MatchError("failed to upload stemcell"))
+				})
+			})
+
+			Context("when the bosh client cannot upload the release by url", func() {
+				It("returns an error", func() {
+					boshClient.UploadReleaseURLCall.Returns.Error = errors.New("failed to upload release")
+
+					_, err := app.Run()
+					Expect(err).To(MatchError("failed to upload release"))
+				})
+			})
+		})
+
 		It("generates a deployment manifest", func() {
-			err := app.Run()
+			_, err := app.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(manifestGenerator.GenerateCall.Receives.DirectorUUID).To(Equal("some-director-uuid"))
@@ -134,15 +402,251 @@ version: 1.2.3
 			Expect(manifestGenerator.GenerateCall.Receives.Stemcell.Name).To(Equal("some-stemcell"))
 		})
 
+		Context("when the director has a cloud config", func() {
+			BeforeEach(func() {
+				directorClient.ConfigsCall.Returns.Configs = map[string][]compiler.Config{
+					"cloud": {{Name: "default", Type: "cloud", Content: `---
+azs:
+- name: z1
+vm_types:
+- name: default
+- name: large
+networks:
+- name: default
+compilation:
+  workers: 2
+  az: z1
+  vm_type: default
+  network: default
+`}},
+				}
+			})
+
+			It("places the deployment with the settings resolved against it", func() {
+				app.Deployment = compiler.DeploymentSettings{VMType: "large"}
+
+				_, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(directorClient.CallsTo("Configs")).To(ContainElement(fakes.Call{Method: "Configs", Args: []interface{}{"cloud"}}))
+				Expect(manifestGenerator.GenerateCall.Receives.Settings).To(Equal(compiler.DeploymentSettings{
+					VMType:  "large",
+					Network: "default",
+					AZs:     []string{"z1"},
+				}))
+			})
+
+			It("returns an error before uploading when the settings do not match it", func() {
+				app.Deployment = compiler.DeploymentSettings{Network: "public"}
+
+				_, err := app.Run()
+				Expect(err).To(MatchError(`network "public" is not defined in the cloud config`))
+				Expect(boshClient.UploadStemcellCall.CallCount).To(Equal(0))
+			})
+		})
+
+		Context("when a manifest template and ops files are given", func() {
+			BeforeEach(func() {
+				manifestGenerator.GenerateCall.Returns.Manifest = []byte("name: compile-release-some-guid\n")
+
+				err := ioutil.WriteFile(filepath.Join(tempDir, "template.yml"), []byte("features: {use_dns_addresses: false}\ntags: {team: compile}\n"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = ioutil.WriteFile(filepath.Join(tempDir, "ops.yml"), []byte("[{type: replace, path: /tags/team, value: releng}]\n"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				app.ManifestTemplatePath = filepath.Join(tempDir, "template.yml")
+				app.OpsFilePaths = []string{filepath.Join(tempDir, "ops.yml")}
+			})
+
+			It("deploys the customized manifest", func() {
+				_, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(boshClient.DeployCall.Receives.Manifest).To(MatchYAML(`---
+name: compile-release-some-guid
+features:
+  use_dns_addresses: false
+tags:
+  team: releng
+`))
+			})
+
+			It("returns an error when an ops file cannot be parsed", func() {
+				err := ioutil.WriteFile(filepath.Join(tempDir, "ops.yml"), []byte("[{type: copy, path: /name}]\n"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = app.Run()
+				Expect(err).To(MatchError(fmt.Sprintf(`could not parse ops file %q: unknown op type "copy" for path "/name", expected replace or remove`, filepath.Join(tempDir, "ops.yml"))))
+				Expect(boshClient.DeployCall.Receives.Manifest).To(BeNil())
+			})
+		})
+
+		Context("when runtime config addons apply to the compile deployment", func() {
+			var runtimeConfig string
+
+			BeforeEach(func() {
+				manifestGenerator.GenerateCall.Returns.Manifest = []byte("name: compile-release-some-guid\n")
+
+				runtimeConfig = `---
+addons:
+- name: some-agent
+  jobs: [{name: agent, release: some-agent}]
+- name: elsewhere
+  jobs: [{name: agent, release: some-agent}]
+  include:
+    deployments: [other-deployment]
+`
+				directorClient.ConfigsCall.Returns.Configs = map[string][]compiler.Config{
+					"runtime": {{Name: "default", Type: "runtime", Content: runtimeConfig}},
+				}
+			})
+
+			It("reports them without changing the runtime config", func() {
+				result, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(directorClient.CallsTo("Configs")).To(Equal([]fakes.Call{
+					{Method: "Configs", Args: []interface{}{"cloud"}},
+					{Method: "Configs", Args: []interface{}{"runtime"}},
+				}))
+				Expect(result.RuntimeConfigAddons).To(Equal([]string{"default/some-agent"}))
+				Expect(logger.Lines).To(ContainElement("runtime config default colocates addon some-agent on the compile deployment\n"))
+				Expect(directorClient.CallsTo("UpdateConfig")).To(BeEmpty())
+			})
+
+			Context("when they are to be excluded", func() {
+				BeforeEach(func() {
+					app.ExcludeRuntimeConfigAddons = true
+				})
+
+				It("excludes the compile deployment while it deploys and restores the runtime config", func() {
+					_, err := app.Run()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(directorClient.Calls).To(fakes.HaveCalledInOrder("Configs", "UpdateConfig", "Configs", "UpdateConfig"))
+					updates := directorClient.CallsTo("UpdateConfig")
+					Expect(updates).To(HaveLen(2))
+
+					excluded := updates[0].Args[0].(compiler.Config)
+					Expect(excluded.Name).To(Equal("default"))
+					Expect(excluded.Type).To(Equal("runtime"))
+					Expect(excluded.Content).To(MatchYAML(`---
+addons:
+- name: some-agent
+  jobs: [{name: agent, release: some-agent}]
+  exclude:
+    deployments: [compile-release-some-guid]
+- name: elsewhere
+  jobs: [{name: agent, release: some-agent}]
+  include:
+    deployments: [other-deployment]
+`))
+
+					Expect(updates[1].Args).To(Equal([]interface{}{compiler.Config{
+						Name:    "default",
+						Type:    "runtime",
+						Content: runtimeConfig,
+					}}))
+				})
+
+				It("plans the exclusion and restoration in a dry run", func() {
+					app.DryRun = true
+
+					result, err := app.Run()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(directorClient.CallsTo("UpdateConfig")).To(BeEmpty())
+					Expect(result.Plan).To(ContainElement("exclude deployment compile-release-some-guid from runtime config default"))
+					Expect(result.Plan[len(result.Plan)-1]).To(Equal("restore runtime config default"))
+				})
+
+				It("restores the runtime config when the deploy fails", func() {
+					boshClient.DeployCall.Returns.Error = errors.New("failed to deploy")
+
+					_, err := app.Run()
+					Expect(err).To(MatchError("failed to deploy"))
+
+					Expect(directorClient.Calls).To(fakes.HaveCalledInOrder("UpdateConfig", "Configs", "UpdateConfig"))
+					Expect(directorClient.CallsTo("UpdateConfig")).To(HaveLen(2))
+					Expect(directorClient.UpdateConfigCall.Receives.Config.Content).To(Equal(runtimeConfig))
+				})
+
+				It("re-reads the runtime config before it restores it", func() {
+					_, err := app.Run()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(directorClient.CallsTo("Configs")).To(Equal([]fakes.Call{
+						{Method: "Configs", Args: []interface{}{"cloud"}},
+						{Method: "Configs", Args: []interface{}{"runtime"}},
+						{Method: "Configs", Args: []interface{}{"runtime"}},
+					}))
+					Expect(directorClient.Calls).To(fakes.HaveCalledInOrder("UpdateConfig", "Configs", "UpdateConfig"))
+				})
+
+				It("does not restore a runtime config that changed while compiling", func() {
+					directorClient.ConfigsCall.ReturnsOnCall = map[int]fakes.ConfigsReturns{
+						2: {Configs: map[string][]compiler.Config{
+							"runtime": {{Name: "default", Type: "runtime", Content: "addons: []\n"}},
+						}},
+					}
+
+					_, err := app.Run()
+					Expect(err).To(MatchError(ContainSubstring("runtime config default changed on the director while compiling, not restoring it")))
+
+					Expect(directorClient.CallsTo("UpdateConfig")).To(HaveLen(1))
+				})
+
+				It("does not restore a runtime config that was deleted while compiling", func() {
+					directorClient.ConfigsCall.ReturnsOnCall = map[int]fakes.ConfigsReturns{
+						2: {Configs: map[string][]compiler.Config{}},
+					}
+
+					_, err := app.Run()
+					Expect(err).To(MatchError(ContainSubstring("runtime config default changed on the director while compiling, not restoring it")))
+
+					Expect(directorClient.CallsTo("UpdateConfig")).To(HaveLen(1))
+				})
+
+				It("restores the runtime configs already excluded from when excluding from another fails", func() {
+					directorClient.ConfigsCall.Returns.Configs["runtime"] = append(directorClient.ConfigsCall.Returns.Configs["runtime"],
+						compiler.Config{Name: "other", Type: "runtime", Content: runtimeConfig})
+					directorClient.UpdateConfigCall.ReturnsOnCall = map[int]fakes.UpdateConfigReturns{
+						1: {Error: errors.New("failed to update config")},
+					}
+
+					_, err := app.Run()
+					Expect(err).To(MatchError("failed to update config"))
+					Expect(boshClient.DeployCall.Receives.Manifest).To(BeNil())
+
+					Expect(directorClient.CallsTo("UpdateConfig")).To(HaveLen(3))
+					Expect(directorClient.Calls).To(fakes.HaveCalledInOrder("UpdateConfig", "UpdateConfig", "Configs", "UpdateConfig"))
+					Expect(directorClient.UpdateConfigCall.Receives.Config).To(Equal(compiler.Config{
+						Name:    "default",
+						Type:    "runtime",
+						Content: runtimeConfig,
+					}))
+				})
+
+				It("returns an error when the runtime config cannot be updated", func() {
+					directorClient.UpdateConfigCall.Returns.Error = errors.New("failed to update config")
+
+					_, err := app.Run()
+					Expect(err).To(MatchError("failed to update config"))
+					Expect(boshClient.DeployCall.Receives.Manifest).To(BeNil())
+				})
+			})
+		})
+
 		It("deploys the manifest", func() {
-			err := app.Run()
+			_, err := app.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(boshClient.DeployCall.Receives.Manifest).To(Equal([]byte("deployment-manifest")))
 		})
 
 		It("exports the release", func() {
-			err := app.Run()
+			_, err := app.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(boshClient.ExportReleaseCall.Receives.DeploymentName).To(Equal("compile-release-some-guid"))
@@ -153,64 +657,424 @@ version: 1.2.3
 		})
 
 		It("downloads the compiled release", func() {
-			err := app.Run()
+			_, err := app.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(boshClient.ResourceCall.Receives.ResourceID).To(Equal("some-resource-guid"))
 		})
 
 		It("writes the compiled release out to the given path", func() {
-			err := app.Run()
+			_, err := app.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			compiledReleaseContents, err := ioutil.ReadFile(filepath.Join(compiledTempDir, "some-release-42.0.0-1.2.3.tgz"))
 			Expect(err).NotTo(HaveOccurred())
-			Expect(compiledReleaseContents).To(Equal([]byte("compiled-release-contents")))
+			Expect(compiledReleaseContents).To(Equal(compiledRelease))
+		})
+
+		It("replaces a longer file that is already at the given path", func() {
+			path := filepath.Join(compiledTempDir, "some-release-42.0.0-1.2.3.tgz")
+			err := ioutil.WriteFile(path, append(append([]byte{}, compiledRelease...), "trailing-bytes"...), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = app.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			compiledReleaseContents, err := ioutil.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(compiledReleaseContents).To(Equal(compiledRelease))
+		})
+
+		It("closes the downloaded resource", func() {
+			resourcePath := filepath.Join(compiledTempDir, "resource.tgz")
+			err := ioutil.WriteFile(resourcePath, compiledRelease, 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			resource, err := os.Open(resourcePath)
+			Expect(err).NotTo(HaveOccurred())
+			boshClient.ResourceCall.Returns.Resource = resource
+
+			_, err = app.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = resource.Read(make([]byte, 1))
+			Expect(err).To(MatchError(os.ErrClosed))
+		})
+
+		It("writes a report of the compilation next to the compiled release", func() {
+			boshClient.DeployCall.Returns.TaskID = 7
+			directorClient.InfoCall.Returns.DirectorInfo.UUID = "some-director-uuid"
+
+			result, err := app.Run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ReportPath).To(Equal(filepath.Join(compiledTempDir, "report.json")))
+
+			content, err := ioutil.ReadFile(result.ReportPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			var report compiler.Report
+			err = json.Unmarshal(content, &report)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Release).To(Equal("some-release"))
+			Expect(report.Version).To(Equal("42"))
+			Expect(report.CommitHash).To(Equal("abc1234"))
+			Expect(report.Stemcell).To(Equal(compiler.ReportStemcell{OS: "some-stemcell", Version: "1.2.3"}))
+			Expect(report.DirectorUUID).To(Equal("some-director-uuid"))
+			Expect(report.Deployment).To(Equal("compile-release-some-guid"))
+			Expect(report.Packages).To(Equal([]compiler.ReportPackage{
+				{
+					Name:         "some-package",
+					Version:      "some-package-fingerprint",
+					Fingerprint:  "some-package-fingerprint",
+					SHA1:         "some-package-sha1",
+					Stemcell:     "some-stemcell/1.2.3",
+					Dependencies: []string{"other-package"},
+					Size:         int64(len("some-compiled-package")),
+				},
+				{
+					Name:         "other-package",
+					Version:      "other-package-fingerprint",
+					Fingerprint:  "other-package-fingerprint",
+					SHA1:         "other-package-sha1",
+					Stemcell:     "some-stemcell/1.2.3",
+					Dependencies: []string{},
+					Size:         int64(len("other-compiled-package-contents")),
+				},
+			}))
+
+			var steps []string
+			for _, step := range report.Steps {
+				steps = append(steps, step.Step)
+				Expect(step.Outcome).To(Equal("succeeded"))
+				if step.Step == "deploy" {
+					Expect(step.TaskID).To(Equal(7))
+				}
+			}
+			Expect(steps).To(ContainElement("export_release"))
+			Expect(steps[len(steps)-1]).To(Equal("sbom"))
+			Expect(report.Duration).To(BeNumerically(">=", 0))
+		})
+
+		It("writes an sbom of the compiled release next to it", func() {
+			result, err := app.Run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.SBOMPath).To(Equal(filepath.Join(compiledTempDir, "some-release-42.0.0-1.2.3.cdx.json")))
+
+			content, err := ioutil.ReadFile(result.SBOMPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			var sbom compiler.SBOM
+			err = json.Unmarshal(content, &sbom)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sbom.Metadata.Component.Name).To(Equal("some-release"))
+			Expect(sbom.Metadata.Component.Properties).To(ContainElement(compiler.SBOMProperty{Name: "bosh:commit_hash", Value: "abc1234"}))
+
+			var components []string
+			for _, component := range sbom.Components {
+				components = append(components, component.BOMRef)
+			}
+			Expect(components).To(Equal([]string{"stemcell:some-stemcell", "package:some-package", "package:other-package"}))
+		})
+
+		It("signs the compiled release when a signing key is given", func() {
+			privateKey, publicKey := testhelpers.GenerateKeys()
+			app.SigningKey = privateKey
+
+			result, err := app.Run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.SignaturePath).To(Equal(result.CompiledReleasePath + ".sig"))
+
+			err = compiler.VerifyTarballSignature(result.CompiledReleasePath, result.SignaturePath, []string{publicKey})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("does not sign the compiled release without a signing key", func() {
+			result, err := app.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.SignaturePath).To(BeEmpty())
+			Expect(result.CompiledReleasePath + ".sig").NotTo(BeAnExistingFile())
+		})
+
+		It("returns an error when the compiled release does not match the source release", func() {
+			boshClient.ResourceCall.Returns.Resource = ioutil.NopCloser(bytes.NewReader(testhelpers.Tarball(map[string]string{
+				"release.MF": `---
+name: some-release
+version: "42"
+compiled_packages:
+- name: some-package
+  version: some-package-fingerprint
+  fingerprint: some-other-fingerprint
+  sha1: some-package-sha1
+  stemcell: some-stemcell/1.2.3
+- name: extra-package
+  version: extra-package-fingerprint
+  fingerprint: extra-package-fingerprint
+  sha1: extra-package-sha1
+  stemcell: some-stemcell/1.2.3
+`,
+			})))
+
+			_, err := app.Run()
+			Expect(err).To(MatchError("compiled release does not match release some-release 42: " +
+				"package some-package has fingerprint some-other-fingerprint, expected some-package-fingerprint; " +
+				"package other-package is missing; " +
+				"package extra-package is not in the source release"))
+		})
+
+		It("returns an error when the compiled release cannot be read", func() {
+			boshClient.ResourceCall.Returns.Resource = ioutil.NopCloser(strings.NewReader("not-a-tarball"))
+
+			_, err := app.Run()
+			Expect(err).To(MatchError(ContainSubstring("could not read the compiled release")))
 		})
 
 		It("deletes the deployment", func() {
-			err := app.Run()
+			_, err := app.Run()
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(len(boshClient.DeleteDeploymentCall.Receives.Name)).To(Equal(1))
-			Expect(boshClient.DeleteDeploymentCall.Receives.Name[0]).To(Equal("compile-release-some-guid"))
+			Expect(boshClient.CallsTo("DeleteDeployment")).To(Equal([]fakes.Call{
+				{Method: "DeleteDeployment", Args: []interface{}{"compile-release-some-guid"}},
+			}))
+			Expect(boshClient.Calls).To(fakes.HaveCalledInOrder("Resource", "DeleteDeployment"))
 		})
 
 		It("cleans up the director", func() {
-			err := app.Run()
+			_, err := app.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(boshClient.CleanupCall.CallCount).To(Equal(2))
 		})
 
+		Context("when the director cannot be cleaned up after compiling", func() {
+			It("returns an error after downloading the compiled release", func() {
+				boshClient.CleanupCall.ReturnsOnCall = map[int]fakes.TaskReturns{
+					1: {Error: errors.New("failed to clean up")},
+				}
+
+				_, err := app.Run()
+				Expect(err).To(MatchError("failed to clean up"))
+				Expect(boshClient.Calls).To(fakes.HaveCalledInOrder("Resource", "DeleteDeployment", "Cleanup"))
+				Expect(filepath.Join(compiledTempDir, "some-release-42.0.0-1.2.3.tgz")).To(BeAnExistingFile())
+			})
+		})
+
 		It("logs all of the steps", func() {
-			err := app.Run()
+			_, err := app.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(logger.Lines).To(Equal([]string{
+				"parsing release details\n",
+				"fetching bosh director information\n",
+				"parsing stemcell details\n",
+				"checking director compatibility\n",
 				"deleting existing deployments\n",
 				"preparing compiler\n",
-				"fetching bosh director information\n",
+				"checking the cloud config\n",
 				"generating deployment name\n",
-				"parsing release details\n",
-				"parsing stemcell details\n",
 				"uploading stemcell some-stemcell 1.2.3\n",
 				"uploading release some-release 42\n",
 				"generating deployment manifest\n",
+				"checking runtime config addons\n",
 				"deploying to bosh director\n",
 				"compiling the release\n",
 				"downloading the compiled release\n",
 				"deleting the deployment\n",
 				"cleaning up\n",
+				"verifying the compiled release\n",
+				"writing the sbom\n",
+				"writing the compilation report\n",
 			}))
 		})
 
+		Context("when an event logger is given", func() {
+			var eventLogger *fakes.EventLogger
+
+			steps := func() []string {
+				var steps []string
+				for _, event := range eventLogger.Events {
+					steps = append(steps, fmt.Sprintf("%s %s", event.Step, event.Outcome))
+				}
+				return steps
+			}
+
+			BeforeEach(func() {
+				eventLogger = &fakes.EventLogger{}
+				app.EventLogger = eventLogger
+			})
+
+			It("reports each step with its outcome", func() {
+				boshClient.ReleaseCall.Returns.Release = bosh.Release{Name: "some-release", Versions: []string{"42"}}
+
+				_, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(steps()).To(Equal([]string{
+					"parse_release succeeded",
+					"director_info succeeded",
+					"parse_stemcell succeeded",
+					"check_compatibility succeeded",
+					"list_deployments succeeded",
+					"cleanup succeeded",
+					"cloud_config succeeded",
+					"upload_stemcell succeeded",
+					"upload_release skipped",
+					"generate_manifest succeeded",
+					"runtime_config succeeded",
+					"deploy succeeded",
+					"export_release succeeded",
+					"download succeeded",
+					"delete_deployment succeeded",
+					"cleanup succeeded",
+					"verify succeeded",
+					"sbom succeeded",
+					"compile succeeded",
+				}))
+
+				for _, event := range eventLogger.Events {
+					Expect(event.Duration).To(BeNumerically(">=", 0))
+				}
+			})
+
+			It("describes the release, stemcell and deployment of each step once known", func() {
+				boshClient.DeployCall.Returns.TaskID = 7
+
+				_, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(eventLogger.Events[0].Release).To(BeEmpty())
+
+				deploy := eventLogger.Events[11]
+				Expect(deploy.Step).To(Equal("deploy"))
+				Expect(deploy.Release).To(Equal("some-release/42"))
+				Expect(deploy.Stemcell).To(Equal("some-stemcell/1.2.3"))
+				Expect(deploy.Deployment).To(Equal("compile-release-some-guid"))
+				Expect(deploy.TaskID).To(Equal(7))
+			})
+
+			It("reports the failing step and the compilation as failed", func() {
+				boshClient.DeployCall.Returns.Error = errors.New("failed to deploy")
+
+				_, err := app.Run()
+				Expect(err).To(MatchError("failed to deploy"))
+
+				last := len(eventLogger.Events) - 1
+				Expect(steps()[last-1:]).To(Equal([]string{"deploy failed", "compile failed"}))
+				Expect(eventLogger.Events[last].Error).To(Equal("failed to deploy"))
+			})
+
+			It("reports a failed compatibility check before uploading", func() {
+				directorClient.InfoCall.Returns.DirectorInfo.CPI = "google_cpi"
+				err := createStemcellTarball(stemcellTarballPath, bytes.NewBuffer([]byte(`---
+operating_system: some-stemcell
+version: 1.2.3
+cloud_properties:
+  infrastructure: aws
+`)))
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = app.Run()
+				Expect(err).To(HaveOccurred())
+
+				Expect(steps()[len(steps())-2:]).To(Equal([]string{"check_compatibility failed", "compile failed"}))
+			})
+
+			It("reports the changing steps of a dry run as planned", func() {
+				app.DryRun = true
+
+				_, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(steps()).To(ContainElement("deploy planned"))
+				Expect(steps()).To(ContainElement("director_info succeeded"))
+				Expect(steps()[len(steps())-1]).To(Equal("compile planned"))
+			})
+		})
+
+		Context("when it is a dry run", func() {
+			BeforeEach(func() {
+				app.DryRun = true
+				boshClient.DeploymentsCall.Returns.DeploymentList = []bosh.Deployment{
+					{Name: "dep1"},
+					{Name: "dep2"},
+				}
+			})
+
+			It("only reads from the director", func() {
+				_, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(boshClient.Calls).To(Equal([]fakes.Call{
+					{Method: "Deployments"},
+					{Method: "Stemcell", Args: []interface{}{"some-stemcell"}},
+					{Method: "Release", Args: []interface{}{"some-release"}},
+				}))
+				Expect(directorClient.InfoCall.CallCount).To(Equal(1))
+				Expect(manifestGenerator.GenerateCall.Receives.DeploymentName).To(Equal("compile-release-some-guid"))
+			})
+
+			It("returns the actions it would take in order", func() {
+				result, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Plan).To(Equal([]string{
+					"delete deployment dep1",
+					"delete deployment dep2",
+					"clean up the director",
+					"upload stemcell some-stemcell 1.2.3",
+					"upload release some-release 42",
+					"deploy compile-release-some-guid",
+					"export release some-release 42 compiled against stemcell some-stemcell 1.2.3",
+					fmt.Sprintf("download the compiled release to %s", filepath.Join(compiledTempDir, "some-release-42.0.0-1.2.3.tgz")),
+					"delete deployment compile-release-some-guid",
+					"clean up the director",
+				}))
+			})
+
+			It("leaves out the uploads it would skip", func() {
+				boshClient.StemcellCall.Returns.Stemcell = bosh.Stemcell{Name: "some-stemcell", Versions: []string{"1.2.3"}}
+
+				result, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.SkippedUploads).To(Equal([]string{"stemcell some-stemcell 1.2.3"}))
+				Expect(result.Plan).NotTo(ContainElement("upload stemcell some-stemcell 1.2.3"))
+			})
+
+			It("logs the actions it would take", func() {
+				_, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(logger.Lines).To(ContainElement("would delete deployment dep1\n"))
+				Expect(logger.Lines).To(ContainElement("would deploy compile-release-some-guid\n"))
+				Expect(logger.Lines).NotTo(ContainElement("deploying to bosh director\n"))
+			})
+
+			It("does not write the compiled release", func() {
+				result, err := app.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.CompiledReleasePath).To(BeEmpty())
+				Expect(result.ReportPath).To(BeEmpty())
+				Expect(filepath.Join(compiledTempDir, "some-release-42.0.0-1.2.3.tgz")).NotTo(BeAnExistingFile())
+			})
+
+			It("still fails on the checks it can make", func() {
+				manifestGenerator.GenerateCall.Returns.Error = errors.New("failed to generate manifest")
+
+				_, err := app.Run()
+				Expect(err).To(MatchError("failed to generate manifest"))
+			})
+		})
+
 		Context("failure cases", func() {
 			Context("when the bosh client cannot get the list of deployments", func() {
 				It("returns an error", func() {
 					boshClient.DeploymentsCall.Returns.Error = errors.New("failed to fetch list of deployments")
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to fetch list of deployments"))
 				})
 			})
@@ -222,25 +1086,79 @@ version: 1.2.3
 					}
 					boshClient.DeleteDeploymentCall.Returns.Error = errors.New("failed to delete deployment")
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to delete deployment"))
 				})
 			})
 
+			Context("when the release has uncommitted changes", func() {
+				It("returns an error without changing the director", func() {
+					boshClient.DeploymentsCall.Returns.DeploymentList = []bosh.Deployment{{Name: "some-old-deployment"}}
+
+					err := createReleaseTarball(releaseTarballPath, bytes.NewBuffer([]byte(`---
+name: some-release
+version: 42
+uncommitted_changes: true
+`)))
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = app.Run()
+					Expect(err).To(MatchError("release some-release 42 was built with uncommitted changes, set allow_dirty to compile it anyway"))
+					Expect(boshClient.Calls).NotTo(fakes.HaveCalledInOrder("DeleteDeployment"))
+					Expect(boshClient.Calls).NotTo(fakes.HaveCalledInOrder("Cleanup"))
+					Expect(boshClient.UploadStemcellCall.CallCount).To(Equal(0))
+					Expect(boshClient.UploadReleaseCall.Receives.Contents).To(BeNil())
+				})
+			})
+
 			Context("when the bosh client cannot get the director info", func() {
 				It("returns an error", func() {
-					boshClient.InfoCall.Returns.Error = errors.New("failed to fetch director info")
+					directorClient.InfoCall.Returns.Error = errors.New("failed to fetch director info")
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to fetch director info"))
 				})
 			})
 
+			Context("when the cloud config cannot be fetched", func() {
+				It("returns an error", func() {
+					directorClient.ConfigsCall.Returns.Error = errors.New("failed to fetch cloud config")
+
+					_, err := app.Run()
+					Expect(err).To(MatchError("failed to fetch cloud config"))
+				})
+			})
+
+			Context("when the director cannot run the stemcell", func() {
+				It("returns an error without changing the director", func() {
+					boshClient.DeploymentsCall.Returns.DeploymentList = []bosh.Deployment{{Name: "some-old-deployment"}}
+
+					directorInfo := directorClient.InfoCall.Returns.DirectorInfo
+					directorInfo.CPI = "vsphere_cpi"
+					directorClient.InfoCall.Returns.DirectorInfo = directorInfo
+
+					err := createStemcellTarball(stemcellTarballPath, bytes.NewBuffer([]byte(`---
+operating_system: some-stemcell
+version: 1.2.3
+cloud_properties:
+  infrastructure: aws
+`)))
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = app.Run()
+					Expect(err).To(MatchError("stemcell some-stemcell 1.2.3 is built for aws, which the director's vsphere_cpi does not support"))
+					Expect(boshClient.Calls).NotTo(fakes.HaveCalledInOrder("DeleteDeployment"))
+					Expect(boshClient.Calls).NotTo(fakes.HaveCalledInOrder("Cleanup"))
+					Expect(boshClient.UploadStemcellCall.CallCount).To(Equal(0))
+					Expect(boshClient.UploadReleaseCall.Receives.Contents).To(BeNil())
+				})
+			})
+
 			Context("when the guid cannot be generated", func() {
 				It("returns an error", func() {
 					app.GUIDGenerator = func() (string, error) { return "", errors.New("failed to generate guid") }
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to generate guid"))
 				})
 			})
@@ -249,7 +1167,7 @@ version: 1.2.3
 				It("returns an error", func() {
 					app.ReleaseTarballPath = "missing-release-1.tgz"
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
 				})
 			})
@@ -258,16 +1176,34 @@ version: 1.2.3
 				It("returns an error", func() {
 					app.StemcellTarballPath = "missing-stemcell-1.tgz"
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
 				})
 			})
 
+			Context("when the bosh client cannot look up the stemcell", func() {
+				It("returns an error", func() {
+					boshClient.StemcellCall.Returns.Error = errors.New("failed to fetch stemcell")
+
+					_, err := app.Run()
+					Expect(err).To(MatchError("failed to fetch stemcell"))
+				})
+			})
+
+			Context("when the bosh client cannot look up the release", func() {
+				It("returns an error", func() {
+					boshClient.ReleaseCall.Returns.Error = errors.New("failed to fetch release")
+
+					_, err := app.Run()
+					Expect(err).To(MatchError("failed to fetch release"))
+				})
+			})
+
 			Context("when the bosh client cannot upload the stemcell", func() {
 				It("returns an error", func() {
 					boshClient.UploadStemcellCall.Returns.Error = errors.New("failed to upload stemcell")
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to upload stemcell"))
 				})
 			})
@@ -276,7 +1212,7 @@ version: 1.2.3
 				It("returns an error", func() {
 					boshClient.UploadReleaseCall.Returns.Error = errors.New("failed to upload release")
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to upload release"))
 				})
 			})
@@ -285,7 +1221,7 @@ version: 1.2.3
 				It("returns an error", func() {
 					manifestGenerator.GenerateCall.Returns.Error = errors.New("failed to generate manifest")
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to generate manifest"))
 				})
 			})
@@ -294,7 +1230,7 @@ version: 1.2.3
 				It("returns an error", func() {
 					boshClient.DeployCall.Returns.Error = errors.New("failed to deploy manifest")
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to deploy manifest"))
 				})
 			})
@@ -303,7 +1239,7 @@ version: 1.2.3
 				It("returns an error", func() {
 					boshClient.ExportReleaseCall.Returns.Error = errors.New("failed to export release")
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to export release"))
 				})
 			})
@@ -318,7 +1254,7 @@ version: 1.2.3
 					err := os.Chmod(compiledTempDir, 0000)
 					Expect(err).NotTo(HaveOccurred())
 
-					err = app.Run()
+					_, err = app.Run()
 					Expect(err).To(MatchError(ContainSubstring("permission denied")))
 				})
 			})
@@ -327,7 +1263,7 @@ version: 1.2.3
 				It("returns an error", func() {
 					boshClient.ResourceCall.Returns.Error = errors.New("failed to retrieve resource")
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to retrieve resource"))
 				})
 			})
@@ -352,7 +1288,7 @@ version: 1.2.3
 				It("returns an error", func() {
 					boshClient.ResourceCall.Returns.Resource = badReader
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError(ContainSubstring("bad file descriptor")))
 				})
 			})
@@ -361,7 +1297,7 @@ version: 1.2.3
 				It("returns an error", func() {
 					boshClient.DeleteDeploymentCall.Returns.Error = errors.New("failed to delete deployment")
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to delete deployment"))
 				})
 			})
@@ -370,7 +1306,7 @@ version: 1.2.3
 				It("returns an error", func() {
 					boshClient.CleanupCall.Returns.Error = errors.New("failed to cleanup bosh director")
 
-					err := app.Run()
+					_, err := app.Run()
 					Expect(err).To(MatchError("failed to cleanup bosh director"))
 				})
 			})
@@ -0,0 +1,148 @@
+package compiler
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf-experimental/bosh-test/bosh"
+)
+
+// BOSHClient is the bosh client with uploads by url, which it lacks: the
+// director fetches the stemcell or release from the location itself and
+// checks it against the sha1.
+type BOSHClient struct {
+	bosh.Client
+
+	// TaskTimeout bounds how long an upload waits for its task to finish,
+	// polling every TaskPollingInterval of the config. It defaults to an hour.
+	TaskTimeout time.Duration
+
+	config     bosh.Config
+	httpClient *http.Client
+}
+
+func NewBOSHClient(config bosh.Config) BOSHClient {
+	if config.TaskPollingInterval == 0 {
+		config.TaskPollingInterval = time.Second
+	}
+
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: config.AllowInsecureSSL},
+	}
+
+	return BOSHClient{
+		Client:      bosh.NewClient(config),
+		TaskTimeout: time.Hour,
+		config:      config,
+		httpClient: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (c BOSHClient) UploadStemcellURL(location, sha1 string) (int, error) {
+	return c.uploadURL("/stemcells", location, sha1)
+}
+
+func (c BOSHClient) UploadReleaseURL(location, sha1 string) (int, error) {
+	return c.uploadURL("/releases", location, sha1)
+}
+
+func (c BOSHClient) uploadURL(path, location, sha1 string) (int, error) {
+	body, err := json.Marshal(map[string]string{
+		"location": location,
+		"sha1":     sha1,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	request, err := http.NewRequest("POST", c.config.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.SetBasicAuth(c.config.Username, c.config.Password)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusFound {
+		content, _ := ioutil.ReadAll(response.Body)
+		return 0, fmt.Errorf("unexpected response uploading %s: %s\n%s", location, response.Status, content)
+	}
+
+	taskURL, err := url.Parse(response.Header.Get("Location"))
+	if err != nil {
+		return 0, err
+	}
+
+	taskID, err := strconv.Atoi(strings.TrimPrefix(taskURL.Path, "/tasks/"))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected task location %q: %s", response.Header.Get("Location"), err)
+	}
+
+	return taskID, c.waitForTask(taskID)
+}
+
+// waitForTask polls the task until it finishes and fails unless it is done
+// or when it does not finish within the task timeout.
+func (c BOSHClient) waitForTask(taskID int) error {
+	deadline := time.Now().Add(c.TaskTimeout)
+
+	for {
+		request, err := http.NewRequest("GET", fmt.Sprintf("%s/tasks/%d", c.config.URL, taskID), nil)
+		if err != nil {
+			return err
+		}
+		request.SetBasicAuth(c.config.Username, c.config.Password)
+
+		response, err := c.httpClient.Do(request)
+		if err != nil {
+			return err
+		}
+
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return fmt.Errorf("unexpected response fetching bosh task %d: %s", taskID, response.Status)
+		}
+
+		var task struct {
+			State  string `json:"state"`
+			Result string `json:"result"`
+		}
+		err = json.NewDecoder(response.Body).Decode(&task)
+		response.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		switch task.State {
+		case "done":
+			return nil
+		case "error", "errored", "cancelled", "timeout":
+			return fmt.Errorf("bosh task %d ended in state %s: %s", taskID, task.State, task.Result)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("bosh task %d did not finish within %s, last state %s", taskID, c.TaskTimeout, task.State)
+		}
+
+		time.Sleep(c.config.TaskPollingInterval)
+	}
+}
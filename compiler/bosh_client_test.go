@@ -0,0 +1,131 @@
+package compiler_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler/fakes"
+	"github.com/pivotal-cf-experimental/bosh-test/bosh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BOSHClient", func() {
+	var (
+		director *fakes.Director
+		server   *httptest.Server
+		tempDir  string
+		client   compiler.BOSHClient
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = createStemcellTarball(filepath.Join(tempDir, "some-stemcell-1.2.3.tgz"), bytes.NewBufferString(`---
+operating_system: some-stemcell
+version: 1.2.3
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		err = createReleaseTarball(filepath.Join(tempDir, "some-release-42.tgz"), bytes.NewBufferString(`---
+name: some-release
+version: 42
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		server = httptest.NewServer(http.FileServer(http.Dir(tempDir)))
+		director = fakes.NewDirector("some-user", "some-password")
+
+		client = compiler.NewBOSHClient(bosh.Config{
+			URL:                 director.URL(),
+			Username:            "some-user",
+			Password:            "some-password",
+			TaskPollingInterval: time.Millisecond,
+		})
+	})
+
+	AfterEach(func() {
+		director.Close()
+		server.Close()
+
+		err := os.RemoveAll(tempDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("has the director upload a stemcell from a url", func() {
+		taskID, err := client.UploadStemcellURL(server.URL+"/some-stemcell-1.2.3.tgz", "some-sha1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(taskID).To(Equal(1))
+
+		Expect(director.Stemcells()).To(Equal([]fakes.DirectorStemcell{
+			{Name: "some-stemcell", OS: "some-stemcell", Version: "1.2.3"},
+		}))
+	})
+
+	It("has the director upload a release from a url", func() {
+		director.ScriptTask("upload_release", fakes.TaskScript{States: []string{"queued", "processing", "done"}})
+
+		_, err := client.UploadReleaseURL(server.URL+"/some-release-42.tgz", "some-sha1")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(director.Releases()).To(Equal(map[string][]string{"some-release": {"42"}}))
+		Expect(director.Requests()).To(Equal([]string{
+			"POST /releases",
+			"GET /tasks/1",
+			"GET /tasks/1",
+			"GET /tasks/1",
+		}))
+	})
+
+	It("has the director serve other requests while it fetches the url", func() {
+		var infoErr error
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var response *http.Response
+			response, infoErr = (&http.Client{Timeout: time.Second}).Get(director.URL() + "/info")
+			if infoErr == nil {
+				response.Body.Close()
+			}
+
+			http.FileServer(http.Dir(tempDir)).ServeHTTP(w, r)
+		})
+
+		_, err := client.UploadReleaseURL(server.URL+"/some-release-42.tgz", "some-sha1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(infoErr).NotTo(HaveOccurred())
+	})
+
+	It("returns an error when the upload task fails", func() {
+		director.ScriptTask("upload_release", fakes.TaskScript{States: []string{"processing", "error"}, Error: "sha1 mismatch"})
+
+		_, err := client.UploadReleaseURL(server.URL+"/some-release-42.tgz", "some-sha1")
+		Expect(err).To(MatchError("bosh task 1 ended in state error: sha1 mismatch"))
+	})
+
+	It("returns an error when the upload task does not finish within the task timeout", func() {
+		director.ScriptTask("upload_release", fakes.TaskScript{States: []string{"queued", "processing"}})
+		client.TaskTimeout = 20 * time.Millisecond
+
+		_, err := client.UploadReleaseURL(server.URL+"/some-release-42.tgz", "some-sha1")
+		Expect(err).To(MatchError("bosh task 1 did not finish within 20ms, last state processing"))
+	})
+
+	It("returns an error when the director refuses the upload", func() {
+		client = compiler.NewBOSHClient(bosh.Config{
+			URL:      director.URL(),
+			Username: "some-user",
+			Password: "some-wrong-password",
+		})
+
+		_, err := client.UploadStemcellURL(server.URL+"/some-stemcell-1.2.3.tgz", "some-sha1")
+		Expect(err).To(MatchError(ContainSubstring("401 Unauthorized")))
+	})
+})
@@ -0,0 +1,102 @@
+package compiler
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DeploymentSettings place the compile deployment on the director's
+// cloud-config. Empty settings default to those of the cloud-config's
+// compilation block.
+type DeploymentSettings struct {
+	VMType             string
+	Network            string
+	AZs                []string
+	CompilationWorkers int
+}
+
+type CloudConfig struct {
+	AZs         []CloudConfigEntry     `yaml:"azs"`
+	VMTypes     []CloudConfigEntry     `yaml:"vm_types"`
+	Networks    []CloudConfigEntry     `yaml:"networks"`
+	Compilation CloudConfigCompilation `yaml:"compilation"`
+}
+
+type CloudConfigEntry struct {
+	Name string `yaml:"name"`
+}
+
+type CloudConfigCompilation struct {
+	Workers int    `yaml:"workers"`
+	AZ      string `yaml:"az"`
+	VMType  string `yaml:"vm_type"`
+	Network string `yaml:"network"`
+}
+
+// ParseCloudConfig merges the named cloud configs of a director into one.
+func ParseCloudConfig(configs []Config) (CloudConfig, error) {
+	var cloudConfig CloudConfig
+	for _, namedConfig := range configs {
+		var config CloudConfig
+		err := yaml.Unmarshal([]byte(namedConfig.Content), &config)
+		if err != nil {
+			return CloudConfig{}, err
+		}
+
+		cloudConfig.AZs = append(cloudConfig.AZs, config.AZs...)
+		cloudConfig.VMTypes = append(cloudConfig.VMTypes, config.VMTypes...)
+		cloudConfig.Networks = append(cloudConfig.Networks, config.Networks...)
+		if config.Compilation.Workers != 0 {
+			cloudConfig.Compilation = config.Compilation
+		}
+	}
+
+	return cloudConfig, nil
+}
+
+// Resolve fills in the settings left empty from the compilation block and
+// checks the result against the cloud config.
+func (c CloudConfig) Resolve(settings DeploymentSettings) (DeploymentSettings, error) {
+	if settings.VMType == "" {
+		settings.VMType = c.Compilation.VMType
+	}
+
+	if settings.Network == "" {
+		settings.Network = c.Compilation.Network
+	}
+
+	if len(settings.AZs) == 0 && c.Compilation.AZ != "" {
+		settings.AZs = []string{c.Compilation.AZ}
+	}
+
+	if settings.VMType != "" && !hasEntry(c.VMTypes, settings.VMType) {
+		return DeploymentSettings{}, fmt.Errorf("vm_type %q is not defined in the cloud config", settings.VMType)
+	}
+
+	if settings.Network != "" && !hasEntry(c.Networks, settings.Network) {
+		return DeploymentSettings{}, fmt.Errorf("network %q is not defined in the cloud config", settings.Network)
+	}
+
+	for _, az := range settings.AZs {
+		if !hasEntry(c.AZs, az) {
+			return DeploymentSettings{}, fmt.Errorf("az %q is not defined in the cloud config", az)
+		}
+	}
+
+	if settings.CompilationWorkers > c.Compilation.Workers {
+		return DeploymentSettings{}, fmt.Errorf("cloud config compiles with %d workers, fewer than the %d requested", c.Compilation.Workers, settings.CompilationWorkers)
+	}
+
+	return settings, nil
+}
+
+func hasEntry(entries []CloudConfigEntry, name string) bool {
+	for _, entry := range entries {
+		if entry.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package compiler_test
+
+import (
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloudConfig", func() {
+	var cloudConfig compiler.CloudConfig
+
+	BeforeEach(func() {
+		var err error
+		cloudConfig, err = compiler.ParseCloudConfig([]compiler.Config{{Name: "default", Content: `---
+azs:
+- name: z1
+- name: z2
+vm_types:
+- name: default
+- name: large
+networks:
+- name: default
+compilation:
+  workers: 4
+  az: z1
+  vm_type: default
+  network: default
+`}, {Name: "extra", Content: `---
+networks:
+- name: private
+`}})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("ParseCloudConfig", func() {
+		It("merges the named cloud configs", func() {
+			Expect(cloudConfig.AZs).To(Equal([]compiler.CloudConfigEntry{{Name: "z1"}, {Name: "z2"}}))
+			Expect(cloudConfig.VMTypes).To(Equal([]compiler.CloudConfigEntry{{Name: "default"}, {Name: "large"}}))
+			Expect(cloudConfig.Networks).To(Equal([]compiler.CloudConfigEntry{{Name: "default"}, {Name: "private"}}))
+			Expect(cloudConfig.Compilation).To(Equal(compiler.CloudConfigCompilation{
+				Workers: 4,
+				AZ:      "z1",
+				VMType:  "default",
+				Network: "default",
+			}))
+		})
+
+		It("returns an error when a cloud config is not YAML", func() {
+			_, err := compiler.ParseCloudConfig([]compiler.Config{{Content: "%%%"}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Resolve", func() {
+		It("defaults to the compilation block", func() {
+			settings, err := cloudConfig.Resolve(compiler.DeploymentSettings{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(settings).To(Equal(compiler.DeploymentSettings{
+				VMType:  "default",
+				Network: "default",
+				AZs:     []string{"z1"},
+			}))
+		})
+
+		It("keeps the settings that are given", func() {
+			settings, err := cloudConfig.Resolve(compiler.DeploymentSettings{
+				VMType:             "large",
+				Network:            "private",
+				AZs:                []string{"z1", "z2"},
+				CompilationWorkers: 4,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(settings).To(Equal(compiler.DeploymentSettings{
+				VMType:             "large",
+				Network:            "private",
+				AZs:                []string{"z1", "z2"},
+				CompilationWorkers: 4,
+			}))
+		})
+
+		It("accepts empty settings without a cloud config", func() {
+			settings, err := compiler.CloudConfig{}.Resolve(compiler.DeploymentSettings{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(settings).To(Equal(compiler.DeploymentSettings{}))
+		})
+
+		Context("failure cases", func() {
+			It("returns an error for an unknown vm_type", func() {
+				_, err := cloudConfig.Resolve(compiler.DeploymentSettings{VMType: "huge"})
+				Expect(err).To(MatchError(`vm_type "huge" is not defined in the cloud config`))
+			})
+
+			It("returns an error for an unknown network", func() {
+				_, err := cloudConfig.Resolve(compiler.DeploymentSettings{Network: "public"})
+				Expect(err).To(MatchError(`network "public" is not defined in the cloud config`))
+			})
+
+			It("returns an error for an unknown az", func() {
+				_, err := cloudConfig.Resolve(compiler.DeploymentSettings{AZs: []string{"z1", "z3"}})
+				Expect(err).To(MatchError(`az "z3" is not defined in the cloud config`))
+			})
+
+			It("returns an error when there are fewer compilation workers than requested", func() {
+				_, err := cloudConfig.Resolve(compiler.DeploymentSettings{CompilationWorkers: 8})
+				Expect(err).To(MatchError("cloud config compiles with 4 workers, fewer than the 8 requested"))
+			})
+		})
+	})
+})
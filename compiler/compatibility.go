@@ -0,0 +1,58 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// stemcellAPIDirectorVersion is the first director version that understands
+// stemcells with an api_version above 1.
+var stemcellAPIDirectorVersion = Semver{Major: 268}
+
+// cpiInfrastructures lists the stemcell infrastructures of CPIs that are not
+// named after their infrastructure.
+var cpiInfrastructures = map[string][]string{
+	"docker": {"warden"},
+}
+
+// CheckCompatibility fails when the director's CPI cannot run the stemcell's
+// infrastructure or the director is too old for the stemcell's api_version.
+// Checks for which the director or stemcell gives no details are skipped.
+func CheckCompatibility(info DirectorInfo, stemcell Stemcell) error {
+	infrastructure := stemcell.CloudProperties.Infrastructure
+	if infrastructure != "" && info.CPI != "" && !supportsInfrastructure(info.CPI, infrastructure) {
+		return fmt.Errorf("stemcell %s %s is built for %s, which the director's %s does not support", stemcell.Name, stemcell.Version, infrastructure, info.CPI)
+	}
+
+	fields := strings.Fields(info.Version)
+	if stemcell.APIVersion > 1 && len(fields) > 0 {
+		directorVersion, err := parseSemver(fields[0])
+		if err != nil {
+			return err
+		}
+
+		if directorVersion.LessThan(stemcellAPIDirectorVersion) {
+			return fmt.Errorf("stemcell %s %s has api_version %d, which director version %s does not support, upgrade the director to %s or later",
+				stemcell.Name, stemcell.Version, stemcell.APIVersion, fields[0], stemcellAPIDirectorVersion)
+		}
+	}
+
+	return nil
+}
+
+// supportsInfrastructure matches the infrastructure against the tokens of the
+// CPI's name, which operators are free to choose, such as vsphere-dc2_cpi.
+func supportsInfrastructure(cpi, infrastructure string) bool {
+	tokens := strings.FieldsFunc(cpi, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, token := range tokens {
+		if token == infrastructure || existsInSlice(cpiInfrastructures[token], infrastructure) {
+			return true
+		}
+	}
+
+	return false
+}
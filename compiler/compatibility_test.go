@@ -0,0 +1,87 @@
+package compiler_test
+
+import (
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CheckCompatibility", func() {
+	var (
+		info     compiler.DirectorInfo
+		stemcell compiler.Stemcell
+	)
+
+	BeforeEach(func() {
+		info = compiler.DirectorInfo{
+			Version: "270.2.0 (00000000)",
+			CPI:     "aws_cpi",
+		}
+
+		stemcell = compiler.Stemcell{
+			Name:       "ubuntu-xenial",
+			Version:    "621.23",
+			APIVersion: 3,
+			CloudProperties: compiler.StemcellCloudProperties{
+				Infrastructure: "aws",
+			},
+		}
+	})
+
+	It("accepts a stemcell built for the director's cpi", func() {
+		Expect(compiler.CheckCompatibility(info, stemcell)).To(Succeed())
+	})
+
+	It("accepts warden stemcells on the docker cpi", func() {
+		info.CPI = "docker_cpi"
+		stemcell.CloudProperties.Infrastructure = "warden"
+
+		Expect(compiler.CheckCompatibility(info, stemcell)).To(Succeed())
+	})
+
+	It("accepts a cpi named after the infrastructure and more", func() {
+		info.CPI = "vsphere-dc2_cpi"
+		stemcell.CloudProperties.Infrastructure = "vsphere"
+
+		Expect(compiler.CheckCompatibility(info, stemcell)).To(Succeed())
+
+		info.CPI = "dc2-vsphere"
+		Expect(compiler.CheckCompatibility(info, stemcell)).To(Succeed())
+	})
+
+	It("skips the checks the director or stemcell gives no details for", func() {
+		Expect(compiler.CheckCompatibility(compiler.DirectorInfo{}, stemcell)).To(Succeed())
+		Expect(compiler.CheckCompatibility(info, compiler.Stemcell{APIVersion: 1})).To(Succeed())
+	})
+
+	It("accepts api_version 1 stemcells on old directors", func() {
+		info.Version = "267.5.0 (00000000)"
+		stemcell.APIVersion = 1
+
+		Expect(compiler.CheckCompatibility(info, stemcell)).To(Succeed())
+	})
+
+	Context("failure cases", func() {
+		It("returns an error when the cpi does not support the stemcell's infrastructure", func() {
+			info.CPI = "google_cpi"
+
+			err := compiler.CheckCompatibility(info, stemcell)
+			Expect(err).To(MatchError("stemcell ubuntu-xenial 621.23 is built for aws, which the director's google_cpi does not support"))
+		})
+
+		It("returns an error when the cpi name only starts with the stemcell's infrastructure", func() {
+			info.CPI = "awsome_cpi"
+
+			err := compiler.CheckCompatibility(info, stemcell)
+			Expect(err).To(MatchError("stemcell ubuntu-xenial 621.23 is built for aws, which the director's awsome_cpi does not support"))
+		})
+
+		It("returns an error when the director is too old for the stemcell's api_version", func() {
+			info.Version = "267.5.0 (00000000)"
+
+			err := compiler.CheckCompatibility(info, stemcell)
+			Expect(err).To(MatchError("stemcell ubuntu-xenial 621.23 has api_version 3, which director version 267.5.0 does not support, upgrade the director to 268.0.0 or later"))
+		})
+	})
+})
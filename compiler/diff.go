@@ -0,0 +1,239 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ReleaseDiff describes how the jobs, packages and stemcell of one release
+// differ from those of another. Either release may be a source or a compiled
+// release.
+type ReleaseDiff struct {
+	From     string      `json:"from"`
+	To       string      `json:"to"`
+	Stemcell *Change     `json:"stemcell,omitempty"`
+	Jobs     []EntryDiff `json:"jobs"`
+	Packages []EntryDiff `json:"packages"`
+}
+
+type Change struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// EntryDiff is a job or package that was "added", "removed" or "changed". The
+// dependencies of a job are its packages.
+type EntryDiff struct {
+	Name                string   `json:"name"`
+	Change              string   `json:"change"`
+	Version             *Change  `json:"version,omitempty"`
+	Fingerprint         *Change  `json:"fingerprint,omitempty"`
+	SHA1                *Change  `json:"sha1,omitempty"`
+	Stemcell            *Change  `json:"stemcell,omitempty"`
+	AddedDependencies   []string `json:"added_dependencies,omitempty"`
+	RemovedDependencies []string `json:"removed_dependencies,omitempty"`
+}
+
+type diffEntry struct {
+	version      string
+	fingerprint  string
+	sha1         string
+	stemcell     string
+	dependencies []string
+}
+
+// DiffReleases compares the release.MF of two release tarballs, listing jobs
+// and packages by name.
+func DiffReleases(from, to ReleaseManifest) ReleaseDiff {
+	diff := ReleaseDiff{
+		From:     fmt.Sprintf("%s/%s", from.Name, from.Version),
+		To:       fmt.Sprintf("%s/%s", to.Name, to.Version),
+		Jobs:     diffEntries(jobEntries(from), jobEntries(to)),
+		Packages: diffEntries(packageEntries(from), packageEntries(to)),
+	}
+
+	diff.Stemcell = change(releaseStemcells(from), releaseStemcells(to))
+
+	return diff
+}
+
+// Empty is true when the releases have the same jobs, packages and stemcell.
+func (d ReleaseDiff) Empty() bool {
+	return d.Stemcell == nil && len(d.Jobs) == 0 && len(d.Packages) == 0
+}
+
+// Text describes the diff for people, one job or package at a time.
+func (d ReleaseDiff) Text() string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("%s -> %s", d.From, d.To))
+
+	if d.Empty() {
+		return strings.Join(append(lines, "no differences"), "\n") + "\n"
+	}
+
+	if d.Stemcell != nil {
+		lines = append(lines, fmt.Sprintf("stemcell: %s -> %s", orNone(d.Stemcell.From), orNone(d.Stemcell.To)))
+	}
+
+	for _, entry := range d.Jobs {
+		lines = append(lines, entry.text("job", "packages")...)
+	}
+
+	for _, entry := range d.Packages {
+		lines = append(lines, entry.text("package", "dependencies")...)
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (e EntryDiff) text(kind, dependencies string) []string {
+	lines := []string{fmt.Sprintf("%s %s %s", kind, e.Name, e.Change)}
+
+	fields := []struct {
+		name   string
+		change *Change
+	}{
+		{"version", e.Version},
+		{"fingerprint", e.Fingerprint},
+		{"sha1", e.SHA1},
+		{"stemcell", e.Stemcell},
+	}
+	for _, field := range fields {
+		if field.change != nil {
+			lines = append(lines, fmt.Sprintf("  %s: %s -> %s", field.name, orNone(field.change.From), orNone(field.change.To)))
+		}
+	}
+
+	if len(e.AddedDependencies) > 0 {
+		lines = append(lines, fmt.Sprintf("  %s added: %s", dependencies, strings.Join(e.AddedDependencies, ", ")))
+	}
+
+	if len(e.RemovedDependencies) > 0 {
+		lines = append(lines, fmt.Sprintf("  %s removed: %s", dependencies, strings.Join(e.RemovedDependencies, ", ")))
+	}
+
+	return lines
+}
+
+func diffEntries(from, to map[string]diffEntry) []EntryDiff {
+	diffs := []EntryDiff{}
+
+	for _, name := range entryNames(from, to) {
+		before, inFrom := from[name]
+		after, inTo := to[name]
+
+		switch {
+		case !inFrom:
+			diffs = append(diffs, EntryDiff{Name: name, Change: "added"})
+		case !inTo:
+			diffs = append(diffs, EntryDiff{Name: name, Change: "removed"})
+		default:
+			entry := EntryDiff{
+				Name:                name,
+				Change:              "changed",
+				Version:             change(before.version, after.version),
+				Fingerprint:         change(before.fingerprint, after.fingerprint),
+				SHA1:                change(before.sha1, after.sha1),
+				Stemcell:            change(before.stemcell, after.stemcell),
+				AddedDependencies:   missingFrom(before.dependencies, after.dependencies),
+				RemovedDependencies: missingFrom(after.dependencies, before.dependencies),
+			}
+
+			if entry.Version != nil || entry.Fingerprint != nil || entry.SHA1 != nil || entry.Stemcell != nil ||
+				len(entry.AddedDependencies) > 0 || len(entry.RemovedDependencies) > 0 {
+				diffs = append(diffs, entry)
+			}
+		}
+	}
+
+	return diffs
+}
+
+func jobEntries(manifest ReleaseManifest) map[string]diffEntry {
+	entries := map[string]diffEntry{}
+	for _, job := range manifest.Jobs {
+		entries[job.Name] = diffEntry{
+			version:      job.Version,
+			fingerprint:  job.Fingerprint,
+			sha1:         job.SHA1,
+			dependencies: job.Packages,
+		}
+	}
+
+	return entries
+}
+
+// packageEntries takes the packages of a source release or the compiled
+// packages of a compiled release.
+func packageEntries(manifest ReleaseManifest) map[string]diffEntry {
+	entries := map[string]diffEntry{}
+	for _, pkg := range append(manifest.Packages, manifest.CompiledPackages...) {
+		entries[pkg.Name] = diffEntry{
+			version:      pkg.Version,
+			fingerprint:  pkg.Fingerprint,
+			sha1:         pkg.SHA1,
+			stemcell:     pkg.Stemcell,
+			dependencies: pkg.Dependencies,
+		}
+	}
+
+	return entries
+}
+
+// releaseStemcells lists the stemcells the packages of a release are compiled
+// against, which is none for a source release.
+func releaseStemcells(manifest ReleaseManifest) string {
+	var stemcells []string
+	for _, pkg := range manifest.CompiledPackages {
+		if pkg.Stemcell != "" && !existsInSlice(stemcells, pkg.Stemcell) {
+			stemcells = append(stemcells, pkg.Stemcell)
+		}
+	}
+	sort.Strings(stemcells)
+
+	return strings.Join(stemcells, ", ")
+}
+
+func entryNames(from, to map[string]diffEntry) []string {
+	var names []string
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func change(from, to string) *Change {
+	if from == to {
+		return nil
+	}
+
+	return &Change{From: from, To: to}
+}
+
+// missingFrom lists the values that are in values but not in others.
+func missingFrom(others, values []string) []string {
+	var missing []string
+	for _, value := range values {
+		if !existsInSlice(others, value) {
+			missing = append(missing, value)
+		}
+	}
+
+	return missing
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+
+	return value
+}
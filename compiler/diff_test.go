@@ -0,0 +1,145 @@
+package compiler_test
+
+import (
+	"encoding/json"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DiffReleases", func() {
+	var from, to compiler.ReleaseManifest
+
+	BeforeEach(func() {
+		from = compiler.ReleaseManifest{
+			Name:    "some-release",
+			Version: "42",
+			Jobs: []compiler.ReleaseJob{
+				{Name: "some-job", Version: "v1", Fingerprint: "f1", SHA1: "s1", Packages: []string{"some-package"}},
+				{Name: "old-job", Version: "v1", Fingerprint: "f1", SHA1: "s1"},
+			},
+			CompiledPackages: []compiler.ReleasePackage{
+				{Name: "some-package", Version: "v1", Fingerprint: "f1", SHA1: "s1", Stemcell: "some-stemcell/1.2.3", Dependencies: []string{"old-package"}},
+				{Name: "old-package", Version: "v1", Fingerprint: "f1", SHA1: "s1", Stemcell: "some-stemcell/1.2.3"},
+			},
+		}
+
+		to = compiler.ReleaseManifest{
+			Name:    "some-release",
+			Version: "43",
+			Jobs: []compiler.ReleaseJob{
+				{Name: "some-job", Version: "v2", Fingerprint: "f2", SHA1: "s2", Packages: []string{"some-package", "new-package"}},
+				{Name: "new-job", Version: "v1", Fingerprint: "f1", SHA1: "s1"},
+			},
+			CompiledPackages: []compiler.ReleasePackage{
+				{Name: "some-package", Version: "v1", Fingerprint: "f1", SHA1: "s2", Stemcell: "some-stemcell/1.2.4", Dependencies: []string{"new-package"}},
+				{Name: "new-package", Version: "v1", Fingerprint: "f1", SHA1: "s1", Stemcell: "some-stemcell/1.2.4"},
+			},
+		}
+	})
+
+	It("reports added, removed and changed jobs and packages and the stemcell", func() {
+		diff := compiler.DiffReleases(from, to)
+
+		Expect(diff).To(Equal(compiler.ReleaseDiff{
+			From:     "some-release/42",
+			To:       "some-release/43",
+			Stemcell: &compiler.Change{From: "some-stemcell/1.2.3", To: "some-stemcell/1.2.4"},
+			Jobs: []compiler.EntryDiff{
+				{Name: "new-job", Change: "added"},
+				{Name: "old-job", Change: "removed"},
+				{
+					Name:              "some-job",
+					Change:            "changed",
+					Version:           &compiler.Change{From: "v1", To: "v2"},
+					Fingerprint:       &compiler.Change{From: "f1", To: "f2"},
+					SHA1:              &compiler.Change{From: "s1", To: "s2"},
+					AddedDependencies: []string{"new-package"},
+				},
+			},
+			Packages: []compiler.EntryDiff{
+				{Name: "new-package", Change: "added"},
+				{Name: "old-package", Change: "removed"},
+				{
+					Name:                "some-package",
+					Change:              "changed",
+					SHA1:                &compiler.Change{From: "s1", To: "s2"},
+					Stemcell:            &compiler.Change{From: "some-stemcell/1.2.3", To: "some-stemcell/1.2.4"},
+					AddedDependencies:   []string{"new-package"},
+					RemovedDependencies: []string{"old-package"},
+				},
+			},
+		}))
+	})
+
+	It("describes the diff as text", func() {
+		Expect(compiler.DiffReleases(from, to).Text()).To(Equal(`some-release/42 -> some-release/43
+stemcell: some-stemcell/1.2.3 -> some-stemcell/1.2.4
+job new-job added
+job old-job removed
+job some-job changed
+  version: v1 -> v2
+  fingerprint: f1 -> f2
+  sha1: s1 -> s2
+  packages added: new-package
+package new-package added
+package old-package removed
+package some-package changed
+  sha1: s1 -> s2
+  stemcell: some-stemcell/1.2.3 -> some-stemcell/1.2.4
+  dependencies added: new-package
+  dependencies removed: old-package
+`))
+	})
+
+	It("describes the diff as json", func() {
+		content, err := json.Marshal(compiler.DiffReleases(from, from))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(content).To(MatchJSON(`{
+			"from": "some-release/42",
+			"to": "some-release/42",
+			"jobs": [],
+			"packages": []
+		}`))
+	})
+
+	It("reports no differences between the same release", func() {
+		diff := compiler.DiffReleases(from, from)
+
+		Expect(diff.Empty()).To(BeTrue())
+		Expect(diff.Text()).To(Equal("some-release/42 -> some-release/42\nno differences\n"))
+	})
+
+	It("compares the packages of a source release with those of its compiled release", func() {
+		source := compiler.ReleaseManifest{
+			Name:    "some-release",
+			Version: "42",
+			Packages: []compiler.ReleasePackage{
+				{Name: "some-package", Version: "v1", Fingerprint: "f1", SHA1: "source-sha1", Dependencies: []string{"old-package"}},
+				{Name: "old-package", Version: "v1", Fingerprint: "f1", SHA1: "s1"},
+			},
+		}
+		source.Jobs = from.Jobs
+
+		diff := compiler.DiffReleases(source, from)
+
+		Expect(diff.Stemcell).To(Equal(&compiler.Change{From: "", To: "some-stemcell/1.2.3"}))
+		Expect(diff.Jobs).To(BeEmpty())
+		Expect(diff.Packages).To(Equal([]compiler.EntryDiff{
+			{
+				Name:     "old-package",
+				Change:   "changed",
+				Stemcell: &compiler.Change{From: "", To: "some-stemcell/1.2.3"},
+			},
+			{
+				Name:     "some-package",
+				Change:   "changed",
+				SHA1:     &compiler.Change{From: "source-sha1", To: "s1"},
+				Stemcell: &compiler.Change{From: "", To: "some-stemcell/1.2.3"},
+			},
+		}))
+	})
+})
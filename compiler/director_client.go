@@ -0,0 +1,201 @@
+package compiler
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/pivotal-cf-experimental/bosh-test/bosh"
+)
+
+// DirectorClient covers the director endpoints that the bosh client does not:
+// the full /info document and the /configs of a type, such as "cloud" or
+// "runtime".
+type DirectorClient struct {
+	config     bosh.Config
+	httpClient *http.Client
+}
+
+type Config struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+}
+
+type DirectorInfo struct {
+	Name     string                     `json:"name"`
+	UUID     string                     `json:"uuid"`
+	Version  string                     `json:"version"`
+	CPI      string                     `json:"cpi"`
+	Features map[string]DirectorFeature `json:"features"`
+}
+
+type DirectorFeature struct {
+	Status bool `json:"status"`
+}
+
+// DirectorStemcell is a stemcell on the director. The director does not
+// report the sha1 of a stemcell's image.
+type DirectorStemcell struct {
+	Name            string `json:"name"`
+	OperatingSystem string `json:"operating_system"`
+	Version         string `json:"version"`
+	APIVersion      int    `json:"api_version"`
+}
+
+// DirectorRelease is the jobs and packages of a release version on the
+// director.
+type DirectorRelease struct {
+	Jobs     []DirectorReleaseEntry `json:"jobs"`
+	Packages []DirectorReleaseEntry `json:"packages"`
+}
+
+type DirectorReleaseEntry struct {
+	Name        string `json:"name" yaml:"name"`
+	Version     string `json:"version" yaml:"version"`
+	Fingerprint string `json:"fingerprint" yaml:"fingerprint"`
+	SHA1        string `json:"sha1" yaml:"sha1"`
+}
+
+func NewDirectorClient(config bosh.Config) DirectorClient {
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: config.AllowInsecureSSL},
+	}
+
+	return DirectorClient{
+		config:     config,
+		httpClient: &http.Client{Transport: transport},
+	}
+}
+
+func (c DirectorClient) Info() (DirectorInfo, error) {
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s/info", c.config.URL), nil)
+	if err != nil {
+		return DirectorInfo{}, err
+	}
+	request.SetBasicAuth(c.config.Username, c.config.Password)
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return DirectorInfo{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return DirectorInfo{}, fmt.Errorf("unexpected response fetching director info: %s", response.Status)
+	}
+
+	var info DirectorInfo
+	err = json.NewDecoder(response.Body).Decode(&info)
+	if err != nil {
+		return DirectorInfo{}, err
+	}
+
+	return info, nil
+}
+
+// Configs returns the latest config of each name of the given type.
+func (c DirectorClient) Configs(configType string) ([]Config, error) {
+	query := url.Values{}
+	query.Set("type", configType)
+	query.Set("latest", "true")
+
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s/configs?%s", c.config.URL, query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.SetBasicAuth(c.config.Username, c.config.Password)
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response fetching %s configs: %s", configType, response.Status)
+	}
+
+	var configs []Config
+	err = json.NewDecoder(response.Body).Decode(&configs)
+	if err != nil {
+		return nil, err
+	}
+
+	return configs, nil
+}
+
+// UpdateConfig stores new content for the named config.
+func (c DirectorClient) UpdateConfig(config Config) error {
+	body, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", fmt.Sprintf("%s/configs", c.config.URL), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(c.config.Username, c.config.Password)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected response updating %s config %s: %s", config.Type, config.Name, response.Status)
+	}
+
+	return nil
+}
+
+func (c DirectorClient) Stemcells() ([]DirectorStemcell, error) {
+	var stemcells []DirectorStemcell
+	err := c.getJSON("/stemcells", "stemcells", &stemcells)
+	if err != nil {
+		return nil, err
+	}
+
+	return stemcells, nil
+}
+
+// ReleaseVersion returns the jobs and packages of a version of the release.
+func (c DirectorClient) ReleaseVersion(name, version string) (DirectorRelease, error) {
+	query := url.Values{}
+	query.Set("version", version)
+
+	var release DirectorRelease
+	err := c.getJSON(fmt.Sprintf("/releases/%s?%s", url.PathEscape(name), query.Encode()), fmt.Sprintf("release %s %s", name, version), &release)
+	if err != nil {
+		return DirectorRelease{}, err
+	}
+
+	return release, nil
+}
+
+func (c DirectorClient) getJSON(path, description string, v interface{}) error {
+	request, err := http.NewRequest("GET", c.config.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(c.config.Username, c.config.Password)
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response fetching %s: %s", description, response.Status)
+	}
+
+	return json.NewDecoder(response.Body).Decode(v)
+}
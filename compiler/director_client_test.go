@@ -0,0 +1,174 @@
+package compiler_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"github.com/pivotal-cf-experimental/bosh-test/bosh"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DirectorClient", func() {
+	var (
+		server      *httptest.Server
+		request     *http.Request
+		requestBody []byte
+		status      int
+		client      compiler.DirectorClient
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			request = r
+			requestBody, _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(status)
+			w.Write([]byte(`[{"id":"1","name":"default","type":"cloud","content":"azs: []\n"},{"id":"2","name":"extra","type":"cloud","content":"networks: []\n"}]`))
+		}))
+
+		client = compiler.NewDirectorClient(bosh.Config{
+			URL:      server.URL,
+			Username: "some-user",
+			Password: "some-password",
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Info", func() {
+		It("fetches the director's info", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				request = r
+				w.Write([]byte(`{"name":"some-director","uuid":"some-uuid","version":"270.2.0 (00000000)","cpi":"aws_cpi","features":{"config_server":{"status":true},"snapshots":{"status":false}}}`))
+			})
+
+			info, err := client.Info()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info).To(Equal(compiler.DirectorInfo{
+				Name:    "some-director",
+				UUID:    "some-uuid",
+				Version: "270.2.0 (00000000)",
+				CPI:     "aws_cpi",
+				Features: map[string]compiler.DirectorFeature{
+					"config_server": {Status: true},
+					"snapshots":     {Status: false},
+				},
+			}))
+			Expect(request.URL.Path).To(Equal("/info"))
+		})
+
+		It("returns an error when the director responds with a failure", func() {
+			status = http.StatusInternalServerError
+
+			_, err := client.Info()
+			Expect(err).To(MatchError("unexpected response fetching director info: 500 Internal Server Error"))
+		})
+	})
+
+	Describe("Stemcells", func() {
+		It("fetches the stemcells on the director", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				request = r
+				w.Write([]byte(`[{"name":"some-stemcell","operating_system":"ubuntu-xenial","version":"1.2.3","api_version":2}]`))
+			})
+
+			stemcells, err := client.Stemcells()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stemcells).To(Equal([]compiler.DirectorStemcell{
+				{Name: "some-stemcell", OperatingSystem: "ubuntu-xenial", Version: "1.2.3", APIVersion: 2},
+			}))
+			Expect(request.URL.Path).To(Equal("/stemcells"))
+		})
+
+		It("returns an error when the director responds with a failure", func() {
+			status = http.StatusInternalServerError
+
+			_, err := client.Stemcells()
+			Expect(err).To(MatchError("unexpected response fetching stemcells: 500 Internal Server Error"))
+		})
+	})
+
+	Describe("ReleaseVersion", func() {
+		It("fetches the jobs and packages of the release version", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				request = r
+				w.Write([]byte(`{"jobs":[{"name":"some-job","version":"job-fingerprint","fingerprint":"job-fingerprint","sha1":"job-sha1"}],"packages":[{"name":"some-package","version":"package-fingerprint","fingerprint":"package-fingerprint","sha1":"package-sha1"}]}`))
+			})
+
+			release, err := client.ReleaseVersion("some-release", "42")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(release).To(Equal(compiler.DirectorRelease{
+				Jobs: []compiler.DirectorReleaseEntry{
+					{Name: "some-job", Version: "job-fingerprint", Fingerprint: "job-fingerprint", SHA1: "job-sha1"},
+				},
+				Packages: []compiler.DirectorReleaseEntry{
+					{Name: "some-package", Version: "package-fingerprint", Fingerprint: "package-fingerprint", SHA1: "package-sha1"},
+				},
+			}))
+			Expect(request.URL.Path).To(Equal("/releases/some-release"))
+			Expect(request.URL.Query().Get("version")).To(Equal("42"))
+		})
+
+		It("returns an error when the director responds with a failure", func() {
+			status = http.StatusInternalServerError
+
+			_, err := client.ReleaseVersion("some-release", "42")
+			Expect(err).To(MatchError("unexpected response fetching release some-release 42: 500 Internal Server Error"))
+		})
+	})
+
+	Describe("Configs", func() {
+		It("fetches the latest configs of a type", func() {
+			configs, err := client.Configs("cloud")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configs).To(Equal([]compiler.Config{
+				{Name: "default", Type: "cloud", Content: "azs: []\n"},
+				{Name: "extra", Type: "cloud", Content: "networks: []\n"},
+			}))
+
+			Expect(request.Method).To(Equal("GET"))
+			Expect(request.URL.Path).To(Equal("/configs"))
+			Expect(request.URL.Query().Get("type")).To(Equal("cloud"))
+			Expect(request.URL.Query().Get("latest")).To(Equal("true"))
+
+			username, password, ok := request.BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(username).To(Equal("some-user"))
+			Expect(password).To(Equal("some-password"))
+		})
+
+		It("returns an error when the director responds with a failure", func() {
+			status = http.StatusUnauthorized
+
+			_, err := client.Configs("cloud")
+			Expect(err).To(MatchError("unexpected response fetching cloud configs: 401 Unauthorized"))
+		})
+	})
+
+	Describe("UpdateConfig", func() {
+		It("posts the config to the director", func() {
+			status = http.StatusCreated
+
+			err := client.UpdateConfig(compiler.Config{Name: "some-name", Type: "runtime", Content: "addons: []\n"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(request.Method).To(Equal("POST"))
+			Expect(request.URL.Path).To(Equal("/configs"))
+			Expect(request.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(requestBody).To(MatchJSON(`{"name":"some-name","type":"runtime","content":"addons: []\n"}`))
+		})
+
+		It("returns an error when the director responds with a failure", func() {
+			status = http.StatusBadRequest
+
+			err := client.UpdateConfig(compiler.Config{Name: "some-name", Type: "runtime"})
+			Expect(err).To(MatchError("unexpected response updating runtime config some-name: 400 Bad Request"))
+		})
+	})
+})
@@ -6,23 +6,20 @@ import (
 	"github.com/pivotal-cf-experimental/bosh-test/bosh"
 )
 
+// BOSHClient records every call made to it in Calls, in order, alongside the
+// arguments of the latest call of each method in its Receives. A call returns
+// ReturnsOnCall[n] for the nth call of a method, counting from 0, when it is
+// set and Returns otherwise.
 type BOSHClient struct {
-	InfoCall struct {
-		CallCount int
-		Returns   struct {
-			DirectorInfo bosh.DirectorInfo
-			Error        error
-		}
-	}
+	Calls []Call
 
 	UploadReleaseCall struct {
-		Receives struct {
+		CallCount int
+		Receives  struct {
 			Contents bosh.SizeReader
 		}
-		Returns struct {
-			TaskID int
-			Error  error
-		}
+		Returns       TaskReturns
+		ReturnsOnCall map[int]TaskReturns
 	}
 
 	UploadStemcellCall struct {
@@ -30,143 +27,309 @@ type BOSHClient struct {
 		Receives  struct {
 			Contents bosh.SizeReader
 		}
-		Returns struct {
-			TaskID int
-			Error  error
+		Returns       TaskReturns
+		ReturnsOnCall map[int]TaskReturns
+	}
+
+	UploadReleaseURLCall struct {
+		CallCount int
+		Receives  struct {
+			URL  string
+			SHA1 string
+		}
+		Returns       TaskReturns
+		ReturnsOnCall map[int]TaskReturns
+	}
+
+	UploadStemcellURLCall struct {
+		CallCount int
+		Receives  struct {
+			URL  string
+			SHA1 string
 		}
+		Returns       TaskReturns
+		ReturnsOnCall map[int]TaskReturns
 	}
 
 	DeployCall struct {
-		Receives struct {
+		CallCount int
+		Receives  struct {
 			Manifest []byte
 		}
-		Returns struct {
-			TaskID int
-			Error  error
-		}
+		Returns       TaskReturns
+		ReturnsOnCall map[int]TaskReturns
 	}
 
 	ExportReleaseCall struct {
-		Receives struct {
+		CallCount int
+		Receives  struct {
 			DeploymentName  string
 			ReleaseName     string
 			ReleaseVersion  string
 			StemcellName    string
 			StemcellVersion string
 		}
-		Returns struct {
-			ResourceID string
-			Error      error
-		}
+		Returns       ExportReleaseReturns
+		ReturnsOnCall map[int]ExportReleaseReturns
 	}
 
 	ResourceCall struct {
-		Receives struct {
+		CallCount int
+		Receives  struct {
 			ResourceID string
 		}
-		Returns struct {
-			Resource io.ReadCloser
-			Error    error
-		}
+		Returns       ResourceReturns
+		ReturnsOnCall map[int]ResourceReturns
 	}
 
 	DeleteDeploymentCall struct {
-		Receives struct {
+		CallCount int
+		Receives  struct {
 			Name []string
 		}
-		Returns struct {
-			Error error
-		}
+		Returns       ErrorReturns
+		ReturnsOnCall map[int]ErrorReturns
 	}
 
 	CleanupCall struct {
-		CallCount int
-		Returns   struct {
-			TaskID int
-			Error  error
-		}
+		CallCount     int
+		Returns       TaskReturns
+		ReturnsOnCall map[int]TaskReturns
 	}
 
 	DeploymentsCall struct {
-		CallCount int
-		Returns   struct {
-			DeploymentList []bosh.Deployment
-			Error          error
-		}
+		CallCount     int
+		Returns       DeploymentsReturns
+		ReturnsOnCall map[int]DeploymentsReturns
+	}
+
+	ReleaseCall struct {
+		CallCount     int
+		Receives      string
+		Returns       ReleaseReturns
+		ReturnsOnCall map[int]ReleaseReturns
 	}
 
 	StemcellCall struct {
-		CallCount int
-		Receives  string
-		Returns   struct {
-			Stemcell bosh.Stemcell
-			Error    error
-		}
+		CallCount     int
+		Receives      string
+		Returns       StemcellReturns
+		ReturnsOnCall map[int]StemcellReturns
 	}
 }
 
-func (c *BOSHClient) Info() (bosh.DirectorInfo, error) {
-	c.InfoCall.CallCount++
+// Call is a method called on a fake with the arguments it was given.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+type TaskReturns struct {
+	TaskID int
+	Error  error
+}
+
+type ErrorReturns struct {
+	Error error
+}
+
+type ExportReleaseReturns struct {
+	ResourceID string
+	Error      error
+}
+
+type ResourceReturns struct {
+	Resource io.ReadCloser
+	Error    error
+}
+
+type DeploymentsReturns struct {
+	DeploymentList []bosh.Deployment
+	Error          error
+}
 
-	return c.InfoCall.Returns.DirectorInfo, c.InfoCall.Returns.Error
+type ReleaseReturns struct {
+	Release bosh.Release
+	Error   error
+}
+
+type StemcellReturns struct {
+	Stemcell bosh.Stemcell
+	Error    error
+}
+
+// CallsTo returns the calls made to the method, in order.
+func (c *BOSHClient) CallsTo(method string) []Call {
+	var calls []Call
+	for _, call := range c.Calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+func (c *BOSHClient) record(method string, args ...interface{}) {
+	c.Calls = append(c.Calls, Call{Method: method, Args: args})
 }
 
 func (c *BOSHClient) Deploy(manifest []byte) (int, error) {
+	c.record("Deploy", manifest)
 	c.DeployCall.Receives.Manifest = manifest
 
-	return c.DeployCall.Returns.TaskID, c.DeployCall.Returns.Error
+	returns := c.DeployCall.Returns
+	if r, ok := c.DeployCall.ReturnsOnCall[c.DeployCall.CallCount]; ok {
+		returns = r
+	}
+	c.DeployCall.CallCount++
+
+	return returns.TaskID, returns.Error
 }
 
 func (c *BOSHClient) ExportRelease(deploymentName, releaseName, releaseVersion, stemcellName, stemcellVersion string) (string, error) {
+	c.record("ExportRelease", deploymentName, releaseName, releaseVersion, stemcellName, stemcellVersion)
 	c.ExportReleaseCall.Receives.DeploymentName = deploymentName
 	c.ExportReleaseCall.Receives.ReleaseName = releaseName
 	c.ExportReleaseCall.Receives.ReleaseVersion = releaseVersion
 	c.ExportReleaseCall.Receives.StemcellName = stemcellName
 	c.ExportReleaseCall.Receives.StemcellVersion = stemcellVersion
 
-	return c.ExportReleaseCall.Returns.ResourceID, c.ExportReleaseCall.Returns.Error
+	returns := c.ExportReleaseCall.Returns
+	if r, ok := c.ExportReleaseCall.ReturnsOnCall[c.ExportReleaseCall.CallCount]; ok {
+		returns = r
+	}
+	c.ExportReleaseCall.CallCount++
+
+	return returns.ResourceID, returns.Error
 }
 
 func (c *BOSHClient) Resource(resourceID string) (io.ReadCloser, error) {
+	c.record("Resource", resourceID)
 	c.ResourceCall.Receives.ResourceID = resourceID
 
-	return c.ResourceCall.Returns.Resource, c.ResourceCall.Returns.Error
+	returns := c.ResourceCall.Returns
+	if r, ok := c.ResourceCall.ReturnsOnCall[c.ResourceCall.CallCount]; ok {
+		returns = r
+	}
+	c.ResourceCall.CallCount++
+
+	return returns.Resource, returns.Error
 }
 
 func (c *BOSHClient) UploadRelease(contents bosh.SizeReader) (int, error) {
+	c.record("UploadRelease", contents)
 	c.UploadReleaseCall.Receives.Contents = contents
 
-	return c.UploadReleaseCall.Returns.TaskID, c.UploadReleaseCall.Returns.Error
+	returns := c.UploadReleaseCall.Returns
+	if r, ok := c.UploadReleaseCall.ReturnsOnCall[c.UploadReleaseCall.CallCount]; ok {
+		returns = r
+	}
+	c.UploadReleaseCall.CallCount++
+
+	return returns.TaskID, returns.Error
 }
 
 func (c *BOSHClient) UploadStemcell(contents bosh.SizeReader) (int, error) {
-	c.UploadStemcellCall.CallCount++
+	c.record("UploadStemcell", contents)
 	c.UploadStemcellCall.Receives.Contents = contents
 
-	return c.UploadStemcellCall.Returns.TaskID, c.UploadStemcellCall.Returns.Error
+	returns := c.UploadStemcellCall.Returns
+	if r, ok := c.UploadStemcellCall.ReturnsOnCall[c.UploadStemcellCall.CallCount]; ok {
+		returns = r
+	}
+	c.UploadStemcellCall.CallCount++
+
+	return returns.TaskID, returns.Error
+}
+
+func (c *BOSHClient) UploadReleaseURL(url, sha1 string) (int, error) {
+	c.record("UploadReleaseURL", url, sha1)
+	c.UploadReleaseURLCall.Receives.URL = url
+	c.UploadReleaseURLCall.Receives.SHA1 = sha1
+
+	returns := c.UploadReleaseURLCall.Returns
+	if r, ok := c.UploadReleaseURLCall.ReturnsOnCall[c.UploadReleaseURLCall.CallCount]; ok {
+		returns = r
+	}
+	c.UploadReleaseURLCall.CallCount++
+
+	return returns.TaskID, returns.Error
+}
+
+func (c *BOSHClient) UploadStemcellURL(url, sha1 string) (int, error) {
+	c.record("UploadStemcellURL", url, sha1)
+	c.UploadStemcellURLCall.Receives.URL = url
+	c.UploadStemcellURLCall.Receives.SHA1 = sha1
+
+	returns := c.UploadStemcellURLCall.Returns
+	if r, ok := c.UploadStemcellURLCall.ReturnsOnCall[c.UploadStemcellURLCall.CallCount]; ok {
+		returns = r
+	}
+	c.UploadStemcellURLCall.CallCount++
+
+	return returns.TaskID, returns.Error
 }
 
 func (c *BOSHClient) DeleteDeployment(name string) error {
+	c.record("DeleteDeployment", name)
 	c.DeleteDeploymentCall.Receives.Name = append(c.DeleteDeploymentCall.Receives.Name, name)
 
-	return c.DeleteDeploymentCall.Returns.Error
+	returns := c.DeleteDeploymentCall.Returns
+	if r, ok := c.DeleteDeploymentCall.ReturnsOnCall[c.DeleteDeploymentCall.CallCount]; ok {
+		returns = r
+	}
+	c.DeleteDeploymentCall.CallCount++
+
+	return returns.Error
 }
 
 func (c *BOSHClient) Cleanup() (int, error) {
+	c.record("Cleanup")
+
+	returns := c.CleanupCall.Returns
+	if r, ok := c.CleanupCall.ReturnsOnCall[c.CleanupCall.CallCount]; ok {
+		returns = r
+	}
 	c.CleanupCall.CallCount++
 
-	return c.CleanupCall.Returns.TaskID, c.CleanupCall.Returns.Error
+	return returns.TaskID, returns.Error
 }
 
 func (c *BOSHClient) Deployments() ([]bosh.Deployment, error) {
+	c.record("Deployments")
+
+	returns := c.DeploymentsCall.Returns
+	if r, ok := c.DeploymentsCall.ReturnsOnCall[c.DeploymentsCall.CallCount]; ok {
+		returns = r
+	}
 	c.DeploymentsCall.CallCount++
 
-	return c.DeploymentsCall.Returns.DeploymentList, c.DeploymentsCall.Returns.Error
+	return returns.DeploymentList, returns.Error
 }
 
 func (c *BOSHClient) Stemcell(name string) (bosh.Stemcell, error) {
-	c.StemcellCall.CallCount++
+	c.record("Stemcell", name)
 	c.StemcellCall.Receives = name
 
-	return c.StemcellCall.Returns.Stemcell, c.StemcellCall.Returns.Error
+	returns := c.StemcellCall.Returns
+	if r, ok := c.StemcellCall.ReturnsOnCall[c.StemcellCall.CallCount]; ok {
+		returns = r
+	}
+	c.StemcellCall.CallCount++
+
+	return returns.Stemcell, returns.Error
+}
+
+func (c *BOSHClient) Release(name string) (bosh.Release, error) {
+	c.record("Release", name)
+	c.ReleaseCall.Receives = name
+
+	returns := c.ReleaseCall.Returns
+	if r, ok := c.ReleaseCall.ReturnsOnCall[c.ReleaseCall.CallCount]; ok {
+		returns = r
+	}
+	c.ReleaseCall.CallCount++
+
+	return returns.Release, returns.Error
 }
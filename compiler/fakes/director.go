@@ -0,0 +1,597 @@
+package fakes
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"gopkg.in/yaml.v2"
+)
+
+// Director is an in-process BOSH director serving enough of the director API
+// for a compile: /info, /stemcells, /releases, /deployments, /cleanup,
+// /configs, /tasks and /resources. Requests that start a task are redirected
+// to the task, whose states are scripted per operation with ScriptTask.
+type Director struct {
+	Info            compiler.DirectorInfo
+	Username        string
+	Password        string
+	ExportedRelease []byte
+
+	server      *httptest.Server
+	mutex       sync.Mutex
+	requests    []string
+	stemcells   []DirectorStemcell
+	releases    map[string][]string
+	contents    map[string]compiler.DirectorRelease
+	deployments map[string][]byte
+	manifests   [][]byte
+	configs     []compiler.Config
+	resources   map[string][]byte
+	tasks       map[int]*directorTask
+	scripts     map[string][]TaskScript
+}
+
+type DirectorStemcell struct {
+	Name       string `json:"name"`
+	OS         string `json:"operating_system"`
+	Version    string `json:"version"`
+	APIVersion int    `json:"api_version"`
+}
+
+// TaskScript describes a task: the states reported by successive polls of
+// it, its result output, and the error reported when it ends in "error".
+type TaskScript struct {
+	States []string
+	Result string
+	Error  string
+}
+
+type directorTask struct {
+	id          int
+	description string
+	script      TaskScript
+	polls       int
+}
+
+func NewDirector(username, password string) *Director {
+	d := &Director{
+		Info: compiler.DirectorInfo{
+			Name:    "fake-director",
+			UUID:    "fake-director-uuid",
+			Version: "270.2.0 (00000000)",
+			CPI:     "warden_cpi",
+		},
+		Username:    username,
+		Password:    password,
+		releases:    map[string][]string{},
+		contents:    map[string]compiler.DirectorRelease{},
+		deployments: map[string][]byte{},
+		resources:   map[string][]byte{},
+		tasks:       map[int]*directorTask{},
+		scripts:     map[string][]TaskScript{},
+	}
+	d.server = httptest.NewServer(http.HandlerFunc(d.serveHTTP))
+
+	return d
+}
+
+func (d *Director) URL() string {
+	return d.server.URL
+}
+
+func (d *Director) Close() {
+	d.server.Close()
+}
+
+// ScriptTask queues the script for the next task of the operation, one of
+// "upload_stemcell", "upload_release", "deploy", "delete_deployment",
+// "cleanup" or "export_release". Unscripted tasks are done at once.
+func (d *Director) ScriptTask(operation string, script TaskScript) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.scripts[operation] = append(d.scripts[operation], script)
+}
+
+func (d *Director) AddStemcell(name, os, version string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.stemcells = append(d.stemcells, DirectorStemcell{Name: name, OS: os, Version: version})
+}
+
+func (d *Director) AddRelease(name string, versions ...string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.releases[name] = append(d.releases[name], versions...)
+}
+
+// AddReleaseVersion adds a version of the release with its jobs and packages.
+func (d *Director) AddReleaseVersion(name, version string, contents compiler.DirectorRelease) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.releases[name] = append(d.releases[name], version)
+	d.contents[name+"/"+version] = contents
+}
+
+func (d *Director) AddDeployment(name string, manifest []byte) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.deployments[name] = manifest
+}
+
+func (d *Director) AddConfig(config compiler.Config) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.configs = append(d.configs, config)
+}
+
+// Requests returns each request served so far as "METHOD /path".
+func (d *Director) Requests() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return append([]string{}, d.requests...)
+}
+
+func (d *Director) Stemcells() []DirectorStemcell {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return append([]DirectorStemcell{}, d.stemcells...)
+}
+
+func (d *Director) Releases() map[string][]string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	releases := map[string][]string{}
+	for name, versions := range d.releases {
+		releases[name] = append([]string{}, versions...)
+	}
+
+	return releases
+}
+
+func (d *Director) Deployments() map[string][]byte {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	deployments := map[string][]byte{}
+	for name, manifest := range d.deployments {
+		deployments[name] = manifest
+	}
+
+	return deployments
+}
+
+// DeployedManifests returns the manifest of each deploy so far.
+func (d *Director) DeployedManifests() [][]byte {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return append([][]byte{}, d.manifests...)
+}
+
+// Configs returns the latest config of each type and name.
+func (d *Director) Configs() []compiler.Config {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.latestConfigs("")
+}
+
+func (d *Director) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !d.authorize(w, r) {
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	route := fmt.Sprintf("%s /%s", r.Method, segments[0])
+	export := len(segments) == 2 && segments[1] == "export"
+
+	// Uploads read the tarball, fetching it for an upload by url, before they
+	// take the lock.
+	switch {
+	case route == "POST /stemcells":
+		d.upload(w, r, "upload_stemcell", "stemcell.MF")
+		return
+	case route == "POST /releases" && !export:
+		d.upload(w, r, "upload_release", "release.MF")
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	switch {
+	case route == "GET /info":
+		d.writeJSON(w, d.Info)
+	case route == "GET /stemcells":
+		d.writeJSON(w, d.stemcells)
+	case route == "GET /releases" && len(segments) == 2:
+		d.getRelease(w, segments[1], r.URL.Query().Get("version"))
+	case route == "GET /releases":
+		d.listReleases(w)
+	case route == "POST /releases":
+		d.exportRelease(w, r)
+	case route == "GET /deployments":
+		d.listDeployments(w)
+	case route == "POST /deployments":
+		d.deploy(w, r)
+	case route == "DELETE /deployments" && len(segments) == 2:
+		delete(d.deployments, segments[1])
+		d.startTask(w, "delete_deployment", fmt.Sprintf("delete deployment %s", segments[1]), "")
+	case route == "POST /cleanup":
+		d.startTask(w, "cleanup", "clean up", "")
+	case route == "GET /configs":
+		d.writeJSON(w, d.latestConfigs(r.URL.Query().Get("type")))
+	case route == "POST /configs":
+		d.updateConfig(w, r)
+	case route == "GET /tasks" && len(segments) >= 2:
+		d.getTask(w, r, segments[1:])
+	case route == "GET /resources" && len(segments) == 2:
+		d.getResource(w, segments[1])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// authorize records the request and checks its credentials, which all but
+// GET /info require.
+func (d *Director) authorize(w http.ResponseWriter, r *http.Request) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.requests = append(d.requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+
+	if r.URL.Path == "/info" && r.Method == "GET" {
+		return true
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok || username != d.Username || password != d.Password {
+		d.writeJSONStatus(w, http.StatusUnauthorized, map[string]interface{}{"code": 600000, "description": "Not authorized"})
+		return false
+	}
+
+	return true
+}
+
+func (d *Director) upload(w http.ResponseWriter, r *http.Request, operation, manifestName string) {
+	var tarball io.Reader = r.Body
+	if r.Header.Get("Content-Type") == "application/json" {
+		var remote struct {
+			Location string `json:"location"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&remote)
+		if err != nil {
+			d.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		response, err := http.Get(remote.Location)
+		if err != nil {
+			d.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		defer response.Body.Close()
+
+		tarball = response.Body
+	}
+
+	var manifest struct {
+		Name            string                          `yaml:"name"`
+		OperatingSystem string                          `yaml:"operating_system"`
+		Version         string                          `yaml:"version"`
+		APIVersion      int                             `yaml:"api_version"`
+		Jobs            []compiler.DirectorReleaseEntry `yaml:"jobs"`
+		Packages        []compiler.DirectorReleaseEntry `yaml:"packages"`
+	}
+	err := readTarballManifest(tarball, manifestName, &manifest)
+	if err != nil {
+		d.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if operation == "upload_stemcell" {
+		name := manifest.Name
+		if name == "" {
+			name = manifest.OperatingSystem
+		}
+		d.stemcells = append(d.stemcells, DirectorStemcell{Name: name, OS: manifest.OperatingSystem, Version: manifest.Version, APIVersion: manifest.APIVersion})
+		d.startTask(w, operation, "create stemcell", "")
+		return
+	}
+
+	d.releases[manifest.Name] = append(d.releases[manifest.Name], manifest.Version)
+	d.contents[manifest.Name+"/"+manifest.Version] = compiler.DirectorRelease{Jobs: manifest.Jobs, Packages: manifest.Packages}
+	d.startTask(w, operation, "create release", "")
+}
+
+// getRelease serves the versions of the release or, given a version, the jobs
+// and packages of that version.
+func (d *Director) getRelease(w http.ResponseWriter, name, version string) {
+	versions, ok := d.releases[name]
+	if !ok {
+		d.writeJSONStatus(w, http.StatusNotFound, map[string]interface{}{"code": 30005, "description": fmt.Sprintf("Release '%s' doesn't exist", name)})
+		return
+	}
+
+	if version != "" {
+		contents := d.contents[name+"/"+version]
+		if contents.Jobs == nil {
+			contents.Jobs = []compiler.DirectorReleaseEntry{}
+		}
+		if contents.Packages == nil {
+			contents.Packages = []compiler.DirectorReleaseEntry{}
+		}
+
+		d.writeJSON(w, contents)
+		return
+	}
+
+	d.writeJSON(w, map[string]interface{}{"versions": versions, "jobs": []string{}, "packages": []string{}})
+}
+
+func (d *Director) listReleases(w http.ResponseWriter) {
+	type releaseVersion struct {
+		Version string `json:"version"`
+	}
+	type release struct {
+		Name            string           `json:"name"`
+		ReleaseVersions []releaseVersion `json:"release_versions"`
+	}
+
+	releases := []release{}
+	for name, versions := range d.releases {
+		r := release{Name: name}
+		for _, version := range versions {
+			r.ReleaseVersions = append(r.ReleaseVersions, releaseVersion{Version: version})
+		}
+		releases = append(releases, r)
+	}
+
+	d.writeJSON(w, releases)
+}
+
+func (d *Director) listDeployments(w http.ResponseWriter) {
+	type deployment struct {
+		Name string `json:"name"`
+	}
+
+	deployments := []deployment{}
+	for name := range d.deployments {
+		deployments = append(deployments, deployment{Name: name})
+	}
+
+	d.writeJSON(w, deployments)
+}
+
+func (d *Director) deploy(w http.ResponseWriter, r *http.Request) {
+	manifest, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		d.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var deployment struct {
+		Name string `yaml:"name"`
+	}
+	err = yaml.Unmarshal(manifest, &deployment)
+	if err != nil || deployment.Name == "" {
+		d.writeError(w, http.StatusBadRequest, fmt.Errorf("manifest has no name: %v", err))
+		return
+	}
+
+	d.deployments[deployment.Name] = manifest
+	d.manifests = append(d.manifests, manifest)
+	d.startTask(w, "deploy", fmt.Sprintf("create deployment %s", deployment.Name), "")
+}
+
+func (d *Director) exportRelease(w http.ResponseWriter, r *http.Request) {
+	var export struct {
+		DeploymentName string `json:"deployment_name"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&export)
+	if err != nil {
+		d.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, ok := d.deployments[export.DeploymentName]; !ok {
+		d.writeError(w, http.StatusNotFound, fmt.Errorf("Deployment '%s' doesn't exist", export.DeploymentName))
+		return
+	}
+
+	blobstoreID := fmt.Sprintf("exported-release-%d", len(d.resources)+1)
+	d.resources[blobstoreID] = d.ExportedRelease
+
+	result, err := json.Marshal(map[string]string{
+		"blobstore_id": blobstoreID,
+		"sha1":         fmt.Sprintf("%x", sha1.Sum(d.ExportedRelease)),
+	})
+	if err != nil {
+		d.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	d.startTask(w, "export_release", "export release", string(result))
+}
+
+func (d *Director) latestConfigs(configType string) []compiler.Config {
+	configs := []compiler.Config{}
+	for i := len(d.configs) - 1; i >= 0; i-- {
+		config := d.configs[i]
+		if configType != "" && config.Type != configType {
+			continue
+		}
+
+		seen := false
+		for _, latest := range configs {
+			if latest.Type == config.Type && latest.Name == config.Name {
+				seen = true
+			}
+		}
+
+		if !seen {
+			configs = append([]compiler.Config{config}, configs...)
+		}
+	}
+
+	return configs
+}
+
+func (d *Director) updateConfig(w http.ResponseWriter, r *http.Request) {
+	var config compiler.Config
+	err := json.NewDecoder(r.Body).Decode(&config)
+	if err != nil {
+		d.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	d.configs = append(d.configs, config)
+	d.writeJSONStatus(w, http.StatusCreated, config)
+}
+
+// startTask redirects the request to a new task running the next script of
+// the operation.
+func (d *Director) startTask(w http.ResponseWriter, operation, description, result string) {
+	script := TaskScript{States: []string{"done"}, Result: result}
+	if scripts := d.scripts[operation]; len(scripts) > 0 {
+		script = scripts[0]
+		d.scripts[operation] = scripts[1:]
+
+		if script.Result == "" {
+			script.Result = result
+		}
+	}
+
+	task := &directorTask{id: len(d.tasks) + 1, description: description, script: script}
+	d.tasks[task.id] = task
+
+	w.Header().Set("Location", fmt.Sprintf("%s/tasks/%d", d.server.URL, task.id))
+	w.WriteHeader(http.StatusFound)
+}
+
+func (d *Director) getTask(w http.ResponseWriter, r *http.Request, segments []string) {
+	id, err := strconv.Atoi(segments[0])
+	if err != nil || d.tasks[id] == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	task := d.tasks[id]
+
+	if len(segments) == 2 && segments[1] == "output" {
+		switch r.URL.Query().Get("type") {
+		case "result":
+			w.Write([]byte(task.script.Result))
+		case "event":
+			if task.state() == "error" {
+				event, _ := json.Marshal(map[string]interface{}{"error": map[string]interface{}{"code": 100, "message": task.script.Error}})
+				w.Write(append(event, '\n'))
+			}
+		}
+		return
+	}
+
+	state := task.state()
+	if task.polls < len(task.script.States)-1 {
+		task.polls++
+	}
+
+	result := ""
+	if state == "error" {
+		result = task.script.Error
+	}
+
+	d.writeJSON(w, map[string]interface{}{
+		"id":          task.id,
+		"state":       state,
+		"description": task.description,
+		"result":      result,
+	})
+}
+
+func (t *directorTask) state() string {
+	if len(t.script.States) == 0 {
+		return "done"
+	}
+
+	return t.script.States[t.polls]
+}
+
+func (d *Director) getResource(w http.ResponseWriter, id string) {
+	resource, ok := d.resources[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Write(resource)
+}
+
+func (d *Director) writeJSON(w http.ResponseWriter, v interface{}) {
+	d.writeJSONStatus(w, http.StatusOK, v)
+}
+
+// writeJSONStatus sets the content type before the status, since headers set
+// after WriteHeader are not sent.
+func (d *Director) writeJSONStatus(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (d *Director) writeError(w http.ResponseWriter, status int, err error) {
+	d.writeJSONStatus(w, status, map[string]interface{}{"code": 10000, "description": err.Error()})
+}
+
+func readTarballManifest(reader io.Reader, manifestName string, manifest interface{}) error {
+	gr, err := gzip.NewReader(reader)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("could not find %s", manifestName)
+		}
+		if err != nil {
+			return err
+		}
+
+		if filepath.Base(header.Name) == manifestName {
+			var content bytes.Buffer
+			_, err = io.Copy(&content, tr)
+			if err != nil {
+				return err
+			}
+
+			return yaml.Unmarshal(content.Bytes(), manifest)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package fakes
+
+import "github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+// DirectorClient records every call made to it in Calls, in order, like
+// BOSHClient, alongside the arguments of the latest call of each method in its
+// Receives.
+type DirectorClient struct {
+	Calls []Call
+
+	InfoCall struct {
+		CallCount int
+		Returns   struct {
+			DirectorInfo compiler.DirectorInfo
+			Error        error
+		}
+	}
+
+	ConfigsCall struct {
+		CallCount int
+		Receives  struct {
+			ConfigType string
+		}
+		Returns       ConfigsReturns
+		ReturnsOnCall map[int]ConfigsReturns
+	}
+
+	StemcellsCall struct {
+		CallCount int
+		Returns   struct {
+			Stemcells []compiler.DirectorStemcell
+			Error     error
+		}
+	}
+
+	ReleaseVersionCall struct {
+		CallCount int
+		Receives  struct {
+			Name    string
+			Version string
+		}
+		Returns struct {
+			Release compiler.DirectorRelease
+			Error   error
+		}
+	}
+
+	UpdateConfigCall struct {
+		CallCount int
+		Receives  struct {
+			Config compiler.Config
+		}
+		Returns       UpdateConfigReturns
+		ReturnsOnCall map[int]UpdateConfigReturns
+	}
+}
+
+type UpdateConfigReturns struct {
+	Error error
+}
+
+type ConfigsReturns struct {
+	Configs map[string][]compiler.Config
+	Error   error
+}
+
+// CallsTo returns the calls made to the method, in order.
+func (c *DirectorClient) CallsTo(method string) []Call {
+	var calls []Call
+	for _, call := range c.Calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+func (c *DirectorClient) record(method string, args ...interface{}) {
+	c.Calls = append(c.Calls, Call{Method: method, Args: args})
+}
+
+func (c *DirectorClient) Info() (compiler.DirectorInfo, error) {
+	c.record("Info")
+	c.InfoCall.CallCount++
+
+	return c.InfoCall.Returns.DirectorInfo, c.InfoCall.Returns.Error
+}
+
+func (c *DirectorClient) Configs(configType string) ([]compiler.Config, error) {
+	c.record("Configs", configType)
+	c.ConfigsCall.Receives.ConfigType = configType
+
+	returns := c.ConfigsCall.Returns
+	if r, ok := c.ConfigsCall.ReturnsOnCall[c.ConfigsCall.CallCount]; ok {
+		returns = r
+	}
+	c.ConfigsCall.CallCount++
+
+	return returns.Configs[configType], returns.Error
+}
+
+// UpdateConfig replaces the config of the same name in the configs it returns
+// unless it fails.
+func (c *DirectorClient) UpdateConfig(config compiler.Config) error {
+	c.record("UpdateConfig", config)
+	c.UpdateConfigCall.Receives.Config = config
+
+	returns := c.UpdateConfigCall.Returns
+	if r, ok := c.UpdateConfigCall.ReturnsOnCall[c.UpdateConfigCall.CallCount]; ok {
+		returns = r
+	}
+	c.UpdateConfigCall.CallCount++
+
+	if returns.Error != nil {
+		return returns.Error
+	}
+
+	configs := c.ConfigsCall.Returns.Configs[config.Type]
+	for i, existing := range configs {
+		if existing.Name == config.Name {
+			updated := append([]compiler.Config{}, configs...)
+			updated[i] = config
+			c.ConfigsCall.Returns.Configs[config.Type] = updated
+			break
+		}
+	}
+
+	return nil
+}
+
+func (c *DirectorClient) Stemcells() ([]compiler.DirectorStemcell, error) {
+	c.record("Stemcells")
+	c.StemcellsCall.CallCount++
+
+	return c.StemcellsCall.Returns.Stemcells, c.StemcellsCall.Returns.Error
+}
+
+func (c *DirectorClient) ReleaseVersion(name, version string) (compiler.DirectorRelease, error) {
+	c.record("ReleaseVersion", name, version)
+	c.ReleaseVersionCall.CallCount++
+	c.ReleaseVersionCall.Receives.Name = name
+	c.ReleaseVersionCall.Receives.Version = version
+
+	return c.ReleaseVersionCall.Returns.Release, c.ReleaseVersionCall.Returns.Error
+}
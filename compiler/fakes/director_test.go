@@ -0,0 +1,61 @@
+package fakes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Director", func() {
+	var director *fakes.Director
+
+	BeforeEach(func() {
+		director = fakes.NewDirector("some-user", "some-password")
+	})
+
+	AfterEach(func() {
+		director.Close()
+	})
+
+	request := func(method, path, username string, body []byte) *http.Response {
+		request, err := http.NewRequest(method, director.URL()+path, bytes.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		request.SetBasicAuth(username, "some-password")
+		request.Header.Set("Content-Type", "application/json")
+
+		response, err := http.DefaultClient.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+
+		return response
+	}
+
+	expectJSONError := func(response *http.Response, status int, description string) {
+		defer response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(status))
+		Expect(response.Header.Get("Content-Type")).To(Equal("application/json"))
+
+		var directorError struct {
+			Description string `json:"description"`
+		}
+		Expect(json.NewDecoder(response.Body).Decode(&directorError)).To(Succeed())
+		Expect(directorError.Description).To(ContainSubstring(description))
+	}
+
+	It("sends a json error when the credentials are wrong", func() {
+		expectJSONError(request("GET", "/deployments", "some-wrong-user", nil), http.StatusUnauthorized, "Not authorized")
+	})
+
+	It("sends a json error when the release does not exist", func() {
+		expectJSONError(request("GET", "/releases/some-release", "some-user", nil), http.StatusNotFound, "Release 'some-release' doesn't exist")
+	})
+
+	It("sends a json error when the upload cannot be read", func() {
+		expectJSONError(request("POST", "/releases", "some-user", []byte("not json")), http.StatusBadRequest, "invalid character")
+	})
+})
@@ -0,0 +1,11 @@
+package fakes
+
+import "github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+type EventLogger struct {
+	Events []compiler.Event
+}
+
+func (l *EventLogger) Event(event compiler.Event) {
+	l.Events = append(l.Events, event)
+}
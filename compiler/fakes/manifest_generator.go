@@ -1,6 +1,6 @@
 package fakes
 
-import "github.com/pivotal-cf/pcf-releng-ci/tasks/future/compile-release/compiler"
+import "github.com/aditya87/precompiled-bosh-release-resource/compiler"
 
 type ManifestGenerator struct {
 	GenerateCall struct {
@@ -9,6 +9,7 @@ type ManifestGenerator struct {
 			DirectorUUID   string
 			Stemcell       compiler.Stemcell
 			Release        compiler.Release
+			Settings       compiler.DeploymentSettings
 		}
 		Returns struct {
 			Manifest []byte
@@ -17,11 +18,12 @@ type ManifestGenerator struct {
 	}
 }
 
-func (g *ManifestGenerator) Generate(directorUUID, deploymentName string, release compiler.Release, stemcell compiler.Stemcell) ([]byte, error) {
+func (g *ManifestGenerator) Generate(directorUUID, deploymentName string, release compiler.Release, stemcell compiler.Stemcell, settings compiler.DeploymentSettings) ([]byte, error) {
 	g.GenerateCall.Receives.DirectorUUID = directorUUID
 	g.GenerateCall.Receives.Release = release
 	g.GenerateCall.Receives.Stemcell = stemcell
 	g.GenerateCall.Receives.DeploymentName = deploymentName
+	g.GenerateCall.Receives.Settings = settings
 
 	return g.GenerateCall.Returns.Manifest, g.GenerateCall.Returns.Error
 }
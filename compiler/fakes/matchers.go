@@ -0,0 +1,85 @@
+package fakes
+
+import (
+	"fmt"
+
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+)
+
+// HaveCalledInOrder succeeds when the calls include calls to the methods in
+// the given order, though not necessarily one after the other.
+func HaveCalledInOrder(methods ...string) types.GomegaMatcher {
+	return &calledInOrderMatcher{methods: methods}
+}
+
+// HaveCalledBefore succeeds when the first call to the method comes before
+// the first call to the later method.
+func HaveCalledBefore(method, later string) types.GomegaMatcher {
+	return &calledInOrderMatcher{methods: []string{method, later}, first: true}
+}
+
+type calledInOrderMatcher struct {
+	methods []string
+	first   bool
+}
+
+func (m *calledInOrderMatcher) Match(actual interface{}) (bool, error) {
+	calls, ok := actual.([]Call)
+	if !ok {
+		return false, fmt.Errorf("HaveCalledInOrder expects a []fakes.Call, got\n%s", format.Object(actual, 1))
+	}
+
+	if m.first {
+		return indexOf(calls, m.methods[0]) != -1 && indexOf(calls, m.methods[0]) < indexOf(calls, m.methods[1]), nil
+	}
+
+	next := 0
+	for _, call := range calls {
+		if next < len(m.methods) && call.Method == m.methods[next] {
+			next++
+		}
+	}
+
+	return next == len(m.methods), nil
+}
+
+func (m *calledInOrderMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected calls\n%s\nto %s", formatCalls(actual), m.expectation())
+}
+
+func (m *calledInOrderMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected calls\n%s\nnot to %s", formatCalls(actual), m.expectation())
+}
+
+func (m *calledInOrderMatcher) expectation() string {
+	if m.first {
+		return fmt.Sprintf("first call %s before %s", m.methods[0], m.methods[1])
+	}
+
+	return fmt.Sprintf("call %v in that order", m.methods)
+}
+
+func indexOf(calls []Call, method string) int {
+	for i, call := range calls {
+		if call.Method == method {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func formatCalls(actual interface{}) string {
+	calls, ok := actual.([]Call)
+	if !ok {
+		return format.Object(actual, 1)
+	}
+
+	methods := make([]string, 0, len(calls))
+	for _, call := range calls {
+		methods = append(methods, call.Method)
+	}
+
+	return format.Object(methods, 1)
+}
@@ -0,0 +1,78 @@
+package fakes_test
+
+import (
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Matchers", func() {
+	var calls []fakes.Call
+
+	BeforeEach(func() {
+		calls = []fakes.Call{
+			{Method: "Configs", Args: []interface{}{"runtime"}},
+			{Method: "UpdateConfig"},
+			{Method: "Deploy"},
+			{Method: "Configs", Args: []interface{}{"runtime"}},
+			{Method: "UpdateConfig"},
+		}
+	})
+
+	Describe("HaveCalledInOrder", func() {
+		It("succeeds when the methods are called in the order given", func() {
+			Expect(calls).To(fakes.HaveCalledInOrder("UpdateConfig", "Configs", "UpdateConfig"))
+			Expect(calls).To(fakes.HaveCalledInOrder("Configs", "Deploy"))
+		})
+
+		It("fails when the methods are not called in the order given", func() {
+			Expect(calls).NotTo(fakes.HaveCalledInOrder("Deploy", "Deploy"))
+			Expect(calls).NotTo(fakes.HaveCalledInOrder("UpdateConfig", "UpdateConfig", "UpdateConfig"))
+			Expect(calls).NotTo(fakes.HaveCalledInOrder("Cleanup"))
+		})
+
+		It("returns an error unless given calls", func() {
+			_, err := fakes.HaveCalledInOrder("Deploy").Match([]string{"Deploy"})
+			Expect(err).To(MatchError(ContainSubstring("HaveCalledInOrder expects a []fakes.Call, got")))
+		})
+
+		It("lists the methods called in its failure messages", func() {
+			matcher := fakes.HaveCalledInOrder("Deploy", "Configs")
+
+			Expect(matcher.FailureMessage(calls)).To(Equal(`Expected calls
+    <[]string | len:5, cap:5>: ["Configs", "UpdateConfig", "Deploy", "Configs", "UpdateConfig"]
+to call [Deploy Configs] in that order`))
+			Expect(matcher.NegatedFailureMessage(calls)).To(Equal(`Expected calls
+    <[]string | len:5, cap:5>: ["Configs", "UpdateConfig", "Deploy", "Configs", "UpdateConfig"]
+not to call [Deploy Configs] in that order`))
+		})
+	})
+
+	Describe("HaveCalledBefore", func() {
+		It("succeeds when the first call to the method comes before the first call to the later method", func() {
+			Expect(calls).To(fakes.HaveCalledBefore("Configs", "UpdateConfig"))
+			Expect(calls).To(fakes.HaveCalledBefore("UpdateConfig", "Deploy"))
+		})
+
+		It("fails when the first call to the later method comes first", func() {
+			Expect(calls).NotTo(fakes.HaveCalledBefore("Deploy", "Configs"))
+		})
+
+		It("fails when either method is not called", func() {
+			Expect(calls).NotTo(fakes.HaveCalledBefore("Cleanup", "Deploy"))
+			Expect(calls).NotTo(fakes.HaveCalledBefore("Deploy", "Cleanup"))
+		})
+
+		It("lists the methods called in its failure messages", func() {
+			matcher := fakes.HaveCalledBefore("Deploy", "Configs")
+
+			Expect(matcher.FailureMessage(calls)).To(Equal(`Expected calls
+    <[]string | len:5, cap:5>: ["Configs", "UpdateConfig", "Deploy", "Configs", "UpdateConfig"]
+to first call Deploy before Configs`))
+			Expect(matcher.NegatedFailureMessage(calls)).To(Equal(`Expected calls
+    <[]string | len:5, cap:5>: ["Configs", "UpdateConfig", "Deploy", "Configs", "UpdateConfig"]
+not to first call Deploy before Configs`))
+		})
+	})
+})
@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"errors"
 
-	"github.com/pivotal-cf/pcf-releng-ci/tasks/future/compile-release/compiler"
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -0,0 +1,71 @@
+package compiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// JSONLogger writes log lines and step events as JSON lines, one object per
+// line, for log pipelines to parse.
+type JSONLogger struct {
+	writer io.Writer
+	now    func() time.Time
+}
+
+type jsonLine struct {
+	Time       string   `json:"time"`
+	Message    string   `json:"message,omitempty"`
+	Step       string   `json:"step,omitempty"`
+	Release    string   `json:"release,omitempty"`
+	Stemcell   string   `json:"stemcell,omitempty"`
+	Deployment string   `json:"deployment,omitempty"`
+	TaskID     int      `json:"task_id,omitempty"`
+	Duration   *float64 `json:"duration,omitempty"`
+	Outcome    string   `json:"outcome,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+func NewJSONLogger(writer io.Writer, now func() time.Time) *JSONLogger {
+	return &JSONLogger{
+		writer: writer,
+		now:    now,
+	}
+}
+
+func (l *JSONLogger) Println(v ...interface{}) {
+	l.write(jsonLine{Message: strings.TrimSuffix(fmt.Sprintln(v...), "\n")})
+}
+
+func (l *JSONLogger) Printf(format string, v ...interface{}) {
+	l.write(jsonLine{Message: strings.TrimSuffix(fmt.Sprintf(format, v...), "\n")})
+}
+
+// Event writes the event with its duration in seconds.
+func (l *JSONLogger) Event(event Event) {
+	duration := event.Duration.Seconds()
+
+	l.write(jsonLine{
+		Step:       event.Step,
+		Release:    event.Release,
+		Stemcell:   event.Stemcell,
+		Deployment: event.Deployment,
+		TaskID:     event.TaskID,
+		Duration:   &duration,
+		Outcome:    event.Outcome,
+		Error:      event.Error,
+	})
+}
+
+func (l *JSONLogger) write(line jsonLine) {
+	line.Time = l.now().UTC().Format(time.RFC3339Nano)
+
+	content, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+
+	l.writer.Write(append(content, '\n'))
+}
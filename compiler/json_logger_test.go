@@ -0,0 +1,73 @@
+package compiler_test
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSONLogger", func() {
+	var (
+		output *bytes.Buffer
+		logger *compiler.JSONLogger
+	)
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		logger = compiler.NewJSONLogger(output, func() time.Time {
+			return time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC)
+		})
+	})
+
+	It("writes log lines as messages", func() {
+		logger.Println("deploying to bosh director")
+		logger.Printf("uploading release %s %s\n", "some-release", "42")
+
+		Expect(output.String()).To(Equal(`{"time":"2026-10-19T12:30:00Z","message":"deploying to bosh director"}
+{"time":"2026-10-19T12:30:00Z","message":"uploading release some-release 42"}
+`))
+	})
+
+	It("writes events with their duration in seconds", func() {
+		logger.Event(compiler.Event{
+			Step:       "deploy",
+			Release:    "some-release/42",
+			Stemcell:   "some-stemcell/1.2.3",
+			Deployment: "compile-release-some-guid",
+			TaskID:     7,
+			Duration:   1500 * time.Millisecond,
+			Outcome:    "succeeded",
+		})
+
+		Expect(output.String()).To(MatchJSON(`{
+			"time": "2026-10-19T12:30:00Z",
+			"step": "deploy",
+			"release": "some-release/42",
+			"stemcell": "some-stemcell/1.2.3",
+			"deployment": "compile-release-some-guid",
+			"task_id": 7,
+			"duration": 1.5,
+			"outcome": "succeeded"
+		}`))
+	})
+
+	It("writes the error of a failed step", func() {
+		logger.Event(compiler.Event{
+			Step:    "director_info",
+			Outcome: "failed",
+			Error:   "failed to get info",
+		})
+
+		Expect(output.String()).To(MatchJSON(`{
+			"time": "2026-10-19T12:30:00Z",
+			"step": "director_info",
+			"duration": 0,
+			"outcome": "failed",
+			"error": "failed to get info"
+		}`))
+	})
+})
@@ -0,0 +1,129 @@
+package compiler
+
+// Manifest models a BOSH v2 deployment manifest. Compilation and
+// ResourcePools are only understood by v1 directors without a cloud-config.
+type Manifest struct {
+	Name           string             `yaml:"name"`
+	DirectorUUID   string             `yaml:"director_uuid"`
+	Releases       []ManifestRelease  `yaml:"releases"`
+	Stemcells      []ManifestStemcell `yaml:"stemcells"`
+	Update         ManifestUpdate     `yaml:"update"`
+	InstanceGroups []InstanceGroup    `yaml:"instance_groups"`
+	Variables      []Variable         `yaml:"variables,omitempty"`
+	Addons         []Addon            `yaml:"addons,omitempty"`
+	Features       *Features          `yaml:"features,omitempty"`
+	Tags           map[string]string  `yaml:"tags,omitempty"`
+	Compilation    *Compilation       `yaml:"compilation,omitempty"`
+	ResourcePools  []ResourcePool     `yaml:"resource_pools,omitempty"`
+}
+
+type ManifestRelease struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	URL     string `yaml:"url,omitempty"`
+	SHA1    string `yaml:"sha1,omitempty"`
+}
+
+type ManifestStemcell struct {
+	Alias   string `yaml:"alias"`
+	OS      string `yaml:"os,omitempty"`
+	Name    string `yaml:"name,omitempty"`
+	Version string `yaml:"version"`
+}
+
+type ManifestUpdate struct {
+	Canaries        int    `yaml:"canaries"`
+	MaxInFlight     int    `yaml:"max_in_flight"`
+	CanaryWatchTime string `yaml:"canary_watch_time"`
+	UpdateWatchTime string `yaml:"update_watch_time"`
+	Serial          *bool  `yaml:"serial,omitempty"`
+}
+
+type InstanceGroup struct {
+	Name               string                 `yaml:"name"`
+	Instances          int                    `yaml:"instances"`
+	AZs                []string               `yaml:"azs,omitempty"`
+	VMType             string                 `yaml:"vm_type"`
+	VMExtensions       []string               `yaml:"vm_extensions,omitempty"`
+	Stemcell           string                 `yaml:"stemcell"`
+	PersistentDiskType string                 `yaml:"persistent_disk_type,omitempty"`
+	Networks           []InstanceGroupNetwork `yaml:"networks"`
+	Jobs               []Job                  `yaml:"jobs"`
+	Lifecycle          string                 `yaml:"lifecycle,omitempty"`
+	Update             *ManifestUpdate        `yaml:"update,omitempty"`
+	Properties         map[string]interface{} `yaml:"properties,omitempty"`
+}
+
+type InstanceGroupNetwork struct {
+	Name      string   `yaml:"name"`
+	StaticIPs []string `yaml:"static_ips,omitempty"`
+	Default   []string `yaml:"default,omitempty"`
+}
+
+type Job struct {
+	Name       string                 `yaml:"name"`
+	Release    string                 `yaml:"release"`
+	Properties map[string]interface{} `yaml:"properties,omitempty"`
+	Consumes   map[string]interface{} `yaml:"consumes,omitempty"`
+	Provides   map[string]interface{} `yaml:"provides,omitempty"`
+}
+
+type Variable struct {
+	Name    string                 `yaml:"name"`
+	Type    string                 `yaml:"type"`
+	Options map[string]interface{} `yaml:"options,omitempty"`
+}
+
+type Addon struct {
+	Name    string     `yaml:"name"`
+	Jobs    []Job      `yaml:"jobs"`
+	Include *Placement `yaml:"include,omitempty"`
+	Exclude *Placement `yaml:"exclude,omitempty"`
+}
+
+// Placement is an include or exclude rule of an addon.
+type Placement struct {
+	Deployments    []string            `yaml:"deployments,omitempty"`
+	Jobs           []PlacementJob      `yaml:"jobs,omitempty"`
+	InstanceGroups []string            `yaml:"instance_groups,omitempty"`
+	Stemcells      []PlacementStemcell `yaml:"stemcell,omitempty"`
+	Networks       []string            `yaml:"networks,omitempty"`
+	Teams          []string            `yaml:"teams,omitempty"`
+	Lifecycle      string              `yaml:"lifecycle,omitempty"`
+}
+
+type PlacementJob struct {
+	Name    string `yaml:"name"`
+	Release string `yaml:"release"`
+}
+
+type PlacementStemcell struct {
+	OS string `yaml:"os"`
+}
+
+type Features struct {
+	ConvergeVariables    *bool `yaml:"converge_variables,omitempty"`
+	RandomizeAZPlacement *bool `yaml:"randomize_az_placement,omitempty"`
+	UseDNSAddresses      *bool `yaml:"use_dns_addresses,omitempty"`
+	UseShortDNSAddresses *bool `yaml:"use_short_dns_addresses,omitempty"`
+	UseTmpfsConfig       *bool `yaml:"use_tmpfs_config,omitempty"`
+}
+
+type Compilation struct {
+	Workers             int                    `yaml:"workers"`
+	Network             string                 `yaml:"network"`
+	ReuseCompilationVMs bool                   `yaml:"reuse_compilation_vms,omitempty"`
+	CloudProperties     map[string]interface{} `yaml:"cloud_properties,omitempty"`
+}
+
+type ResourcePool struct {
+	Name            string                 `yaml:"name"`
+	Network         string                 `yaml:"network"`
+	Stemcell        ResourcePoolStemcell   `yaml:"stemcell"`
+	CloudProperties map[string]interface{} `yaml:"cloud_properties,omitempty"`
+}
+
+type ResourcePoolStemcell struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
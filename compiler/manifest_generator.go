@@ -1,6 +1,10 @@
 package compiler
 
-import "gopkg.in/yaml.v2"
+import (
+	"errors"
+
+	"gopkg.in/yaml.v2"
+)
 
 type guidGenerator func() string
 
@@ -8,43 +12,26 @@ type ManifestGenerator struct {
 	guidGenerator guidGenerator
 }
 
-type Manifest struct {
-	Name           string             `yaml:"name"`
-	DirectorUUID   string             `yaml:"director_uuid"`
-	Releases       []ManifestRelease  `yaml:"releases"`
-	Stemcells      []ManifestStemcell `yaml:"stemcells"`
-	Update         ManifestUpdate     `yaml:"update"`
-	InstanceGroups []interface{}      `yaml:"instance_groups"`
-}
-
-type ManifestRelease struct {
-	Name    string `yaml:"name"`
-	Version string `yaml:"version"`
-}
-
-type ManifestStemcell struct {
-	Alias   string `yaml:"alias"`
-	OS      string `yaml:"os"`
-	Version string `yaml:"version"`
-}
-
-type ManifestUpdate struct {
-	Canaries        int    `yaml:"canaries"`
-	MaxInFlight     int    `yaml:"max_in_flight"`
-	CanaryWatchTime string `yaml:"canary_watch_time"`
-	UpdateWatchTime string `yaml:"update_watch_time"`
-}
-
 func NewManifestGenerator() ManifestGenerator {
 	return ManifestGenerator{}
 }
 
-func (g ManifestGenerator) Generate(directorUUID, deploymentName string, release Release, stemcell Stemcell) ([]byte, error) {
+// Generate renders the compile deployment manifest. When the settings name a
+// vm_type and network, an instance group with no instances places the
+// deployment on the cloud-config without creating any VMs. The compilation
+// workers are left to the cloud-config's compilation block: a director with a
+// cloud-config rejects a manifest with one of its own.
+func (g ManifestGenerator) Generate(directorUUID, deploymentName string, release Release, stemcell Stemcell, settings DeploymentSettings) ([]byte, error) {
+	if (settings.VMType == "") != (settings.Network == "") {
+		return nil, errors.New("vm_type and network must be given together")
+	}
+
 	manifest := Manifest{
-		Name:         deploymentName,
-		DirectorUUID: directorUUID,
-		Releases:     []ManifestRelease{},
-		Stemcells:    []ManifestStemcell{},
+		Name:           deploymentName,
+		DirectorUUID:   directorUUID,
+		Releases:       []ManifestRelease{},
+		Stemcells:      []ManifestStemcell{},
+		InstanceGroups: []InstanceGroup{},
 		Update: ManifestUpdate{
 			Canaries:        1,
 			MaxInFlight:     1,
@@ -70,6 +57,21 @@ func (g ManifestGenerator) Generate(directorUUID, deploymentName string, release
 		},
 	)
 
+	if settings.VMType != "" && settings.Network != "" {
+		manifest.InstanceGroups = append(
+			manifest.InstanceGroups,
+			InstanceGroup{
+				Name:      "compiler",
+				Instances: 0,
+				AZs:       settings.AZs,
+				VMType:    settings.VMType,
+				Stemcell:  "default",
+				Networks:  []InstanceGroupNetwork{{Name: settings.Network}},
+				Jobs:      []Job{},
+			},
+		)
+	}
+
 	manifestYAML, err := yaml.Marshal(manifest)
 	if err != nil {
 		return nil, err
@@ -1,40 +1,109 @@
 package compiler_test
 
 import (
-	"github.com/pivotal-cf/pcf-releng-ci/tasks/future/compile-release/compiler"
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"gopkg.in/yaml.v2"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	. "github.com/pivotal-cf-experimental/gomegamatchers"
 )
 
 var _ = Describe("ManifestGenerator", func() {
-	It("creates a manifest with the given releases and stemcell", func() {
-		generator := compiler.NewManifestGenerator()
-		manifest, err := generator.Generate("some-director-uuid", "compiled-release-guid", compiler.Release{
+	var (
+		generator compiler.ManifestGenerator
+		release   compiler.Release
+		stemcell  compiler.Stemcell
+	)
+
+	BeforeEach(func() {
+		generator = compiler.NewManifestGenerator()
+		release = compiler.Release{
 			Name:    "some-release-name-1",
 			Version: "some-release-version-1",
-		}, compiler.Stemcell{
+		}
+		stemcell = compiler.Stemcell{
 			Name:    "some-stemcell-os",
 			Version: "some-stemcell-version",
+		}
+	})
+
+	generate := func(settings compiler.DeploymentSettings) compiler.Manifest {
+		manifestYAML, err := generator.Generate("some-director-uuid", "compiled-release-guid", release, stemcell, settings)
+		Expect(err).NotTo(HaveOccurred())
+
+		var manifest compiler.Manifest
+		err = yaml.UnmarshalStrict(manifestYAML, &manifest)
+		Expect(err).NotTo(HaveOccurred())
+
+		return manifest
+	}
+
+	expectedManifest := func() compiler.Manifest {
+		return compiler.Manifest{
+			Name:         "compiled-release-guid",
+			DirectorUUID: "some-director-uuid",
+			Releases: []compiler.ManifestRelease{
+				{Name: "some-release-name-1", Version: "some-release-version-1"},
+			},
+			Stemcells: []compiler.ManifestStemcell{
+				{Alias: "default", OS: "some-stemcell-os", Version: "some-stemcell-version"},
+			},
+			Update: compiler.ManifestUpdate{
+				Canaries:        1,
+				MaxInFlight:     1,
+				CanaryWatchTime: "1000-1001",
+				UpdateWatchTime: "1000-1001",
+			},
+			InstanceGroups: []compiler.InstanceGroup{},
+		}
+	}
+
+	It("creates a manifest with the given releases and stemcell", func() {
+		Expect(generate(compiler.DeploymentSettings{})).To(Equal(expectedManifest()))
+	})
+
+	It("places the deployment on the cloud config when a vm_type and network are given", func() {
+		expected := expectedManifest()
+		expected.InstanceGroups = []compiler.InstanceGroup{
+			{
+				Name:      "compiler",
+				Instances: 0,
+				AZs:       []string{"z1", "z2"},
+				VMType:    "some-vm-type",
+				Stemcell:  "default",
+				Networks:  []compiler.InstanceGroupNetwork{{Name: "some-network"}},
+				Jobs:      []compiler.Job{},
+			},
+		}
+
+		Expect(generate(compiler.DeploymentSettings{
+			VMType:  "some-vm-type",
+			Network: "some-network",
+			AZs:     []string{"z1", "z2"},
+		})).To(Equal(expected))
+	})
+
+	It("leaves the compilation block to the cloud config", func() {
+		manifestYAML, err := generator.Generate("some-director-uuid", "compiled-release-guid", release, stemcell, compiler.DeploymentSettings{
+			VMType:             "some-vm-type",
+			Network:            "some-network",
+			CompilationWorkers: 4,
 		})
 		Expect(err).NotTo(HaveOccurred())
-		Expect(manifest).To(MatchYAML(`---
-name: compiled-release-guid
-director_uuid: some-director-uuid
-releases:
-  - name: some-release-name-1
-    version: some-release-version-1
-stemcells:
-  - alias: default
-    os: some-stemcell-os
-    version: some-stemcell-version
-update:
-  canaries: 1
-  max_in_flight: 1
-  canary_watch_time: 1000-1001
-  update_watch_time: 1000-1001
-instance_groups: []
-`))
+
+		var manifest map[string]interface{}
+		err = yaml.Unmarshal(manifestYAML, &manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest).NotTo(HaveKey("compilation"))
+	})
+
+	It("returns an error when only a vm_type is given", func() {
+		_, err := generator.Generate("some-director-uuid", "compiled-release-guid", release, stemcell, compiler.DeploymentSettings{VMType: "some-vm-type"})
+		Expect(err).To(MatchError("vm_type and network must be given together"))
+	})
+
+	It("returns an error when only a network is given", func() {
+		_, err := generator.Generate("some-director-uuid", "compiled-release-guid", release, stemcell, compiler.DeploymentSettings{Network: "some-network"})
+		Expect(err).To(MatchError("vm_type and network must be given together"))
 	})
 })
@@ -0,0 +1,50 @@
+package compiler
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// generatedManifestKeys are the top-level keys that identify the compile
+// deployment, which a manifest template cannot override.
+var generatedManifestKeys = map[string]bool{
+	"name":          true,
+	"director_uuid": true,
+	"releases":      true,
+	"stemcells":     true,
+}
+
+// CustomizeManifest lays the other top-level keys of the template, such as
+// features, tags or update, over the generated manifest and then applies the
+// ops to the result.
+func CustomizeManifest(manifest, template []byte, ops []Op) ([]byte, error) {
+	var document map[interface{}]interface{}
+	err := yaml.Unmarshal(manifest, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	var templateDocument map[interface{}]interface{}
+	err = yaml.Unmarshal(template, &templateDocument)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse manifest template: %s", err)
+	}
+
+	if document == nil {
+		document = map[interface{}]interface{}{}
+	}
+
+	for key, value := range templateDocument {
+		if !generatedManifestKeys[fmt.Sprint(key)] {
+			document[key] = value
+		}
+	}
+
+	customized, err := ApplyOps(document, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(customized)
+}
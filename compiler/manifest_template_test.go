@@ -0,0 +1,72 @@
+package compiler_test
+
+import (
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CustomizeManifest", func() {
+	manifest := []byte(`---
+name: compile-release-some-guid
+releases:
+- name: some-release
+  version: "42"
+update:
+  canaries: 1
+instance_groups: []
+`)
+
+	It("lays the template over the generated manifest", func() {
+		customized, err := compiler.CustomizeManifest(manifest, []byte(`---
+name: template-name
+releases: []
+features:
+  use_dns_addresses: true
+update:
+  canaries: 5
+`), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(customized).To(MatchYAML(`---
+name: compile-release-some-guid
+releases:
+- name: some-release
+  version: "42"
+features:
+  use_dns_addresses: true
+update:
+  canaries: 5
+instance_groups: []
+`))
+	})
+
+	It("applies the ops after the template", func() {
+		customized, err := compiler.CustomizeManifest(manifest, []byte("tags: {team: releng}"), []compiler.Op{
+			{Type: "replace", Path: "/tags/team", Value: "compile"},
+			{Type: "remove", Path: "/update"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(customized).To(MatchYAML(`---
+name: compile-release-some-guid
+releases:
+- name: some-release
+  version: "42"
+tags:
+  team: compile
+instance_groups: []
+`))
+	})
+
+	Context("failure cases", func() {
+		It("returns an error when the template is not YAML", func() {
+			_, err := compiler.CustomizeManifest(manifest, []byte("%%%"), nil)
+			Expect(err).To(MatchError(ContainSubstring("could not parse manifest template")))
+		})
+
+		It("returns an error when an op cannot be applied", func() {
+			_, err := compiler.CustomizeManifest(manifest, nil, []compiler.Op{{Type: "remove", Path: "/missing"}})
+			Expect(err).To(MatchError(`expected to find a map key "missing" for path "/missing"`))
+		})
+	})
+})
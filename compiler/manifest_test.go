@@ -0,0 +1,152 @@
+package compiler_test
+
+import (
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"gopkg.in/yaml.v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manifest", func() {
+	roundTrip := func(document string) compiler.Manifest {
+		var manifest compiler.Manifest
+		err := yaml.UnmarshalStrict([]byte(document), &manifest)
+		Expect(err).NotTo(HaveOccurred())
+
+		output, err := yaml.Marshal(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output).To(MatchYAML(document))
+
+		return manifest
+	}
+
+	It("round-trips a v2 manifest", func() {
+		manifest := roundTrip(`---
+name: some-deployment
+director_uuid: some-director-uuid
+releases:
+- name: some-release
+  version: "42"
+  url: https://example.com/some-release.tgz
+  sha1: some-sha1
+stemcells:
+- alias: default
+  os: ubuntu-xenial
+  version: latest
+update:
+  canaries: 1
+  max_in_flight: 2
+  canary_watch_time: 1000-30000
+  update_watch_time: 1000-30000
+  serial: false
+instance_groups:
+- name: some-instance-group
+  instances: 2
+  azs: [z1, z2]
+  vm_type: default
+  vm_extensions: [public-lb]
+  stemcell: default
+  persistent_disk_type: 10GB
+  networks:
+  - name: default
+    static_ips: [10.0.0.10]
+    default: [dns, gateway]
+  jobs:
+  - name: some-job
+    release: some-release
+    properties:
+      port: 8080
+    consumes:
+      db: {from: some-db}
+    provides:
+      api: {as: some-api}
+  lifecycle: service
+  update:
+    canaries: 2
+    max_in_flight: 1
+    canary_watch_time: 1000-1001
+    update_watch_time: 1000-1001
+  properties:
+    some: property
+variables:
+- name: some-password
+  type: password
+- name: some-cert
+  type: certificate
+  options:
+    is_ca: true
+    common_name: some-ca
+addons:
+- name: some-addon
+  jobs:
+  - name: some-agent
+    release: some-agent-release
+  include:
+    stemcell:
+    - os: ubuntu-xenial
+    jobs:
+    - name: some-job
+      release: some-release
+  exclude:
+    deployments: [other-deployment]
+    instance_groups: [other-instance-group]
+    networks: [other-network]
+    teams: [other-team]
+    lifecycle: errand
+features:
+  converge_variables: true
+  randomize_az_placement: false
+  use_dns_addresses: true
+  use_short_dns_addresses: false
+  use_tmpfs_config: true
+tags:
+  team: releng
+`)
+
+		Expect(manifest.InstanceGroups[0].Jobs[0].Release).To(Equal("some-release"))
+		Expect(manifest.Addons[0].Exclude.Deployments).To(Equal([]string{"other-deployment"}))
+		Expect(*manifest.Features.UseDNSAddresses).To(BeTrue())
+		Expect(manifest.Tags).To(Equal(map[string]string{"team": "releng"}))
+	})
+
+	It("round-trips a v1 manifest with compilation and resource pools", func() {
+		manifest := roundTrip(`---
+name: some-deployment
+director_uuid: some-director-uuid
+releases:
+- name: some-release
+  version: latest
+stemcells: []
+update:
+  canaries: 1
+  max_in_flight: 1
+  canary_watch_time: 1000-1001
+  update_watch_time: 1000-1001
+instance_groups: []
+compilation:
+  workers: 4
+  network: default
+  reuse_compilation_vms: true
+  cloud_properties:
+    instance_type: m4.large
+resource_pools:
+- name: default
+  network: default
+  stemcell:
+    name: bosh-aws-xen-hvm-ubuntu-trusty-go_agent
+    version: "3468"
+  cloud_properties:
+    instance_type: m4.large
+`)
+
+		Expect(manifest.Compilation.Workers).To(Equal(4))
+		Expect(manifest.ResourcePools[0].Stemcell.Version).To(Equal("3468"))
+	})
+
+	It("rejects unknown keys", func() {
+		var manifest compiler.Manifest
+		err := yaml.UnmarshalStrict([]byte("name: some-deployment\nunknown: true\n"), &manifest)
+		Expect(err).To(MatchError(ContainSubstring("field unknown not found")))
+	})
+})
@@ -0,0 +1,268 @@
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Op is a go-patch style operation: it replaces or removes the value found at
+// a path such as /instance_groups/name=compiler/vm_type.
+type Op struct {
+	Type  string      `yaml:"type"`
+	Path  string      `yaml:"path"`
+	Value interface{} `yaml:"value"`
+}
+
+type pathToken struct {
+	kind     string
+	key      string
+	index    int
+	value    string
+	optional bool
+}
+
+func ParseOps(content []byte) ([]Op, error) {
+	var ops []Op
+	err := yaml.Unmarshal(content, &ops)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range ops {
+		if op.Type != "replace" && op.Type != "remove" {
+			return nil, fmt.Errorf("unknown op type %q for path %q, expected replace or remove", op.Type, op.Path)
+		}
+	}
+
+	return ops, nil
+}
+
+// ApplyOps applies the ops in order to a document unmarshalled from YAML and
+// returns the resulting document.
+func ApplyOps(document interface{}, ops []Op) (interface{}, error) {
+	for _, op := range ops {
+		tokens, err := parsePath(op.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		document, err = op.apply(document, tokens, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return document, nil
+}
+
+// parsePath splits a path into tokens. A token is a map key, an array index,
+// "-" for the end of an array or key=value to find an array element. A
+// trailing "?" makes that token and all that follow it optional.
+func parsePath(path string) ([]pathToken, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path %q must start with /", path)
+	}
+
+	if path == "/" {
+		return nil, nil
+	}
+
+	var tokens []pathToken
+	optional := false
+	for _, segment := range strings.Split(path[1:], "/") {
+		segment = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+
+		if strings.HasSuffix(segment, "?") {
+			segment = strings.TrimSuffix(segment, "?")
+			optional = true
+		}
+
+		token := pathToken{kind: "key", key: segment, optional: optional}
+		if segment == "-" {
+			token.kind = "append"
+		} else if index, err := strconv.Atoi(segment); err == nil {
+			token.kind = "index"
+			token.index = index
+		} else if parts := strings.SplitN(segment, "=", 2); len(parts) == 2 {
+			token.kind = "match"
+			token.key = parts[0]
+			token.value = parts[1]
+		}
+
+		if segment == "" {
+			return nil, fmt.Errorf("path %q has an empty segment", path)
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+func (o Op) apply(node interface{}, tokens []pathToken, seen string) (interface{}, error) {
+	if len(tokens) == 0 {
+		if o.Type == "remove" {
+			return nil, fmt.Errorf("cannot remove the document root")
+		}
+
+		return o.Value, nil
+	}
+
+	token := tokens[0]
+	last := len(tokens) == 1
+
+	switch token.kind {
+	case "key":
+		seen = fmt.Sprintf("%s/%s", seen, token.key)
+		return o.applyKey(node, token, tokens[1:], last, seen)
+	case "index":
+		seen = fmt.Sprintf("%s/%d", seen, token.index)
+		return o.applyIndex(node, token, tokens[1:], last, seen)
+	case "append":
+		seen = seen + "/-"
+		return o.applyAppend(node, token, tokens[1:], last, seen)
+	default:
+		seen = fmt.Sprintf("%s/%s=%s", seen, token.key, token.value)
+		return o.applyMatch(node, token, tokens[1:], last, seen)
+	}
+}
+
+func (o Op) applyKey(node interface{}, token pathToken, rest []pathToken, last bool, seen string) (interface{}, error) {
+	if node == nil && token.optional {
+		node = map[interface{}]interface{}{}
+	}
+
+	m, ok := node.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a map at %q", parentPath(seen))
+	}
+
+	child, found := m[token.key]
+	if !found && !token.optional {
+		return nil, fmt.Errorf("expected to find a map key %q for path %q", token.key, seen)
+	}
+
+	if last && o.Type == "remove" {
+		delete(m, token.key)
+		return m, nil
+	}
+
+	child, err := o.apply(child, rest, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	m[token.key] = child
+	return m, nil
+}
+
+func (o Op) applyIndex(node interface{}, token pathToken, rest []pathToken, last bool, seen string) (interface{}, error) {
+	s, ok := node.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an array at %q", parentPath(seen))
+	}
+
+	index := token.index
+	if index < 0 {
+		index += len(s)
+	}
+
+	if index < 0 || index >= len(s) {
+		return nil, fmt.Errorf("expected to find an array index %d for path %q", token.index, seen)
+	}
+
+	if last && o.Type == "remove" {
+		return append(s[:index:index], s[index+1:]...), nil
+	}
+
+	child, err := o.apply(s[index], rest, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	s[index] = child
+	return s, nil
+}
+
+func (o Op) applyAppend(node interface{}, token pathToken, rest []pathToken, last bool, seen string) (interface{}, error) {
+	if node == nil && token.optional {
+		node = []interface{}{}
+	}
+
+	s, ok := node.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an array at %q", parentPath(seen))
+	}
+
+	if last && o.Type == "remove" {
+		return nil, fmt.Errorf("cannot remove the end of the array at %q", seen)
+	}
+
+	child, err := o.apply(nil, rest, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(s, child), nil
+}
+
+func (o Op) applyMatch(node interface{}, token pathToken, rest []pathToken, last bool, seen string) (interface{}, error) {
+	if node == nil && token.optional {
+		node = []interface{}{}
+	}
+
+	s, ok := node.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an array at %q", parentPath(seen))
+	}
+
+	index := -1
+	for i, element := range s {
+		m, ok := element.(map[interface{}]interface{})
+		if !ok || fmt.Sprint(m[token.key]) != token.value {
+			continue
+		}
+
+		if index != -1 {
+			return nil, fmt.Errorf("expected to find exactly one matching array item for path %q but found multiple", seen)
+		}
+		index = i
+	}
+
+	if index == -1 {
+		if !token.optional {
+			return nil, fmt.Errorf("expected to find exactly one matching array item for path %q but found 0", seen)
+		}
+
+		if last && o.Type == "remove" {
+			return s, nil
+		}
+
+		s = append(s, map[interface{}]interface{}{token.key: token.value})
+		index = len(s) - 1
+	}
+
+	if last && o.Type == "remove" {
+		return append(s[:index:index], s[index+1:]...), nil
+	}
+
+	child, err := o.apply(s[index], rest, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	s[index] = child
+	return s, nil
+}
+
+func parentPath(path string) string {
+	parent := path[:strings.LastIndex(path, "/")]
+	if parent == "" {
+		return "/"
+	}
+
+	return parent
+}
@@ -0,0 +1,183 @@
+package compiler_test
+
+import (
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"gopkg.in/yaml.v2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ops", func() {
+	var document interface{}
+
+	BeforeEach(func() {
+		err := yaml.Unmarshal([]byte(`---
+name: some-deployment
+update:
+  canaries: 1
+instance_groups:
+- name: compiler
+  vm_type: default
+  networks:
+  - name: default
+- name: other
+  vm_type: large
+`), &document)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	apply := func(ops string) (string, error) {
+		parsed, err := compiler.ParseOps([]byte(ops))
+		if err != nil {
+			return "", err
+		}
+
+		result, err := compiler.ApplyOps(document, parsed)
+		if err != nil {
+			return "", err
+		}
+
+		output, err := yaml.Marshal(result)
+		return string(output), err
+	}
+
+	Describe("replace", func() {
+		It("replaces a map key", func() {
+			result, err := apply(`[{type: replace, path: /update/canaries, value: 2}]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(MatchYAML(`---
+name: some-deployment
+update:
+  canaries: 2
+instance_groups:
+- name: compiler
+  vm_type: default
+  networks:
+  - name: default
+- name: other
+  vm_type: large
+`))
+		})
+
+		It("creates optional map keys", func() {
+			result, err := apply(`[{type: replace, path: "/features?/use_dns_addresses", value: true}]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(ContainSubstring("features:\n  use_dns_addresses: true\n"))
+		})
+
+		It("replaces array items by index", func() {
+			result, err := apply(`[{type: replace, path: /instance_groups/-1/vm_type, value: huge}]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(ContainSubstring("vm_type: huge"))
+			Expect(result).NotTo(ContainSubstring("vm_type: large"))
+		})
+
+		It("replaces array items matching a key", func() {
+			result, err := apply(`[{type: replace, path: /instance_groups/name=compiler/networks/name=default/name, value: private}]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(ContainSubstring("- name: private"))
+		})
+
+		It("appends to an array", func() {
+			result, err := apply(`[{type: replace, path: /instance_groups/name=compiler/networks/-, value: {name: extra}}]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(ContainSubstring("  - name: default\n  - name: extra\n"))
+		})
+
+		It("appends optional array items that do not match", func() {
+			result, err := apply(`[{type: replace, path: "/tags?/name=team?/value", value: releng}]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(ContainSubstring("tags:\n- name: team\n  value: releng\n"))
+		})
+
+		It("replaces the document root", func() {
+			result, err := apply(`[{type: replace, path: /, value: {name: other}}]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(MatchYAML("name: other"))
+		})
+
+		It("unescapes slashes and tildes in keys", func() {
+			result, err := apply(`[{type: replace, path: "/a~1b~0c?", value: 1}]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(ContainSubstring("a/b~c: 1"))
+		})
+	})
+
+	Describe("remove", func() {
+		It("removes a map key", func() {
+			result, err := apply(`[{type: remove, path: /update}]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).NotTo(ContainSubstring("update"))
+		})
+
+		It("removes array items matching a key", func() {
+			result, err := apply(`[{type: remove, path: /instance_groups/name=other}]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).NotTo(ContainSubstring("other"))
+			Expect(result).To(ContainSubstring("compiler"))
+		})
+
+		It("removes array items by index", func() {
+			result, err := apply(`[{type: remove, path: /instance_groups/0}]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).NotTo(ContainSubstring("compiler"))
+			Expect(result).To(ContainSubstring("other"))
+		})
+
+		It("ignores optional keys that are missing", func() {
+			_, err := apply(`[{type: remove, path: "/addons?"}]`)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	It("applies ops in order", func() {
+		result, err := apply(`---
+- type: replace
+  path: /update/canaries
+  value: 3
+- type: replace
+  path: /update/canaries
+  value: 4
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(ContainSubstring("canaries: 4"))
+	})
+
+	Context("failure cases", func() {
+		It("returns an error for unknown op types", func() {
+			_, err := apply(`[{type: test, path: /name}]`)
+			Expect(err).To(MatchError(`unknown op type "test" for path "/name", expected replace or remove`))
+		})
+
+		It("returns an error for relative paths", func() {
+			_, err := apply(`[{type: remove, path: name}]`)
+			Expect(err).To(MatchError(`path "name" must start with /`))
+		})
+
+		It("returns an error for missing map keys", func() {
+			_, err := apply(`[{type: replace, path: /features/use_dns_addresses, value: true}]`)
+			Expect(err).To(MatchError(`expected to find a map key "features" for path "/features"`))
+		})
+
+		It("returns an error for out of range indexes", func() {
+			_, err := apply(`[{type: remove, path: /instance_groups/2}]`)
+			Expect(err).To(MatchError(`expected to find an array index 2 for path "/instance_groups/2"`))
+		})
+
+		It("returns an error when nothing matches", func() {
+			_, err := apply(`[{type: remove, path: /instance_groups/name=missing}]`)
+			Expect(err).To(MatchError(`expected to find exactly one matching array item for path "/instance_groups/name=missing" but found 0`))
+		})
+
+		It("returns an error when indexing into a map", func() {
+			_, err := apply(`[{type: replace, path: /update/0, value: 1}]`)
+			Expect(err).To(MatchError(`expected an array at "/update"`))
+		})
+
+		It("returns an error when removing the document root", func() {
+			_, err := apply(`[{type: remove, path: /}]`)
+			Expect(err).To(MatchError("cannot remove the document root"))
+		})
+	})
+})
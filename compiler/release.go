@@ -1,33 +1,22 @@
 package compiler
 
 import (
-	"archive/tar"
-	"compress/gzip"
-	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
 
 	"gopkg.in/yaml.v2"
 )
 
-type Semver struct {
-	Major int
-	Minor int
-	Patch int
-}
-
-func (s Semver) String() string {
-	return fmt.Sprintf("%d.%d.%d", s.Major, s.Minor, s.Patch)
-}
-
 type Release struct {
-	Name    string
-	Version string
-	Semver  Semver
+	Name               string
+	Version            string
+	Semver             Semver
+	CommitHash         string           `yaml:"commit_hash"`
+	UncommittedChanges bool             `yaml:"uncommitted_changes"`
+	Jobs               []ReleaseJob     `yaml:"jobs"`
+	Packages           []ReleasePackage `yaml:"packages"`
+	URL                string           `yaml:"-"`
+	SHA1               string           `yaml:"-"`
 	*os.File
 	size int64
 }
@@ -44,60 +33,65 @@ func NewRelease(path string) (Release, error) {
 		return Release{}, err
 	}
 
-	gr, err := gzip.NewReader(fd)
+	content, err := readManifest(fd, "release.MF", path)
 	if err != nil {
 		return Release{}, err
 	}
-	defer gr.Close()
 
-	tr := tar.NewReader(gr)
-	header, err := tr.Next()
-	for err == nil {
-		if filepath.Base(header.Name) == "release.MF" {
-			break
-		}
-
-		header, err = tr.Next()
-	}
+	release, err := parseRelease(content)
 	if err != nil {
-		if err == io.EOF {
-			return Release{}, fmt.Errorf("could not find release.MF in %q", path)
-		}
-
-		return Release{}, fmt.Errorf("error while reading %q: %s", path, err)
+		return Release{}, err
 	}
 
-	var release Release
-	content, err := ioutil.ReadAll(tr)
+	release.File, err = os.Open(path)
 	if err != nil {
 		return Release{}, err
 	}
 
-	err = yaml.Unmarshal(content, &release)
+	release.size = fileInfo.Size()
+
+	return release, nil
+}
+
+// NewRemoteRelease describes a release tarball that the director will fetch
+// from url itself. The release metadata is read from the release.MF at
+// manifestPath when given, otherwise from the head of the tarball at url.
+func NewRemoteRelease(url, sha1, manifestPath string) (Release, error) {
+	var (
+		content []byte
+		err     error
+	)
+
+	if manifestPath != "" {
+		content, err = ioutil.ReadFile(manifestPath)
+	} else {
+		content, err = readRemoteManifest(url, "release.MF")
+	}
 	if err != nil {
 		return Release{}, err
 	}
 
-	release.File, err = os.Open(path)
+	release, err := parseRelease(content)
 	if err != nil {
 		return Release{}, err
 	}
 
-	release.size = fileInfo.Size()
+	release.URL = url
+	release.SHA1 = sha1
+
+	return release, nil
+}
 
-	parts := strings.Split(release.Version, ".")
-	switch len(parts) {
-	case 1:
-		release.Semver.Major, _ = strconv.Atoi(parts[0])
-	case 2:
-		release.Semver.Major, _ = strconv.Atoi(parts[0])
-		release.Semver.Minor, _ = strconv.Atoi(parts[1])
-	case 3:
-		release.Semver.Major, _ = strconv.Atoi(parts[0])
-		release.Semver.Minor, _ = strconv.Atoi(parts[1])
-		release.Semver.Patch, _ = strconv.Atoi(parts[2])
-	default:
-		return Release{}, fmt.Errorf("could not parse semver version from %s", release.Version)
+func parseRelease(content []byte) (Release, error) {
+	var release Release
+	err := yaml.Unmarshal(content, &release)
+	if err != nil {
+		return Release{}, err
+	}
+
+	release.Semver, err = parseSemver(release.Version)
+	if err != nil {
+		return Release{}, err
 	}
 
 	return release, nil
@@ -0,0 +1,108 @@
+package compiler
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ReleaseManifest is the release.MF of a release tarball, source or compiled,
+// with the size of each job and package archive in the tarball.
+type ReleaseManifest struct {
+	Name               string           `yaml:"name"`
+	Version            string           `yaml:"version"`
+	CommitHash         string           `yaml:"commit_hash"`
+	UncommittedChanges bool             `yaml:"uncommitted_changes"`
+	Jobs               []ReleaseJob     `yaml:"jobs"`
+	Packages           []ReleasePackage `yaml:"packages"`
+	CompiledPackages   []ReleasePackage `yaml:"compiled_packages"`
+}
+
+type ReleaseJob struct {
+	Name        string   `yaml:"name"`
+	Version     string   `yaml:"version"`
+	Fingerprint string   `yaml:"fingerprint"`
+	SHA1        string   `yaml:"sha1"`
+	Packages    []string `yaml:"packages"`
+	Size        int64    `yaml:"-"`
+}
+
+type ReleasePackage struct {
+	Name         string   `yaml:"name"`
+	Version      string   `yaml:"version"`
+	Fingerprint  string   `yaml:"fingerprint"`
+	SHA1         string   `yaml:"sha1"`
+	Stemcell     string   `yaml:"stemcell"`
+	Dependencies []string `yaml:"dependencies"`
+	Size         int64    `yaml:"-"`
+}
+
+func ReadReleaseManifest(path string) (ReleaseManifest, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return ReleaseManifest{}, err
+	}
+	defer fd.Close()
+
+	gr, err := gzip.NewReader(fd)
+	if err != nil {
+		return ReleaseManifest{}, fmt.Errorf("error while reading %q: %s", path, err)
+	}
+	defer gr.Close()
+
+	var content []byte
+	sizes := map[string]int64{}
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ReleaseManifest{}, fmt.Errorf("error while reading %q: %s", path, err)
+		}
+
+		name := filepath.Clean(header.Name)
+		if name == "release.MF" {
+			content, err = ioutil.ReadAll(tr)
+			if err != nil {
+				return ReleaseManifest{}, fmt.Errorf("error while reading %q: %s", path, err)
+			}
+
+			continue
+		}
+
+		sizes[name] = header.Size
+	}
+
+	if content == nil {
+		return ReleaseManifest{}, fmt.Errorf("could not find release.MF in %q", path)
+	}
+
+	var manifest ReleaseManifest
+	err = yaml.Unmarshal(content, &manifest)
+	if err != nil {
+		return ReleaseManifest{}, fmt.Errorf("could not parse release.MF in %q: %s", path, err)
+	}
+
+	for i, job := range manifest.Jobs {
+		manifest.Jobs[i].Size = sizes[filepath.Join("jobs", job.Name+".tgz")]
+	}
+
+	for i, pkg := range manifest.Packages {
+		manifest.Packages[i].Size = sizes[filepath.Join("packages", pkg.Name+".tgz")]
+	}
+
+	for i, pkg := range manifest.CompiledPackages {
+		manifest.CompiledPackages[i].Size = sizes[filepath.Join("compiled_packages", pkg.Name+".tgz")]
+	}
+
+	return manifest, nil
+}
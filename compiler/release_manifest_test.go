@@ -0,0 +1,119 @@
+package compiler_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"github.com/aditya87/precompiled-bosh-release-resource/internal/testhelpers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReadReleaseManifest", func() {
+	var (
+		tempDir     string
+		tarballPath string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		tarballPath = filepath.Join(tempDir, "release.tgz")
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(tempDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reads the release.MF with the size of each job and package", func() {
+		err := ioutil.WriteFile(tarballPath, testhelpers.Tarball(map[string]string{
+			"release.MF": `---
+name: some-release
+version: "42"
+commit_hash: abc1234
+uncommitted_changes: true
+jobs:
+- name: some-job
+  version: some-job-fingerprint
+  fingerprint: some-job-fingerprint
+  sha1: some-job-sha1
+  packages: [some-package]
+compiled_packages:
+- name: some-package
+  version: some-package-fingerprint
+  fingerprint: some-package-fingerprint
+  sha1: some-package-sha1
+  stemcell: some-stemcell/1.2.3
+  dependencies: [other-package]
+`,
+			"jobs/some-job.tgz":                  "some-job",
+			"compiled_packages/some-package.tgz": "some-compiled-package",
+		}), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		manifest, err := compiler.ReadReleaseManifest(tarballPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(manifest).To(Equal(compiler.ReleaseManifest{
+			Name:               "some-release",
+			Version:            "42",
+			CommitHash:         "abc1234",
+			UncommittedChanges: true,
+			Jobs: []compiler.ReleaseJob{
+				{
+					Name:        "some-job",
+					Version:     "some-job-fingerprint",
+					Fingerprint: "some-job-fingerprint",
+					SHA1:        "some-job-sha1",
+					Packages:    []string{"some-package"},
+					Size:        int64(len("some-job")),
+				},
+			},
+			CompiledPackages: []compiler.ReleasePackage{
+				{
+					Name:         "some-package",
+					Version:      "some-package-fingerprint",
+					Fingerprint:  "some-package-fingerprint",
+					SHA1:         "some-package-sha1",
+					Stemcell:     "some-stemcell/1.2.3",
+					Dependencies: []string{"other-package"},
+					Size:         int64(len("some-compiled-package")),
+				},
+			},
+		}))
+	})
+
+	It("returns an error when the tarball has no release.MF", func() {
+		err := ioutil.WriteFile(tarballPath, testhelpers.Tarball(map[string]string{
+			"jobs/some-job.tgz": "some-job",
+		}), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = compiler.ReadReleaseManifest(tarballPath)
+		Expect(err).To(MatchError(ContainSubstring("could not find release.MF")))
+	})
+
+	It("returns an error when the release.MF cannot be parsed", func() {
+		err := ioutil.WriteFile(tarballPath, testhelpers.Tarball(map[string]string{
+			"release.MF": "%%%",
+		}), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = compiler.ReadReleaseManifest(tarballPath)
+		Expect(err).To(MatchError(ContainSubstring("could not parse release.MF")))
+	})
+
+	It("returns an error when the file is not a tarball", func() {
+		err := ioutil.WriteFile(tarballPath, []byte("not-a-tarball"), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = compiler.ReadReleaseManifest(tarballPath)
+		Expect(err).To(MatchError(ContainSubstring("error while reading")))
+	})
+})
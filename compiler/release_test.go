@@ -8,11 +8,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"time"
 
-	"github.com/pivotal-cf/pcf-releng-ci/tasks/future/compile-release/compiler"
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -195,4 +197,77 @@ version: 1.2.3
 			})
 		})
 	})
+
+	Describe("NewRemoteRelease", func() {
+		var (
+			tempDir string
+			server  *httptest.Server
+		)
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			tarballPath := filepath.Join(tempDir, "release.tgz")
+			err = createReleaseTarball(tarballPath, bytes.NewBuffer([]byte(`---
+name: remote-release
+version: 1.2.3
+`)))
+			Expect(err).NotTo(HaveOccurred())
+
+			server = httptest.NewServer(http.FileServer(http.Dir(tempDir)))
+		})
+
+		AfterEach(func() {
+			server.Close()
+
+			err := os.RemoveAll(tempDir)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reads the release details from the tarball at the url", func() {
+			release, err := compiler.NewRemoteRelease(server.URL+"/release.tgz", "some-sha1", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(release.Name).To(Equal("remote-release"))
+			Expect(release.Version).To(Equal("1.2.3"))
+			Expect(release.URL).To(Equal(server.URL + "/release.tgz"))
+			Expect(release.SHA1).To(Equal("some-sha1"))
+			Expect(release.File).To(BeNil())
+		})
+
+		It("reads the release details from a local manifest when one is given", func() {
+			manifestPath := filepath.Join(tempDir, "release.MF")
+			err := ioutil.WriteFile(manifestPath, []byte(`---
+name: local-release
+version: 4.5
+`), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			release, err := compiler.NewRemoteRelease("http://example.com/release.tgz", "some-sha1", manifestPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(release.Name).To(Equal("local-release"))
+			Expect(release.Semver).To(Equal(compiler.Semver{
+				Major: 4,
+				Minor: 5,
+			}))
+			Expect(release.URL).To(Equal("http://example.com/release.tgz"))
+		})
+
+		Context("failure cases", func() {
+			Context("when the url cannot be fetched", func() {
+				It("returns an error", func() {
+					_, err := compiler.NewRemoteRelease(server.URL+"/missing.tgz", "some-sha1", "")
+					Expect(err).To(MatchError(ContainSubstring("404 Not Found")))
+				})
+			})
+
+			Context("when the local manifest does not exist", func() {
+				It("returns an error", func() {
+					_, err := compiler.NewRemoteRelease(server.URL+"/release.tgz", "some-sha1", filepath.Join(tempDir, "missing.MF"))
+					Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+				})
+			})
+		})
+	})
 })
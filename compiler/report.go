@@ -0,0 +1,100 @@
+package compiler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+// Report describes a compiled release for tooling: its compiled packages, the
+// stemcell and director it was compiled on, and the steps that compiled it.
+type Report struct {
+	Release      string          `json:"release"`
+	Version      string          `json:"version"`
+	CommitHash   string          `json:"commit_hash,omitempty"`
+	Stemcell     ReportStemcell  `json:"stemcell"`
+	DirectorUUID string          `json:"director_uuid"`
+	Deployment   string          `json:"deployment"`
+	Packages     []ReportPackage `json:"packages"`
+	Steps        []ReportStep    `json:"steps"`
+	Duration     float64         `json:"duration"`
+}
+
+type ReportStemcell struct {
+	OS      string `json:"os"`
+	Version string `json:"version"`
+}
+
+type ReportPackage struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	Fingerprint  string   `json:"fingerprint"`
+	SHA1         string   `json:"sha1"`
+	Stemcell     string   `json:"stemcell"`
+	Dependencies []string `json:"dependencies"`
+	Size         int64    `json:"size"`
+}
+
+// ReportStep is a step of the compilation with its duration in seconds.
+type ReportStep struct {
+	Step     string  `json:"step"`
+	TaskID   int     `json:"task_id,omitempty"`
+	Duration float64 `json:"duration"`
+	Outcome  string  `json:"outcome"`
+}
+
+// NewReport describes the compiled release from the release.MF of its
+// tarball, and its compilation from the events of the steps that compiled it.
+func NewReport(manifest ReleaseManifest, stemcell Stemcell, directorUUID, deployment string, events []Event, duration time.Duration) Report {
+	report := Report{
+		Release:    manifest.Name,
+		Version:    manifest.Version,
+		CommitHash: manifest.CommitHash,
+		Stemcell: ReportStemcell{
+			OS:      stemcell.Name,
+			Version: stemcell.Version,
+		},
+		DirectorUUID: directorUUID,
+		Deployment:   deployment,
+		Packages:     []ReportPackage{},
+		Steps:        []ReportStep{},
+		Duration:     duration.Seconds(),
+	}
+
+	for _, pkg := range manifest.CompiledPackages {
+		dependencies := pkg.Dependencies
+		if dependencies == nil {
+			dependencies = []string{}
+		}
+
+		report.Packages = append(report.Packages, ReportPackage{
+			Name:         pkg.Name,
+			Version:      pkg.Version,
+			Fingerprint:  pkg.Fingerprint,
+			SHA1:         pkg.SHA1,
+			Stemcell:     pkg.Stemcell,
+			Dependencies: dependencies,
+			Size:         pkg.Size,
+		})
+	}
+
+	for _, event := range events {
+		report.Steps = append(report.Steps, ReportStep{
+			Step:     event.Step,
+			TaskID:   event.TaskID,
+			Duration: event.Duration.Seconds(),
+			Outcome:  event.Outcome,
+		})
+	}
+
+	return report
+}
+
+func (r Report) Write(path string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
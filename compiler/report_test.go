@@ -0,0 +1,74 @@
+package compiler_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Report", func() {
+	var report compiler.Report
+
+	BeforeEach(func() {
+		report = compiler.NewReport(compiler.ReleaseManifest{
+			Name:    "some-release",
+			Version: "42",
+			CompiledPackages: []compiler.ReleasePackage{
+				{
+					Name:        "some-package",
+					Version:     "some-fingerprint",
+					Fingerprint: "some-fingerprint",
+					SHA1:        "some-sha1",
+					Stemcell:    "some-stemcell/1.2.3",
+					Size:        21,
+				},
+			},
+		}, compiler.Stemcell{
+			Name:    "some-stemcell",
+			Version: "1.2.3",
+		}, "some-director-uuid", "compile-release-some-guid", []compiler.Event{
+			{Step: "deploy", TaskID: 7, Duration: 1500 * time.Millisecond, Outcome: "succeeded"},
+		}, 3*time.Second)
+	})
+
+	It("writes the report as json", func() {
+		tempDir, err := ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tempDir)
+
+		path := filepath.Join(tempDir, "report.json")
+		err = report.Write(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		content, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(MatchJSON(`{
+			"release": "some-release",
+			"version": "42",
+			"stemcell": {"os": "some-stemcell", "version": "1.2.3"},
+			"director_uuid": "some-director-uuid",
+			"deployment": "compile-release-some-guid",
+			"packages": [
+				{
+					"name": "some-package",
+					"version": "some-fingerprint",
+					"fingerprint": "some-fingerprint",
+					"sha1": "some-sha1",
+					"stemcell": "some-stemcell/1.2.3",
+					"dependencies": [],
+					"size": 21
+				}
+			],
+			"steps": [
+				{"step": "deploy", "task_id": 7, "duration": 1.5, "outcome": "succeeded"}
+			],
+			"duration": 3
+		}`))
+	})
+})
@@ -0,0 +1,200 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RuntimeConfig holds the parts of a runtime config that decide which
+// deployments its addons are colocated on.
+type RuntimeConfig struct {
+	Addons []Addon `yaml:"addons"`
+}
+
+// RuntimeConfigAddon names an addon of a named runtime config.
+type RuntimeConfigAddon struct {
+	Config string
+	Addon  string
+}
+
+func (a RuntimeConfigAddon) String() string {
+	return fmt.Sprintf("%s/%s", a.Config, a.Addon)
+}
+
+// ApplyingAddons returns the addons of the runtime configs that the director
+// would colocate on the deployment.
+func ApplyingAddons(configs []Config, manifest Manifest) ([]RuntimeConfigAddon, error) {
+	var applying []RuntimeConfigAddon
+	for _, config := range configs {
+		var runtimeConfig RuntimeConfig
+		err := yaml.Unmarshal([]byte(config.Content), &runtimeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse runtime config %s: %s", config.Name, err)
+		}
+
+		for _, addon := range runtimeConfig.Addons {
+			if addon.Applies(manifest) {
+				applying = append(applying, RuntimeConfigAddon{Config: config.Name, Addon: addon.Name})
+			}
+		}
+	}
+
+	return applying, nil
+}
+
+// Applies reports whether the addon is colocated on the deployment: the
+// deployment must match its include rule, if any, and not its exclude rule.
+func (a Addon) Applies(manifest Manifest) bool {
+	if a.Include != nil && !a.Include.Matches(manifest) {
+		return false
+	}
+
+	return a.Exclude == nil || !a.Exclude.Matches(manifest)
+}
+
+// Matches reports whether the deployment meets every criterion given in the
+// placement rule. The compile deployment belongs to no teams, so a rule
+// naming teams never matches it.
+func (p Placement) Matches(manifest Manifest) bool {
+	if len(p.Deployments) > 0 && !existsInSlice(p.Deployments, manifest.Name) {
+		return false
+	}
+
+	if len(p.Teams) > 0 {
+		return false
+	}
+
+	if len(p.Stemcells) > 0 && !p.matchesStemcell(manifest) {
+		return false
+	}
+
+	var instanceGroups, networks, lifecycles []string
+	var jobs []PlacementJob
+	for _, instanceGroup := range manifest.InstanceGroups {
+		instanceGroups = append(instanceGroups, instanceGroup.Name)
+
+		for _, network := range instanceGroup.Networks {
+			networks = append(networks, network.Name)
+		}
+
+		lifecycle := instanceGroup.Lifecycle
+		if lifecycle == "" {
+			lifecycle = "service"
+		}
+		lifecycles = append(lifecycles, lifecycle)
+
+		for _, job := range instanceGroup.Jobs {
+			jobs = append(jobs, PlacementJob{Name: job.Name, Release: job.Release})
+		}
+	}
+
+	if len(p.InstanceGroups) > 0 && !containsAny(p.InstanceGroups, instanceGroups) {
+		return false
+	}
+
+	if len(p.Networks) > 0 && !containsAny(p.Networks, networks) {
+		return false
+	}
+
+	if p.Lifecycle != "" && !existsInSlice(lifecycles, p.Lifecycle) {
+		return false
+	}
+
+	if len(p.Jobs) > 0 && !p.matchesJob(jobs) {
+		return false
+	}
+
+	return true
+}
+
+func (p Placement) matchesStemcell(manifest Manifest) bool {
+	for _, stemcell := range p.Stemcells {
+		for _, manifestStemcell := range manifest.Stemcells {
+			if stemcell.OS == manifestStemcell.OS {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (p Placement) matchesJob(jobs []PlacementJob) bool {
+	for _, job := range p.Jobs {
+		for _, deploymentJob := range jobs {
+			if job == deploymentJob {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ExcludeDeployment adds the deployment to the exclude rule of each of the
+// named addons in the runtime config content. An exclude rule with criteria
+// other than deployments cannot be extended this way, as the director
+// requires every criterion of a rule to match.
+func ExcludeDeployment(content string, addons []string, deploymentName string) (string, error) {
+	var runtimeConfig RuntimeConfig
+	err := yaml.Unmarshal([]byte(content), &runtimeConfig)
+	if err != nil {
+		return "", err
+	}
+
+	var ops []Op
+	for _, addon := range runtimeConfig.Addons {
+		if !existsInSlice(addons, addon.Name) {
+			continue
+		}
+
+		if addon.Exclude != nil && !addon.Exclude.onlyDeployments() {
+			return "", fmt.Errorf("addon %s already has an exclude rule that is not limited to deployments", addon.Name)
+		}
+
+		ops = append(ops, Op{
+			Type:  "replace",
+			Path:  fmt.Sprintf("/addons/name=%s/exclude?/deployments?/-", escapePathSegment(addon.Name)),
+			Value: deploymentName,
+		})
+	}
+
+	var document interface{}
+	err = yaml.Unmarshal([]byte(content), &document)
+	if err != nil {
+		return "", err
+	}
+
+	document, err = ApplyOps(document, ops)
+	if err != nil {
+		return "", err
+	}
+
+	excluded, err := yaml.Marshal(document)
+	if err != nil {
+		return "", err
+	}
+
+	return string(excluded), nil
+}
+
+func (p Placement) onlyDeployments() bool {
+	return len(p.Jobs) == 0 && len(p.InstanceGroups) == 0 && len(p.Stemcells) == 0 &&
+		len(p.Networks) == 0 && len(p.Teams) == 0 && p.Lifecycle == ""
+}
+
+func escapePathSegment(segment string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(segment)
+}
+
+func containsAny(values, candidates []string) bool {
+	for _, candidate := range candidates {
+		if existsInSlice(values, candidate) {
+			return true
+		}
+	}
+
+	return false
+}
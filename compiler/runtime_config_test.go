@@ -0,0 +1,147 @@
+package compiler_test
+
+import (
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RuntimeConfig", func() {
+	var manifest compiler.Manifest
+
+	BeforeEach(func() {
+		manifest = compiler.Manifest{
+			Name:      "compile-release-some-guid",
+			Stemcells: []compiler.ManifestStemcell{{Alias: "default", OS: "ubuntu-xenial", Version: "1.2.3"}},
+			InstanceGroups: []compiler.InstanceGroup{
+				{
+					Name:     "compiler",
+					VMType:   "default",
+					Stemcell: "default",
+					Networks: []compiler.InstanceGroupNetwork{{Name: "default"}},
+					Jobs:     []compiler.Job{},
+				},
+			},
+		}
+	})
+
+	Describe("ApplyingAddons", func() {
+		It("returns the addons that apply to the deployment", func() {
+			addons, err := compiler.ApplyingAddons([]compiler.Config{
+				{Name: "default", Content: `---
+addons:
+- name: everywhere
+  jobs: []
+- name: xenial-only
+  jobs: []
+  include:
+    stemcell: [{os: ubuntu-xenial}]
+- name: windows-only
+  jobs: []
+  include:
+    stemcell: [{os: windows2019}]
+`},
+				{Name: "security", Content: `---
+addons:
+- name: not-compiles
+  jobs: []
+  exclude:
+    instance_groups: [compiler]
+- name: antivirus
+  jobs: []
+  exclude:
+    deployments: [other-deployment]
+`},
+			}, manifest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(addons).To(Equal([]compiler.RuntimeConfigAddon{
+				{Config: "default", Addon: "everywhere"},
+				{Config: "default", Addon: "xenial-only"},
+				{Config: "security", Addon: "antivirus"},
+			}))
+			Expect(addons[2].String()).To(Equal("security/antivirus"))
+		})
+
+		It("returns an error when a runtime config is not YAML", func() {
+			_, err := compiler.ApplyingAddons([]compiler.Config{{Name: "broken", Content: "%%%"}}, manifest)
+			Expect(err).To(MatchError(ContainSubstring("could not parse runtime config broken")))
+		})
+	})
+
+	Describe("Placement", func() {
+		It("matches the deployment with an empty rule", func() {
+			Expect(compiler.Placement{}.Matches(manifest)).To(BeTrue())
+		})
+
+		It("matches the deployment's name, stemcell, instance groups, networks and lifecycle", func() {
+			Expect(compiler.Placement{Deployments: []string{"compile-release-some-guid"}}.Matches(manifest)).To(BeTrue())
+			Expect(compiler.Placement{Stemcells: []compiler.PlacementStemcell{{OS: "ubuntu-xenial"}}}.Matches(manifest)).To(BeTrue())
+			Expect(compiler.Placement{InstanceGroups: []string{"compiler"}}.Matches(manifest)).To(BeTrue())
+			Expect(compiler.Placement{Networks: []string{"default"}}.Matches(manifest)).To(BeTrue())
+			Expect(compiler.Placement{Lifecycle: "service"}.Matches(manifest)).To(BeTrue())
+		})
+
+		It("does not match other deployments, networks, lifecycles, jobs or teams", func() {
+			Expect(compiler.Placement{Deployments: []string{"other"}}.Matches(manifest)).To(BeFalse())
+			Expect(compiler.Placement{Networks: []string{"private"}}.Matches(manifest)).To(BeFalse())
+			Expect(compiler.Placement{Lifecycle: "errand"}.Matches(manifest)).To(BeFalse())
+			Expect(compiler.Placement{Jobs: []compiler.PlacementJob{{Name: "some-job", Release: "some-release"}}}.Matches(manifest)).To(BeFalse())
+			Expect(compiler.Placement{Teams: []string{"some-team"}}.Matches(manifest)).To(BeFalse())
+		})
+
+		It("requires every criterion of the rule to match", func() {
+			Expect(compiler.Placement{
+				Deployments: []string{"compile-release-some-guid"},
+				Networks:    []string{"private"},
+			}.Matches(manifest)).To(BeFalse())
+		})
+	})
+
+	Describe("ExcludeDeployment", func() {
+		It("adds the deployment to the exclude rule of the named addons", func() {
+			content, err := compiler.ExcludeDeployment(`---
+releases:
+- name: some-agent
+  version: "1"
+addons:
+- name: some-agent
+  jobs: [{name: agent, release: some-agent}]
+- name: antivirus
+  jobs: [{name: antivirus, release: some-antivirus}]
+  exclude:
+    deployments: [other-deployment]
+- name: untouched
+  jobs: []
+`, []string{"some-agent", "antivirus"}, "compile-release-some-guid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(content).To(MatchYAML(`---
+releases:
+- name: some-agent
+  version: "1"
+addons:
+- name: some-agent
+  jobs: [{name: agent, release: some-agent}]
+  exclude:
+    deployments: [compile-release-some-guid]
+- name: antivirus
+  jobs: [{name: antivirus, release: some-antivirus}]
+  exclude:
+    deployments: [other-deployment, compile-release-some-guid]
+- name: untouched
+  jobs: []
+`))
+		})
+
+		It("returns an error when an exclude rule has other criteria", func() {
+			_, err := compiler.ExcludeDeployment(`---
+addons:
+- name: some-agent
+  jobs: []
+  exclude:
+    jobs: [{name: some-job, release: some-release}]
+`, []string{"some-agent"}, "compile-release-some-guid")
+			Expect(err).To(MatchError("addon some-agent already has an exclude rule that is not limited to deployments"))
+		})
+	})
+})
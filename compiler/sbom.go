@@ -0,0 +1,148 @@
+package compiler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+)
+
+// SBOM is a CycloneDX software bill of materials for a compiled release: its
+// jobs and compiled packages, the stemcell they were compiled against and the
+// dependencies between them.
+type SBOM struct {
+	BOMFormat    string           `json:"bomFormat"`
+	SpecVersion  string           `json:"specVersion"`
+	Version      int              `json:"version"`
+	Metadata     SBOMMetadata     `json:"metadata"`
+	Components   []SBOMComponent  `json:"components"`
+	Dependencies []SBOMDependency `json:"dependencies"`
+}
+
+type SBOMMetadata struct {
+	Component SBOMComponent `json:"component"`
+}
+
+type SBOMComponent struct {
+	Type       string         `json:"type"`
+	BOMRef     string         `json:"bom-ref"`
+	Name       string         `json:"name"`
+	Version    string         `json:"version"`
+	Hashes     []SBOMHash     `json:"hashes,omitempty"`
+	Properties []SBOMProperty `json:"properties,omitempty"`
+}
+
+type SBOMHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+type SBOMProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type SBOMDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// NewSBOM describes the compiled release from the release.MF of its tarball.
+func NewSBOM(manifest ReleaseManifest, stemcell Stemcell) SBOM {
+	release := SBOMComponent{
+		Type:    "application",
+		BOMRef:  "release:" + manifest.Name,
+		Name:    manifest.Name,
+		Version: manifest.Version,
+	}
+	if manifest.CommitHash != "" {
+		release.Properties = append(release.Properties, SBOMProperty{Name: "bosh:commit_hash", Value: manifest.CommitHash})
+	}
+
+	stemcellRef := "stemcell:" + stemcell.Name
+	sbom := SBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata:    SBOMMetadata{Component: release},
+		Components: []SBOMComponent{
+			{
+				Type:    "operating-system",
+				BOMRef:  stemcellRef,
+				Name:    stemcell.Name,
+				Version: stemcell.Version,
+			},
+		},
+		Dependencies: []SBOMDependency{},
+	}
+
+	releaseDependency := SBOMDependency{Ref: release.BOMRef, DependsOn: []string{}}
+
+	for _, job := range manifest.Jobs {
+		ref := "job:" + job.Name
+		sbom.Components = append(sbom.Components, SBOMComponent{
+			Type:       "application",
+			BOMRef:     ref,
+			Name:       job.Name,
+			Version:    job.Version,
+			Hashes:     sbomHashes(job.SHA1),
+			Properties: []SBOMProperty{{Name: "bosh:fingerprint", Value: job.Fingerprint}},
+		})
+
+		releaseDependency.DependsOn = append(releaseDependency.DependsOn, ref)
+		sbom.Dependencies = append(sbom.Dependencies, SBOMDependency{Ref: ref, DependsOn: packageRefs(job.Packages)})
+	}
+
+	for _, pkg := range manifest.CompiledPackages {
+		ref := "package:" + pkg.Name
+		sbom.Components = append(sbom.Components, SBOMComponent{
+			Type:    "library",
+			BOMRef:  ref,
+			Name:    pkg.Name,
+			Version: pkg.Version,
+			Hashes:  sbomHashes(pkg.SHA1),
+			Properties: []SBOMProperty{
+				{Name: "bosh:fingerprint", Value: pkg.Fingerprint},
+				{Name: "bosh:stemcell", Value: pkg.Stemcell},
+			},
+		})
+
+		releaseDependency.DependsOn = append(releaseDependency.DependsOn, ref)
+		sbom.Dependencies = append(sbom.Dependencies, SBOMDependency{Ref: ref, DependsOn: append(packageRefs(pkg.Dependencies), stemcellRef)})
+	}
+
+	sbom.Dependencies = append([]SBOMDependency{releaseDependency}, sbom.Dependencies...)
+
+	return sbom
+}
+
+func (s SBOM) Write(path string) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// sbomHashes describes the digest of a job or package, which release.MF gives
+// as a bare sha1 or prefixed with its algorithm, as in "sha256:...".
+func sbomHashes(digest string) []SBOMHash {
+	if digest == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(digest, "sha256:") {
+		return []SBOMHash{{Algorithm: "SHA-256", Content: strings.TrimPrefix(digest, "sha256:")}}
+	}
+
+	return []SBOMHash{{Algorithm: "SHA-1", Content: digest}}
+}
+
+func packageRefs(names []string) []string {
+	refs := []string{}
+	for _, name := range names {
+		refs = append(refs, "package:"+name)
+	}
+
+	return refs
+}
@@ -0,0 +1,101 @@
+package compiler_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SBOM", func() {
+	It("writes a CycloneDX document of the jobs, packages and stemcell of the compiled release", func() {
+		sbom := compiler.NewSBOM(compiler.ReleaseManifest{
+			Name:       "some-release",
+			Version:    "42",
+			CommitHash: "abc1234",
+			Jobs: []compiler.ReleaseJob{
+				{
+					Name:        "some-job",
+					Version:     "some-job-version",
+					Fingerprint: "some-job-fingerprint",
+					SHA1:        "some-job-sha1",
+					Packages:    []string{"some-package"},
+				},
+			},
+			CompiledPackages: []compiler.ReleasePackage{
+				{
+					Name:         "some-package",
+					Version:      "some-package-version",
+					Fingerprint:  "some-package-fingerprint",
+					SHA1:         "sha256:some-package-sha256",
+					Stemcell:     "some-stemcell/1.2.3",
+					Dependencies: []string{"other-package"},
+				},
+			},
+		}, compiler.Stemcell{
+			Name:    "some-stemcell",
+			Version: "1.2.3",
+		})
+
+		tempDir, err := ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tempDir)
+
+		path := filepath.Join(tempDir, "some-release.cdx.json")
+		err = sbom.Write(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		content, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(MatchJSON(`{
+			"bomFormat": "CycloneDX",
+			"specVersion": "1.4",
+			"version": 1,
+			"metadata": {
+				"component": {
+					"type": "application",
+					"bom-ref": "release:some-release",
+					"name": "some-release",
+					"version": "42",
+					"properties": [{"name": "bosh:commit_hash", "value": "abc1234"}]
+				}
+			},
+			"components": [
+				{
+					"type": "operating-system",
+					"bom-ref": "stemcell:some-stemcell",
+					"name": "some-stemcell",
+					"version": "1.2.3"
+				},
+				{
+					"type": "application",
+					"bom-ref": "job:some-job",
+					"name": "some-job",
+					"version": "some-job-version",
+					"hashes": [{"alg": "SHA-1", "content": "some-job-sha1"}],
+					"properties": [{"name": "bosh:fingerprint", "value": "some-job-fingerprint"}]
+				},
+				{
+					"type": "library",
+					"bom-ref": "package:some-package",
+					"name": "some-package",
+					"version": "some-package-version",
+					"hashes": [{"alg": "SHA-256", "content": "some-package-sha256"}],
+					"properties": [
+						{"name": "bosh:fingerprint", "value": "some-package-fingerprint"},
+						{"name": "bosh:stemcell", "value": "some-stemcell/1.2.3"}
+					]
+				}
+			],
+			"dependencies": [
+				{"ref": "release:some-release", "dependsOn": ["job:some-job", "package:some-package"]},
+				{"ref": "job:some-job", "dependsOn": ["package:some-package"]},
+				{"ref": "package:some-package", "dependsOn": ["package:other-package", "stemcell:some-stemcell"]}
+			]
+		}`))
+	})
+})
@@ -0,0 +1,78 @@
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Semver struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseSemver strictly parses a one, two or three part numeric version, such
+// as one supplied by a user, into a Semver.
+func ParseSemver(version string) (Semver, error) {
+	var numbers [3]int
+
+	parts := strings.Split(version, ".")
+	if len(parts) > 3 {
+		return Semver{}, fmt.Errorf("could not parse semver version from %s", version)
+	}
+
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return Semver{}, fmt.Errorf("could not parse semver version from %s", version)
+		}
+
+		numbers[i] = number
+	}
+
+	return Semver{
+		Major: numbers[0],
+		Minor: numbers[1],
+		Patch: numbers[2],
+	}, nil
+}
+
+func (s Semver) String() string {
+	return fmt.Sprintf("%d.%d.%d", s.Major, s.Minor, s.Patch)
+}
+
+func (s Semver) LessThan(other Semver) bool {
+	if s.Major != other.Major {
+		return s.Major < other.Major
+	}
+
+	if s.Minor != other.Minor {
+		return s.Minor < other.Minor
+	}
+
+	return s.Patch < other.Patch
+}
+
+// parseSemver leniently parses the version of an existing release or stemcell,
+// treating parts that are not numeric, such as "0+dev", as zero.
+func parseSemver(version string) (Semver, error) {
+	var semver Semver
+
+	parts := strings.Split(version, ".")
+	switch len(parts) {
+	case 1:
+		semver.Major, _ = strconv.Atoi(parts[0])
+	case 2:
+		semver.Major, _ = strconv.Atoi(parts[0])
+		semver.Minor, _ = strconv.Atoi(parts[1])
+	case 3:
+		semver.Major, _ = strconv.Atoi(parts[0])
+		semver.Minor, _ = strconv.Atoi(parts[1])
+		semver.Patch, _ = strconv.Atoi(parts[2])
+	default:
+		return Semver{}, fmt.Errorf("could not parse semver version from %s", version)
+	}
+
+	return semver, nil
+}
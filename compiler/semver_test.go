@@ -0,0 +1,53 @@
+package compiler_test
+
+import (
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Semver", func() {
+	Describe("ParseSemver", func() {
+		It("parses one, two and three part versions", func() {
+			semver, err := compiler.ParseSemver("42")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(semver).To(Equal(compiler.Semver{Major: 42}))
+
+			semver, err = compiler.ParseSemver("3445.11")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(semver).To(Equal(compiler.Semver{Major: 3445, Minor: 11}))
+
+			semver, err = compiler.ParseSemver("1.2.3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(semver).To(Equal(compiler.Semver{Major: 1, Minor: 2, Patch: 3}))
+		})
+
+		Context("failure cases", func() {
+			It("rejects versions with too many parts", func() {
+				_, err := compiler.ParseSemver("1.2.3.4")
+				Expect(err).To(MatchError("could not parse semver version from 1.2.3.4"))
+			})
+
+			It("rejects versions that are not numeric", func() {
+				_, err := compiler.ParseSemver("0+dev.1")
+				Expect(err).To(MatchError("could not parse semver version from 0+dev.1"))
+			})
+
+			It("rejects empty versions", func() {
+				_, err := compiler.ParseSemver("")
+				Expect(err).To(MatchError("could not parse semver version from "))
+			})
+		})
+	})
+
+	Describe("LessThan", func() {
+		It("compares major, then minor, then patch", func() {
+			Expect(compiler.Semver{Major: 1, Minor: 9, Patch: 9}.LessThan(compiler.Semver{Major: 2})).To(BeTrue())
+			Expect(compiler.Semver{Major: 2, Minor: 1}.LessThan(compiler.Semver{Major: 2, Minor: 2})).To(BeTrue())
+			Expect(compiler.Semver{Major: 2, Minor: 2, Patch: 1}.LessThan(compiler.Semver{Major: 2, Minor: 2, Patch: 2})).To(BeTrue())
+			Expect(compiler.Semver{Major: 2, Minor: 2}.LessThan(compiler.Semver{Major: 2, Minor: 2})).To(BeFalse())
+			Expect(compiler.Semver{Major: 3}.LessThan(compiler.Semver{Major: 2, Minor: 9})).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,141 @@
+package compiler
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// SignTarball writes a detached signature of the tarball to <tarball>.sig.
+// The private key is PEM encoded PKCS #8, as written by `openssl genpkey
+// -algorithm ed25519`.
+//
+// The .sig holds one line: the base64 encoded ed25519 signature of the raw
+// 32 byte sha256 digest of the tarball, not of the tarball itself. Signing the
+// digest streams the tarball rather than holding it in memory. Standard
+// ed25519 tooling verifies it once given the digest, for example:
+//
+//	openssl dgst -sha256 -binary release.tgz > release.tgz.sha256
+//	base64 -d release.tgz.sig > release.tgz.sig.raw
+//	openssl pkeyutl -verify -pubin -inkey public.pem -rawin \
+//	  -in release.tgz.sha256 -sigfile release.tgz.sig.raw
+func SignTarball(tarballPath, privateKey string) (string, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := tarballDigest(tarballPath)
+	if err != nil {
+		return "", err
+	}
+
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, digest))
+
+	signaturePath := tarballPath + ".sig"
+	err = ioutil.WriteFile(signaturePath, []byte(signature+"\n"), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return signaturePath, nil
+}
+
+// VerifyTarballSignature checks the detached signature of the sha256 digest of
+// the tarball against the PEM encoded ed25519 public keys and succeeds when
+// any of them verifies it.
+func VerifyTarballSignature(tarballPath, signaturePath string, publicKeys []string) error {
+	if len(publicKeys) == 0 {
+		return errors.New("no public keys to verify the signature with")
+	}
+
+	digest, err := tarballDigest(tarballPath)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := ioutil.ReadFile(signaturePath)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("could not decode signature %s: %s", signaturePath, err)
+	}
+
+	for _, publicKey := range publicKeys {
+		key, err := parsePublicKey(publicKey)
+		if err != nil {
+			return err
+		}
+
+		if ed25519.Verify(key, digest, signature) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("signature %s does not match %s for any of the public keys", signaturePath, tarballPath)
+}
+
+func tarballDigest(tarballPath string) ([]byte, error) {
+	fd, err := os.Open(tarballPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, fd)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+func parsePrivateKey(privateKey string) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return nil, errors.New("could not decode the signing key, expected a PEM encoded ed25519 private key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the signing key: %s", err)
+	}
+
+	ed25519Key, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("the signing key is not an ed25519 private key")
+	}
+
+	return ed25519Key, nil
+}
+
+func parsePublicKey(publicKey string) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return nil, errors.New("could not decode a public key, expected a PEM encoded ed25519 public key")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse a public key: %s", err)
+	}
+
+	ed25519Key, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("a public key is not an ed25519 public key")
+	}
+
+	return ed25519Key, nil
+}
@@ -0,0 +1,110 @@
+package compiler_test
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"github.com/aditya87/precompiled-bosh-release-resource/internal/testhelpers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signature", func() {
+	var (
+		tempDir     string
+		tarballPath string
+		privateKey  string
+		publicKey   string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		tarballPath = filepath.Join(tempDir, "some-release-42.0.0-1.2.3.tgz")
+		err = ioutil.WriteFile(tarballPath, []byte("compiled-release-contents"), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		privateKey, publicKey = testhelpers.GenerateKeys()
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(tempDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("signs the tarball with a detached signature that the public key verifies", func() {
+		signaturePath, err := compiler.SignTarball(tarballPath, privateKey)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signaturePath).To(Equal(tarballPath + ".sig"))
+
+		_, otherPublicKey := testhelpers.GenerateKeys()
+		err = compiler.VerifyTarballSignature(tarballPath, signaturePath, []string{otherPublicKey, publicKey})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("signs the sha256 digest of the tarball", func() {
+		signaturePath, err := compiler.SignTarball(tarballPath, privateKey)
+		Expect(err).NotTo(HaveOccurred())
+
+		encoded, err := ioutil.ReadFile(signaturePath)
+		Expect(err).NotTo(HaveOccurred())
+		signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+		Expect(err).NotTo(HaveOccurred())
+
+		block, _ := pem.Decode([]byte(publicKey))
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		Expect(err).NotTo(HaveOccurred())
+
+		digest := sha256.Sum256([]byte("compiled-release-contents"))
+		Expect(ed25519.Verify(key.(ed25519.PublicKey), digest[:], signature)).To(BeTrue())
+	})
+
+	It("fails verification when the tarball has changed", func() {
+		signaturePath, err := compiler.SignTarball(tarballPath, privateKey)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(tarballPath, []byte("other-contents"), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = compiler.VerifyTarballSignature(tarballPath, signaturePath, []string{publicKey})
+		Expect(err).To(MatchError(ContainSubstring("does not match")))
+	})
+
+	It("fails verification against other public keys", func() {
+		signaturePath, err := compiler.SignTarball(tarballPath, privateKey)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, otherPublicKey := testhelpers.GenerateKeys()
+		err = compiler.VerifyTarballSignature(tarballPath, signaturePath, []string{otherPublicKey})
+		Expect(err).To(MatchError(ContainSubstring("does not match")))
+	})
+
+	It("fails verification without public keys", func() {
+		err := compiler.VerifyTarballSignature(tarballPath, tarballPath+".sig", nil)
+		Expect(err).To(MatchError("no public keys to verify the signature with"))
+	})
+
+	It("returns an error when the signing key cannot be decoded", func() {
+		_, err := compiler.SignTarball(tarballPath, "some-signing-key")
+		Expect(err).To(MatchError(ContainSubstring("could not decode the signing key")))
+	})
+
+	It("returns an error when a public key cannot be decoded", func() {
+		signaturePath, err := compiler.SignTarball(tarballPath, privateKey)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = compiler.VerifyTarballSignature(tarballPath, signaturePath, []string{"some-public-key"})
+		Expect(err).To(MatchError(ContainSubstring("could not decode a public key")))
+	})
+})
@@ -1,16 +1,9 @@
 package compiler
 
 import (
-	"archive/tar"
-	"compress/gzip"
-	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
-	"path/filepath"
 	"regexp"
-	"strconv"
-	"strings"
 
 	"gopkg.in/yaml.v2"
 )
@@ -18,13 +11,21 @@ import (
 var tarballRegex = regexp.MustCompile(`(.*)-([\d\.]+)\.tgz`)
 
 type Stemcell struct {
-	Name    string `yaml:"operating_system"`
-	Version string
-	Semver  Semver
+	Name            string `yaml:"operating_system"`
+	Version         string
+	Semver          Semver
+	APIVersion      int                     `yaml:"api_version"`
+	CloudProperties StemcellCloudProperties `yaml:"cloud_properties"`
+	URL             string                  `yaml:"-"`
+	SHA1            string                  `yaml:"-"`
 	*os.File
 	size int64
 }
 
+type StemcellCloudProperties struct {
+	Infrastructure string `yaml:"infrastructure"`
+}
+
 func NewStemcell(path string) (Stemcell, error) {
 	fd, err := os.Open(path)
 	if err != nil {
@@ -37,60 +38,65 @@ func NewStemcell(path string) (Stemcell, error) {
 		return Stemcell{}, err
 	}
 
-	gr, err := gzip.NewReader(fd)
+	content, err := readManifest(fd, "stemcell.MF", path)
 	if err != nil {
 		return Stemcell{}, err
 	}
-	defer gr.Close()
 
-	tr := tar.NewReader(gr)
-	header, err := tr.Next()
-	for err == nil {
-		if filepath.Base(header.Name) == "stemcell.MF" {
-			break
-		}
-
-		header, err = tr.Next()
-	}
+	stemcell, err := parseStemcell(content)
 	if err != nil {
-		if err == io.EOF {
-			return Stemcell{}, fmt.Errorf("could not find stemcell.MF in %q", path)
-		}
-
-		return Stemcell{}, fmt.Errorf("error while reading %q: %s", path, err)
+		return Stemcell{}, err
 	}
 
-	var stemcell Stemcell
-	content, err := ioutil.ReadAll(tr)
+	stemcell.File, err = os.Open(path)
 	if err != nil {
 		return Stemcell{}, err
 	}
 
-	err = yaml.Unmarshal(content, &stemcell)
+	stemcell.size = fileInfo.Size()
+
+	return stemcell, nil
+}
+
+// NewRemoteStemcell describes a stemcell tarball that the director will fetch
+// from url itself. The stemcell metadata is read from the stemcell.MF at
+// manifestPath when given, otherwise from the head of the tarball at url.
+func NewRemoteStemcell(url, sha1, manifestPath string) (Stemcell, error) {
+	var (
+		content []byte
+		err     error
+	)
+
+	if manifestPath != "" {
+		content, err = ioutil.ReadFile(manifestPath)
+	} else {
+		content, err = readRemoteManifest(url, "stemcell.MF")
+	}
 	if err != nil {
 		return Stemcell{}, err
 	}
 
-	stemcell.File, err = os.Open(path)
+	stemcell, err := parseStemcell(content)
 	if err != nil {
 		return Stemcell{}, err
 	}
 
-	stemcell.size = fileInfo.Size()
+	stemcell.URL = url
+	stemcell.SHA1 = sha1
 
-	parts := strings.Split(stemcell.Version, ".")
-	switch len(parts) {
-	case 1:
-		stemcell.Semver.Major, _ = strconv.Atoi(parts[0])
-	case 2:
-		stemcell.Semver.Major, _ = strconv.Atoi(parts[0])
-		stemcell.Semver.Minor, _ = strconv.Atoi(parts[1])
-	case 3:
-		stemcell.Semver.Major, _ = strconv.Atoi(parts[0])
-		stemcell.Semver.Minor, _ = strconv.Atoi(parts[1])
-		stemcell.Semver.Patch, _ = strconv.Atoi(parts[2])
-	default:
-		panic(err)
+	return stemcell, nil
+}
+
+func parseStemcell(content []byte) (Stemcell, error) {
+	var stemcell Stemcell
+	err := yaml.Unmarshal(content, &stemcell)
+	if err != nil {
+		return Stemcell{}, err
+	}
+
+	stemcell.Semver, err = parseSemver(stemcell.Version)
+	if err != nil {
+		return Stemcell{}, err
 	}
 
 	return stemcell, nil
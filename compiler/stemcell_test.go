@@ -8,11 +8,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"time"
 
-	"github.com/pivotal-cf/pcf-releng-ci/tasks/future/compile-release/compiler"
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -83,6 +85,23 @@ version: 1.2.3
 			})
 		})
 
+		It("parses the api_version and infrastructure", func() {
+			path := filepath.Join(tempDir, "stemcell.tgz")
+			err := createStemcellTarball(path, bytes.NewBuffer([]byte(`---
+operating_system: ubuntu-xenial
+version: 621.23
+api_version: 3
+cloud_properties:
+  infrastructure: aws
+`)))
+			Expect(err).NotTo(HaveOccurred())
+
+			stemcell, err := compiler.NewStemcell(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stemcell.APIVersion).To(Equal(3))
+			Expect(stemcell.CloudProperties.Infrastructure).To(Equal("aws"))
+		})
+
 		Context("failure cases", func() {
 			Context("when the stemcell tarball does not exist", func() {
 				It("returns an error", func() {
@@ -195,4 +214,77 @@ version: 1.2.3
 			})
 		})
 	})
+
+	Describe("NewRemoteStemcell", func() {
+		var (
+			tempDir string
+			server  *httptest.Server
+		)
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			tarballPath := filepath.Join(tempDir, "stemcell.tgz")
+			err = createStemcellTarball(tarballPath, bytes.NewBuffer([]byte(`---
+operating_system: remote-stemcell
+version: 1.2.3
+`)))
+			Expect(err).NotTo(HaveOccurred())
+
+			server = httptest.NewServer(http.FileServer(http.Dir(tempDir)))
+		})
+
+		AfterEach(func() {
+			server.Close()
+
+			err := os.RemoveAll(tempDir)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reads the stemcell details from the tarball at the url", func() {
+			stemcell, err := compiler.NewRemoteStemcell(server.URL+"/stemcell.tgz", "some-sha1", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stemcell.Name).To(Equal("remote-stemcell"))
+			Expect(stemcell.Version).To(Equal("1.2.3"))
+			Expect(stemcell.URL).To(Equal(server.URL + "/stemcell.tgz"))
+			Expect(stemcell.SHA1).To(Equal("some-sha1"))
+			Expect(stemcell.File).To(BeNil())
+		})
+
+		It("reads the stemcell details from a local manifest when one is given", func() {
+			manifestPath := filepath.Join(tempDir, "stemcell.MF")
+			err := ioutil.WriteFile(manifestPath, []byte(`---
+operating_system: local-stemcell
+version: 4.5
+`), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			stemcell, err := compiler.NewRemoteStemcell("http://example.com/stemcell.tgz", "some-sha1", manifestPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stemcell.Name).To(Equal("local-stemcell"))
+			Expect(stemcell.Semver).To(Equal(compiler.Semver{
+				Major: 4,
+				Minor: 5,
+			}))
+			Expect(stemcell.URL).To(Equal("http://example.com/stemcell.tgz"))
+		})
+
+		Context("failure cases", func() {
+			Context("when the url cannot be fetched", func() {
+				It("returns an error", func() {
+					_, err := compiler.NewRemoteStemcell(server.URL+"/missing.tgz", "some-sha1", "")
+					Expect(err).To(MatchError(ContainSubstring("404 Not Found")))
+				})
+			})
+
+			Context("when the local manifest does not exist", func() {
+				It("returns an error", func() {
+					_, err := compiler.NewRemoteStemcell(server.URL+"/stemcell.tgz", "some-sha1", filepath.Join(tempDir, "missing.MF"))
+					Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+				})
+			})
+		})
+	})
 })
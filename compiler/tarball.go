@@ -0,0 +1,57 @@
+package compiler
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+func readManifest(reader io.Reader, manifestName, source string) ([]byte, error) {
+	gr, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	header, err := tr.Next()
+	for err == nil {
+		if filepath.Base(header.Name) == manifestName {
+			break
+		}
+
+		header, err = tr.Next()
+	}
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("could not find %s in %q", manifestName, source)
+		}
+
+		return nil, fmt.Errorf("error while reading %q: %s", source, err)
+	}
+
+	return ioutil.ReadAll(tr)
+}
+
+// manifestClient fetches manifests from the head of remote tarballs, which is
+// all that is read of them, so a minute is plenty.
+var manifestClient = &http.Client{Timeout: time.Minute}
+
+func readRemoteManifest(url, manifestName string) ([]byte, error) {
+	resp, err := manifestClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response fetching %q: %s", url, resp.Status)
+	}
+
+	return readManifest(resp.Body, manifestName, url)
+}
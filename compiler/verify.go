@@ -0,0 +1,86 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+type releaseEntry struct {
+	name        string
+	version     string
+	fingerprint string
+}
+
+// VerifyCompiledRelease checks that the compiled release is the source release:
+// the same name and version, and the same jobs and packages with the same
+// versions and fingerprints. Its error lists every discrepancy.
+func VerifyCompiledRelease(source Release, compiled ReleaseManifest) error {
+	var discrepancies []string
+
+	if compiled.Name != source.Name || compiled.Version != source.Version {
+		discrepancies = append(discrepancies, fmt.Sprintf("release is %s %s, expected %s %s", compiled.Name, compiled.Version, source.Name, source.Version))
+	}
+
+	var sourceJobs, compiledJobs []releaseEntry
+	for _, job := range source.Jobs {
+		sourceJobs = append(sourceJobs, releaseEntry{job.Name, job.Version, job.Fingerprint})
+	}
+	for _, job := range compiled.Jobs {
+		compiledJobs = append(compiledJobs, releaseEntry{job.Name, job.Version, job.Fingerprint})
+	}
+	discrepancies = append(discrepancies, compareEntries("job", sourceJobs, compiledJobs)...)
+
+	var sourcePackages, compiledPackages []releaseEntry
+	for _, pkg := range source.Packages {
+		sourcePackages = append(sourcePackages, releaseEntry{pkg.Name, pkg.Version, pkg.Fingerprint})
+	}
+	for _, pkg := range compiled.CompiledPackages {
+		compiledPackages = append(compiledPackages, releaseEntry{pkg.Name, pkg.Version, pkg.Fingerprint})
+	}
+	discrepancies = append(discrepancies, compareEntries("package", sourcePackages, compiledPackages)...)
+
+	if len(discrepancies) > 0 {
+		return fmt.Errorf("compiled release does not match release %s %s: %s", source.Name, source.Version, strings.Join(discrepancies, "; "))
+	}
+
+	return nil
+}
+
+// compareEntries describes the entries that are missing from the compiled
+// release, those whose version or fingerprint differs and those that are not
+// in the source release.
+func compareEntries(kind string, source, compiled []releaseEntry) []string {
+	var discrepancies []string
+
+	compiledByName := map[string]releaseEntry{}
+	for _, entry := range compiled {
+		compiledByName[entry.name] = entry
+	}
+
+	sourceNames := map[string]bool{}
+	for _, expected := range source {
+		sourceNames[expected.name] = true
+
+		actual, ok := compiledByName[expected.name]
+		if !ok {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s %s is missing", kind, expected.name))
+			continue
+		}
+
+		if actual.version != expected.version {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s %s has version %s, expected %s", kind, expected.name, actual.version, expected.version))
+		}
+
+		if actual.fingerprint != expected.fingerprint {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s %s has fingerprint %s, expected %s", kind, expected.name, actual.fingerprint, expected.fingerprint))
+		}
+	}
+
+	for _, entry := range compiled {
+		if !sourceNames[entry.name] {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s %s is not in the source release", kind, entry.name))
+		}
+	}
+
+	return discrepancies
+}
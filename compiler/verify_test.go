@@ -0,0 +1,76 @@
+package compiler_test
+
+import (
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VerifyCompiledRelease", func() {
+	var (
+		source   compiler.Release
+		compiled compiler.ReleaseManifest
+	)
+
+	BeforeEach(func() {
+		source = compiler.Release{
+			Name:    "some-release",
+			Version: "42",
+			Jobs: []compiler.ReleaseJob{
+				{Name: "some-job", Version: "some-job-version", Fingerprint: "some-job-fingerprint"},
+			},
+			Packages: []compiler.ReleasePackage{
+				{Name: "some-package", Version: "some-package-version", Fingerprint: "some-package-fingerprint"},
+			},
+		}
+
+		compiled = compiler.ReleaseManifest{
+			Name:    "some-release",
+			Version: "42",
+			Jobs: []compiler.ReleaseJob{
+				{Name: "some-job", Version: "some-job-version", Fingerprint: "some-job-fingerprint"},
+			},
+			CompiledPackages: []compiler.ReleasePackage{
+				{Name: "some-package", Version: "some-package-version", Fingerprint: "some-package-fingerprint", Stemcell: "some-stemcell/1.2.3"},
+			},
+		}
+	})
+
+	It("succeeds when the compiled release has the jobs and packages of the source release", func() {
+		Expect(compiler.VerifyCompiledRelease(source, compiled)).To(Succeed())
+	})
+
+	It("flags a different release name or version", func() {
+		compiled.Version = "43"
+
+		err := compiler.VerifyCompiledRelease(source, compiled)
+		Expect(err).To(MatchError("compiled release does not match release some-release 42: release is some-release 43, expected some-release 42"))
+	})
+
+	It("flags missing jobs and packages", func() {
+		compiled.Jobs = nil
+		compiled.CompiledPackages = nil
+
+		err := compiler.VerifyCompiledRelease(source, compiled)
+		Expect(err).To(MatchError("compiled release does not match release some-release 42: job some-job is missing; package some-package is missing"))
+	})
+
+	It("flags extra jobs and packages", func() {
+		compiled.Jobs = append(compiled.Jobs, compiler.ReleaseJob{Name: "other-job"})
+		compiled.CompiledPackages = append(compiled.CompiledPackages, compiler.ReleasePackage{Name: "other-package"})
+
+		err := compiler.VerifyCompiledRelease(source, compiled)
+		Expect(err).To(MatchError("compiled release does not match release some-release 42: job other-job is not in the source release; package other-package is not in the source release"))
+	})
+
+	It("flags mismatched versions and fingerprints", func() {
+		compiled.Jobs[0].Version = "other-job-version"
+		compiled.CompiledPackages[0].Fingerprint = "other-package-fingerprint"
+
+		err := compiler.VerifyCompiledRelease(source, compiled)
+		Expect(err).To(MatchError("compiled release does not match release some-release 42: " +
+			"job some-job has version other-job-version, expected some-job-version; " +
+			"package some-package has fingerprint other-package-fingerprint, expected some-package-fingerprint"))
+	})
+})
@@ -0,0 +1,26 @@
+package testhelpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+
+	. "github.com/onsi/gomega"
+)
+
+// GenerateKeys returns a new PEM encoded ed25519 key pair, the private key as
+// PKCS #8 and the public key as PKIX.
+func GenerateKeys() (privateKey, publicKey string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	privateBytes, err := x509.MarshalPKCS8PrivateKey(private)
+	Expect(err).NotTo(HaveOccurred())
+
+	publicBytes, err := x509.MarshalPKIXPublicKey(public)
+	Expect(err).NotTo(HaveOccurred())
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateBytes})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes}))
+}
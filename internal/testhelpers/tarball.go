@@ -0,0 +1,44 @@
+// Package testhelpers holds the helpers shared by the tests of the other
+// packages.
+package testhelpers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"sort"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// Tarball returns a gzipped tarball of the files, in the order of their names.
+func Tarball(files map[string]string) []byte {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buffer bytes.Buffer
+	gw := gzip.NewWriter(&buffer)
+	tw := tar.NewWriter(gw)
+
+	for _, name := range names {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    "./" + name,
+			Size:    int64(len(files[name])),
+			Mode:    int64(0644),
+			ModTime: time.Now(),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = tw.Write([]byte(files[name]))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	Expect(tw.Close()).To(Succeed())
+	Expect(gw.Close()).To(Succeed())
+
+	return buffer.Bytes()
+}
@@ -4,4 +4,14 @@ type Source struct {
 	BoshTarget   string
 	BoshUser     string
 	BoshPassword string
+	PrivateYML   string `json:"private_yml"`
+
+	VMType             string   `json:"vm_type"`
+	Network            string   `json:"network"`
+	AZs                []string `json:"azs"`
+	CompilationWorkers int      `json:"compilation_workers"`
+
+	LogFormat string `json:"log_format"`
+
+	SigningKey string `json:"signing_key"`
 }
@@ -8,7 +8,49 @@ type OutRequest struct {
 }
 
 type Params struct {
-	ReleaseDir     string `json:"release_dir"`
-	ReleaseVersion string `json:"release_version"`
-	StemcellDir    string `json:"stemcell_dir"`
+	ReleaseDir          string `json:"release_dir"`
+	ReleaseTarball      string `json:"release_tarball"`
+	ReleaseName         string `json:"release_name"`
+	ReleaseVersion      string `json:"release_version"`
+	ReleaseVersionFile  string `json:"release_version_file"`
+	ReleaseVersionFrom  string `json:"release_version_from"`
+	CompiledReleasesDir string `json:"compiled_releases_dir"`
+	ReleaseBuilder      string `json:"release_builder"`
+	Final               bool   `json:"final"`
+	ReleaseURL          string `json:"release_url"`
+	ReleaseSHA1         string `json:"release_sha1"`
+	ReleaseManifest     string `json:"release_manifest"`
+	StemcellDir         string `json:"stemcell_dir"`
+	StemcellURL         string `json:"stemcell_url"`
+	StemcellSHA1        string `json:"stemcell_sha1"`
+	StemcellManifest    string `json:"stemcell_manifest"`
+	OutputDir           string `json:"output_dir"`
+	ForceUpload         bool   `json:"force_upload"`
+	AllowDirty          bool   `json:"allow_dirty"`
+
+	VMType             string   `json:"vm_type"`
+	Network            string   `json:"network"`
+	AZs                []string `json:"azs"`
+	CompilationWorkers int      `json:"compilation_workers"`
+
+	Manifest string   `json:"manifest"`
+	OpsFiles []string `json:"ops_files"`
+
+	ExcludeRuntimeConfigAddons bool `json:"exclude_runtime_config_addons"`
+
+	DryRun bool `json:"dry_run"`
+}
+
+type OutResponse struct {
+	Version  Version         `json:"version"`
+	Metadata []MetadataField `json:"metadata"`
+}
+
+type Version struct {
+	CompiledRelease string `json:"compiled_release"`
+}
+
+type MetadataField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
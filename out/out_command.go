@@ -1,206 +1,298 @@
 package out
 
 import (
+	"crypto/rand"
+	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
+	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"time"
 
+	"github.com/aditya87/precompiled-bosh-release-resource/builder"
 	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
 	"github.com/pivotal-cf-experimental/bosh-test/bosh"
 )
 
+// OutCommand adapts an out request to a compiler.Application: it resolves the
+// release and stemcell tarballs named by the params and leaves the compilation
+// itself to the Application.
 type OutCommand struct {
-	BOSHClient     boshClient
-	releaseDir     string
-	releaseVersion string
-	stemcellDir    string
-	release        Release
-	stemcell       Stemcell
+	Application         compiler.Application
+	releaseDir          string
+	releaseTarball      string
+	releaseName         string
+	version             string
+	versionFile         string
+	versionFrom         string
+	compiledReleasesDir string
+	releaseBuilder      string
+	final               bool
+	privateYML          string
+	stemcellDir         string
+	logFormat           string
 }
 
-type boshClient interface {
-	Resource(resourceID string) (file io.ReadCloser, err error)
-	ExportRelease(deploymentName, releaseName, releaseVersion, stemcellName, stemcellVersion string) (resourceID string, err error)
-	Deploy(manifest []byte) (taskID int, err error)
-	UploadStemcell(stemcell bosh.SizeReader) (taskID int, err error)
-	UploadRelease(release bosh.SizeReader) (taskID int, err error)
-	Info() (bosh.DirectorInfo, error)
-	DeleteDeployment(name string) error
-	Cleanup() (taskID int, err error)
-	Deployments() (deploymentList []bosh.Deployment, err error)
-	Stemcell(name string) (bosh.Stemcell, error)
+type releaseBuilder interface {
+	Build(name, version string) (tarballPath string, err error)
 }
 
-type manifestGenerator interface {
-	Generate(directorUUID, deploymentName string, release compiler.Release, stemcell compiler.Stemcell) (manifest []byte, err error)
-}
+func NewOutCommand(request OutRequest) *OutCommand {
+	boshConfig := bosh.Config{
+		URL:              request.Source.BoshTarget,
+		Username:         request.Source.BoshUser,
+		Password:         request.Source.BoshPassword,
+		AllowInsecureSSL: true,
+	}
 
-func existsInSlice(slice []string, str string) bool {
-	for _, x := range slice {
-		if x == str {
-			return true
-		}
+	command := &OutCommand{
+		Application: compiler.Application{
+			ReleaseURL:                 request.Params.ReleaseURL,
+			ReleaseSHA1:                request.Params.ReleaseSHA1,
+			ReleaseManifestPath:        request.Params.ReleaseManifest,
+			StemcellURL:                request.Params.StemcellURL,
+			StemcellSHA1:               request.Params.StemcellSHA1,
+			StemcellManifestPath:       request.Params.StemcellManifest,
+			OutputDirectory:            request.Params.OutputDir,
+			ForceUpload:                request.Params.ForceUpload,
+			AllowDirty:                 request.Params.AllowDirty,
+			Deployment:                 deploymentSettings(request),
+			ManifestTemplatePath:       request.Params.Manifest,
+			OpsFilePaths:               request.Params.OpsFiles,
+			ExcludeRuntimeConfigAddons: request.Params.ExcludeRuntimeConfigAddons,
+			DryRun:                     request.Params.DryRun,
+			SigningKey:                 request.Source.SigningKey,
+			BOSHClient:                 compiler.NewBOSHClient(boshConfig),
+			DirectorClient:             compiler.NewDirectorClient(boshConfig),
+			ManifestGenerator:          compiler.NewManifestGenerator(),
+			GUIDGenerator:              compiler.NewGUIDGenerator(rand.Reader).Generate,
+			Logger:                     log.New(os.Stderr, "", 0),
+		},
+		releaseDir:          request.Params.ReleaseDir,
+		releaseTarball:      request.Params.ReleaseTarball,
+		releaseName:         request.Params.ReleaseName,
+		version:             request.Params.ReleaseVersion,
+		versionFile:         request.Params.ReleaseVersionFile,
+		versionFrom:         request.Params.ReleaseVersionFrom,
+		compiledReleasesDir: request.Params.CompiledReleasesDir,
+		releaseBuilder:      request.Params.ReleaseBuilder,
+		final:               request.Params.Final,
+		privateYML:          request.Source.PrivateYML,
+		stemcellDir:         request.Params.StemcellDir,
+		logFormat:           request.Source.LogFormat,
 	}
-	return false
+
+	if request.Source.LogFormat == "json" {
+		logger := compiler.NewJSONLogger(os.Stderr, time.Now)
+		command.Application.Logger = logger
+		command.Application.EventLogger = logger
+	}
+
+	return command
 }
 
-func NewOutCommand(request OutRequest) *OutCommand {
-	return &OutCommand{
-		BOSHClient: bosh.NewClient(bosh.Config{
-			URL:              request.Source.BoshTarget,
-			Username:         request.Source.BoshUser,
-			Password:         request.Source.BoshPassword,
-			AllowInsecureSSL: true,
-		}),
-		releaseDir:     request.Params.ReleaseDir,
-		releaseVersion: request.Params.ReleaseVersion,
-		stemcellDir:    request.Params.StemcellDir,
+// deploymentSettings takes the placement of the compile deployment from the
+// source, with any setting given in the params taking precedence.
+func deploymentSettings(request OutRequest) compiler.DeploymentSettings {
+	settings := compiler.DeploymentSettings{
+		VMType:             request.Source.VMType,
+		Network:            request.Source.Network,
+		AZs:                request.Source.AZs,
+		CompilationWorkers: request.Source.CompilationWorkers,
+	}
+
+	if request.Params.VMType != "" {
+		settings.VMType = request.Params.VMType
+	}
+
+	if request.Params.Network != "" {
+		settings.Network = request.Params.Network
+	}
+
+	if len(request.Params.AZs) > 0 {
+		settings.AZs = request.Params.AZs
+	}
+
+	if request.Params.CompilationWorkers != 0 {
+		settings.CompilationWorkers = request.Params.CompilationWorkers
 	}
+
+	return settings
 }
 
-func (o *OutCommand) getReleaseName() string {
-	matches := regexp.MustCompile("(.*)/(.*)$").FindStringSubmatch(o.releaseDir)
-	return matches[len(matches)-1]
+func (o *OutCommand) getReleaseName() (string, error) {
+	if o.releaseName != "" {
+		return o.releaseName, nil
+	}
+
+	return builder.ReleaseName(o.releaseDir)
 }
 
-func (o *OutCommand) UploadStemcell() error {
-	stemcellDirInfo, err := ioutil.ReadDir(o.stemcellDir)
+// CreateRelease builds a dev release from the release directory, natively by
+// default or with the bosh CLI when release_builder is "cli", and returns the
+// path of the resulting tarball. The release is named by release_name or,
+// failing that, the release config. When final is set a final release is
+// built instead, with its blobs fetched from the configured blobstore. A
+// release directory with uncommitted changes is refused unless allow_dirty is
+// set.
+func (o *OutCommand) CreateRelease() (string, error) {
+	if !o.Application.AllowDirty {
+		if _, dirty := builder.GitState(o.releaseDir); dirty {
+			return "", fmt.Errorf("release directory %q has uncommitted changes, set allow_dirty to build it anyway", o.releaseDir)
+		}
+	}
+
+	err := os.RemoveAll(filepath.Join(o.releaseDir, "dev_releases"))
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
-	stemcellTarballPath := filepath.Join(o.stemcellDir, stemcellDirInfo[0].Name())
-	stemcell, err := compiler.NewStemcell(stemcellTarballPath)
+	releaseName, err := o.getReleaseName()
 	if err != nil {
-		panic(err)
-	}
-
-	existingStemcell, err := o.BOSHClient.Stemcell(stemcell.Name)
-	if err != nil && !strings.Contains(err.Error(), "could not be found") {
-		panic(err)
-	} else if err != nil && strings.Contains(err.Error(), "could not be found") {
-		fmt.Printf("uploading stemcell %s %s\n", stemcell.Name, stemcell.Version)
-		_, err = o.BOSHClient.UploadStemcell(stemcell)
-		return err
-	} else {
-		if existingStemcell.Name == stemcell.Name && !existsInSlice(existingStemcell.Versions, stemcell.Version) {
-			fmt.Printf("uploading stemcell %s %s\n", stemcell.Name, stemcell.Version)
-			_, err = o.BOSHClient.UploadStemcell(stemcell)
-			return err
-		}
-		return nil
+		return "", err
 	}
-}
 
-func (o *OutCommand) CreateRelease() error {
-	createReleaseCmd := exec.Command("bosh", "create", "release", "--force", "--name", o.getReleaseName(), "--version", o.releaseVersion, "--with-tarball")
-	createReleaseCmd.Dir = o.releaseDir
-	err := os.RemoveAll(filepath.Join(o.releaseDir, "dev_releases"))
+	releaseVersion, err := o.releaseVersion(releaseName)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
-	err = createReleaseCmd.Run()
+	tarballPath, err := o.buildRelease(releaseName, releaseVersion)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
-	return nil
-}
 
-func (o *OutCommand) Run() error {
-	fmt.Println("deleting existing deployments")
-	deploymentList, err := o.BOSHClient.Deployments()
+	release, err := compiler.NewRelease(tarballPath)
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+	defer release.Close()
+
+	if release.Name != releaseName {
+		return "", fmt.Errorf("release tarball %q is named %q, expected %q", tarballPath, release.Name, releaseName)
 	}
 
-	for _, deployment := range deploymentList {
-		err = o.BOSHClient.DeleteDeployment(o.getReleaseName())
+	return tarballPath, nil
+}
+
+func (o *OutCommand) buildRelease(name, version string) (string, error) {
+	if o.final {
+		if o.releaseBuilder == "cli" {
+			return "", errors.New("final releases are only supported by the native release builder")
+		}
+
+		blobstore, err := builder.NewBlobstore(o.releaseDir, o.privateYML)
 		if err != nil {
-			panic(err)
+			return "", err
 		}
+
+		return builder.NewReleaseBuilder(o.releaseDir).BuildFinal(name, version, blobstore)
 	}
 
-	fmt.Println("fetching bosh director information")
-	directorInfo, err := o.BOSHClient.Info()
-	if err != nil {
-		panic(err)
+	var releaseBuilder releaseBuilder = builder.NewReleaseBuilder(o.releaseDir)
+	if o.releaseBuilder == "cli" {
+		releaseBuilder = builder.NewCLIReleaseBuilder(o.releaseDir, o.Application.Logger)
 	}
 
-	fmt.Println("creating release")
-	err = o.CreateRelease()
-	if err != nil {
-		panic(err)
+	return releaseBuilder.Build(name, version)
+}
+
+// ReleaseTarball returns the release tarball to compile: the single file
+// matching release_tarball when it is given, otherwise a release created from
+// release_dir.
+func (o *OutCommand) ReleaseTarball() (string, error) {
+	if o.releaseTarball == "" {
+		o.Application.Logger.Println("creating release")
+		return o.CreateRelease()
 	}
 
-	fmt.Println("parsing release details")
-	release, err := compiler.NewRelease(filepath.Join(o.releaseDir, fmt.Sprintf("dev_releases/%s/%s-%s.tgz", o.getReleaseName(), o.getReleaseName(), o.releaseVersion)))
+	return singleMatch(o.releaseTarball, "release tarball")
+}
+
+func (o *OutCommand) stemcellTarball() (string, error) {
+	return singleMatch(filepath.Join(o.stemcellDir, "*.tgz"), "stemcell tarball")
+}
+
+func singleMatch(pattern, description string) (string, error) {
+	matches, err := filepath.Glob(pattern)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
-	err = o.UploadStemcell()
-	if err != nil {
-		panic(err)
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no %s matches %q", description, pattern)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%s %q is ambiguous, it matches %s", description, pattern, strings.Join(matches, ", "))
 	}
+}
 
-	fmt.Printf("uploading release %s %s\n", release.Name, release.Version)
-	_, err = o.BOSHClient.UploadRelease(release)
-	if err != nil {
-		panic(err)
+// Run resolves the release and stemcell tarballs that the director is not
+// fetching by url itself and compiles the release with the Application.
+func (o *OutCommand) Run() (compiler.Result, error) {
+	if o.logFormat != "" && o.logFormat != "text" && o.logFormat != "json" {
+		return compiler.Result{}, fmt.Errorf("unknown log_format %q, expected text or json", o.logFormat)
 	}
 
-	fmt.Println("generating deployment manifest")
-	manifest, err := compiler.ManifestGenerator.Generate(directorInfo.UUID, release.Name, release, stemcell)
-	if err != nil {
-		panic(err)
+	app := o.Application
+
+	if app.ReleaseURL == "" {
+		releaseTarballPath, err := o.ReleaseTarball()
+		if err != nil {
+			return compiler.Result{}, err
+		}
+
+		app.ReleaseTarballPath = releaseTarballPath
 	}
 
-	fmt.Println("deploying to bosh director")
-	_, err = o.BOSHClient.Deploy(manifest)
-	if err != nil {
-		panic(err)
+	if app.StemcellURL == "" {
+		stemcellTarballPath, err := o.stemcellTarball()
+		if err != nil {
+			return compiler.Result{}, err
+		}
+
+		app.StemcellTarballPath = stemcellTarballPath
 	}
 
-	fmt.Println("compiling the release")
-	resourceID, err := o.BOSHClient.ExportRelease(deploymentName, release.Name, release.Version, stemcell.Name, stemcell.Version)
-	if err != nil {
-		panic(err)
+	return app.Run()
+}
+
+// Response describes a compilation result as the version and metadata that
+// out emits to Concourse. A dry run compiles nothing, so its version is empty
+// and its metadata lists the planned actions.
+func Response(result compiler.Result) OutResponse {
+	response := OutResponse{
+		Metadata: []MetadataField{
+			{Name: "commit_hash", Value: result.CommitHash},
+		},
 	}
 
-	fmt.Println("downloading the compiled release")
-	compiledTarballPath := filepath.Join(a.OutputDirectory, fmt.Sprintf("%s-%s-%s.tgz", release.Name, release.Semver, stemcell.Semver))
-	fd, err := os.OpenFile(compiledTarballPath, os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		panic(err)
+	if result.CompiledReleasePath != "" {
+		response.Version.CompiledRelease = filepath.Base(result.CompiledReleasePath)
 	}
 
-	resource, err := o.BOSHClient.Resource(resourceID)
-	if err != nil {
-		panic(err)
+	if result.SBOMPath != "" {
+		response.Metadata = append(response.Metadata, MetadataField{Name: "sbom", Value: filepath.Base(result.SBOMPath)})
 	}
 
-	_, err = io.Copy(fd, resource)
-	if err != nil {
-		panic(err)
+	if result.SignaturePath != "" {
+		response.Metadata = append(response.Metadata, MetadataField{Name: "signature", Value: filepath.Base(result.SignaturePath)})
 	}
 
-	fmt.Println("deleting the deployment")
-	err = o.BOSHClient.DeleteDeployment(deploymentName)
-	if err != nil {
-		panic(err)
+	for _, skipped := range result.SkippedUploads {
+		response.Metadata = append(response.Metadata, MetadataField{Name: "skipped_upload", Value: skipped})
 	}
 
-	fmt.Println("cleaning up")
-	_, err = o.BOSHClient.Cleanup()
-	if err != nil {
-		panic(err)
+	for _, addon := range result.RuntimeConfigAddons {
+		response.Metadata = append(response.Metadata, MetadataField{Name: "runtime_config_addon", Value: addon})
+	}
+
+	for _, action := range result.Plan {
+		response.Metadata = append(response.Metadata, MetadataField{Name: "planned_action", Value: action})
 	}
 
-	return nil
+	return response
 }
@@ -2,15 +2,20 @@ package out_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
-	"regexp"
-	"strings"
 
 	"github.com/aditya87/precompiled-bosh-release-resource"
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
 	"github.com/aditya87/precompiled-bosh-release-resource/compiler/fakes"
+	"github.com/aditya87/precompiled-bosh-release-resource/internal/testhelpers"
 	"github.com/aditya87/precompiled-bosh-release-resource/out"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -20,7 +25,9 @@ import (
 var _ = Describe("Out Command", func() {
 	var (
 		boshClient        *fakes.BOSHClient
+		directorClient    *fakes.DirectorClient
 		manifestGenerator *fakes.ManifestGenerator
+		logger            *fakes.Logger
 		command           *out.OutCommand
 		boshTarget        string
 		boshUser          string
@@ -29,10 +36,20 @@ var _ = Describe("Out Command", func() {
 		releaseVersion    string
 		stemcellDirPath   string
 		stemcellTarball   string
+		outputDirPath     string
 		request           out.OutRequest
 		releaseName       string
 	)
 
+	newCommand := func() {
+		command = out.NewOutCommand(request)
+		command.Application.BOSHClient = boshClient
+		command.Application.DirectorClient = directorClient
+		command.Application.ManifestGenerator = manifestGenerator
+		command.Application.GUIDGenerator = func() (string, error) { return "some-guid", nil }
+		command.Application.Logger = logger
+	}
+
 	BeforeEach(func() {
 		var err error
 		boshTarget = "http://fake-bosh-target"
@@ -61,6 +78,8 @@ var _ = Describe("Out Command", func() {
 		Expect(err).ToNot(HaveOccurred())
 		err = os.Mkdir("dev_releases/foo", 0700)
 		Expect(err).ToNot(HaveOccurred())
+		err = ioutil.WriteFile("config/final.yml", []byte("---\nfinal_name: fake-bosh-release\n"), 0600)
+		Expect(err).ToNot(HaveOccurred())
 
 		stemcellDirPath, err = ioutil.TempDir("", "stemcell-dir")
 		Expect(err).ToNot(HaveOccurred())
@@ -78,8 +97,13 @@ version: 42
 `)))
 		Expect(err).NotTo(HaveOccurred())
 
+		outputDirPath, err = ioutil.TempDir("", "output-dir")
+		Expect(err).ToNot(HaveOccurred())
+
 		boshClient = &fakes.BOSHClient{}
+		directorClient = &fakes.DirectorClient{}
 		manifestGenerator = &fakes.ManifestGenerator{}
+		logger = &fakes.Logger{}
 
 		request = out.OutRequest{
 			Source: precompiled_release_resource.Source{
@@ -91,13 +115,12 @@ version: 42
 				ReleaseDir:     releaseDirPath,
 				ReleaseVersion: releaseVersion,
 				StemcellDir:    stemcellDirPath,
+				OutputDir:      outputDirPath,
 			},
 		}
 
-		command = out.NewOutCommand(request)
-		command.BOSHClient = boshClient
-		matches := regexp.MustCompile("(.*)/(.*)$").FindStringSubmatch(releaseDirPath)
-		releaseName = matches[len(matches)-1]
+		newCommand()
+		releaseName = "fake-bosh-release"
 	})
 
 	AfterEach(func() {
@@ -106,69 +129,344 @@ version: 42
 
 		err = os.RemoveAll(releaseDirPath)
 		Expect(err).NotTo(HaveOccurred())
+
+		err = os.RemoveAll(outputDirPath)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("NewOutCommand", func() {
+		It("configures the application from the params", func() {
+			request.Params.ReleaseURL = "http://example.com/release.tgz"
+			request.Params.ReleaseSHA1 = "some-release-sha1"
+			request.Params.StemcellURL = "http://example.com/stemcell.tgz"
+			request.Params.StemcellSHA1 = "some-stemcell-sha1"
+			request.Params.ForceUpload = true
+			request.Params.Manifest = "some-manifest.yml"
+			request.Params.OpsFiles = []string{"ops-1.yml", "ops-2.yml"}
+			request.Params.ExcludeRuntimeConfigAddons = true
+			request.Params.DryRun = true
+			request.Source.SigningKey = "some-signing-key"
+
+			command = out.NewOutCommand(request)
+			Expect(command.Application.ReleaseURL).To(Equal("http://example.com/release.tgz"))
+			Expect(command.Application.ReleaseSHA1).To(Equal("some-release-sha1"))
+			Expect(command.Application.StemcellURL).To(Equal("http://example.com/stemcell.tgz"))
+			Expect(command.Application.StemcellSHA1).To(Equal("some-stemcell-sha1"))
+			Expect(command.Application.OutputDirectory).To(Equal(outputDirPath))
+			Expect(command.Application.ForceUpload).To(BeTrue())
+			Expect(command.Application.ManifestTemplatePath).To(Equal("some-manifest.yml"))
+			Expect(command.Application.OpsFilePaths).To(Equal([]string{"ops-1.yml", "ops-2.yml"}))
+			Expect(command.Application.ExcludeRuntimeConfigAddons).To(BeTrue())
+			Expect(command.Application.DryRun).To(BeTrue())
+			Expect(command.Application.SigningKey).To(Equal("some-signing-key"))
+			Expect(command.Application.BOSHClient).NotTo(BeNil())
+			Expect(command.Application.ManifestGenerator).NotTo(BeNil())
+			Expect(command.Application.Logger).NotTo(BeNil())
+		})
+		It("places the deployment with the source settings overridden by the params", func() {
+			request.Source.VMType = "some-vm-type"
+			request.Source.Network = "some-network"
+			request.Source.AZs = []string{"z1"}
+			request.Source.CompilationWorkers = 4
+			request.Params.Network = "other-network"
+			request.Params.AZs = []string{"z2", "z3"}
+
+			command = out.NewOutCommand(request)
+			Expect(command.Application.DirectorClient).NotTo(BeNil())
+			Expect(command.Application.Deployment).To(Equal(compiler.DeploymentSettings{
+				VMType:             "some-vm-type",
+				Network:            "other-network",
+				AZs:                []string{"z2", "z3"},
+				CompilationWorkers: 4,
+			}))
+		})
 	})
 
-	Describe("UploadStemcell", func() {
-		Context("The stemcell does not already exist on the bosh director", func() {
+	Describe("CreateRelease", func() {
+		It("creates release with tarball", func() {
+			releasePath, err := command.CreateRelease()
+			Expect(err).NotTo(HaveOccurred())
+			expectedReleasePath := filepath.Join(releaseDirPath, fmt.Sprintf("dev_releases/%s/%s-%s.tgz", releaseName, releaseName, releaseVersion))
+			Expect(releasePath).To(Equal(expectedReleasePath))
+			Expect(expectedReleasePath).To(BeAnExistingFile())
+			Expect(filepath.Join(releaseDirPath, "dev_releases/foo")).NotTo(BeADirectory())
+		})
+
+		It("names the release after release_name when given", func() {
+			request.Params.ReleaseName = "other-release"
+			newCommand()
+
+			releasePath, err := command.CreateRelease()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(releasePath).To(Equal(filepath.Join(releaseDirPath, fmt.Sprintf("dev_releases/other-release/other-release-%s.tgz", releaseVersion))))
+		})
+
+		It("falls back to config/dev.yml for the release name", func() {
+			err := os.Remove(filepath.Join(releaseDirPath, "config/final.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			err = ioutil.WriteFile(filepath.Join(releaseDirPath, "config/dev.yml"), []byte("---\ndev_name: dev-release\n"), 0600)
+			Expect(err).NotTo(HaveOccurred())
+
+			releasePath, err := command.CreateRelease()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(releasePath).To(Equal(filepath.Join(releaseDirPath, fmt.Sprintf("dev_releases/dev-release/dev-release-%s.tgz", releaseVersion))))
+		})
+
+		Context("when the version is read from a file", func() {
+			It("builds the release at that version", func() {
+				versionFile := filepath.Join(releaseDirPath, "version")
+				err := ioutil.WriteFile(versionFile, []byte("1.2.3\n"), 0600)
+				Expect(err).NotTo(HaveOccurred())
+
+				request.Params.ReleaseVersion = ""
+				request.Params.ReleaseVersionFile = versionFile
+				newCommand()
+
+				releasePath, err := command.CreateRelease()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(releasePath).To(HaveSuffix("fake-bosh-release-1.2.3.tgz"))
+			})
+		})
+
+		Context("when the version is read from git tags", func() {
+			It("builds the release at the latest tag", func() {
+				for _, args := range [][]string{
+					{"init", "-q"},
+					{"add", "."},
+					{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial"},
+					{"tag", "v4.5"},
+				} {
+					git := exec.Command("git", args...)
+					git.Dir = releaseDirPath
+					output, err := git.CombinedOutput()
+					Expect(err).NotTo(HaveOccurred(), string(output))
+				}
+
+				request.Params.ReleaseVersion = ""
+				request.Params.ReleaseVersionFrom = "git"
+				newCommand()
+
+				releasePath, err := command.CreateRelease()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(releasePath).To(HaveSuffix("fake-bosh-release-4.5.tgz"))
+			})
+		})
+
+		Context("when the release directory has uncommitted changes", func() {
 			BeforeEach(func() {
-				boshClient.StemcellCall.Returns.Stemcell = bosh.Stemcell{}
-				boshClient.StemcellCall.Returns.Error = fmt.Errorf("stemcell some-stemcell could not be found")
+				for _, args := range [][]string{
+					{"init", "-q"},
+					{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial"},
+				} {
+					git := exec.Command("git", args...)
+					git.Dir = releaseDirPath
+					output, err := git.CombinedOutput()
+					Expect(err).NotTo(HaveOccurred(), string(output))
+				}
+			})
+
+			It("refuses to build the release", func() {
+				_, err := command.CreateRelease()
+				Expect(err).To(MatchError(fmt.Sprintf("release directory %q has uncommitted changes, set allow_dirty to build it anyway", releaseDirPath)))
 			})
 
-			It("uploads the stemcell to the bosh director", func() {
-				err := command.UploadStemcell()
+			It("builds the release when allow_dirty is set", func() {
+				request.Params.AllowDirty = true
+				newCommand()
+
+				releasePath, err := command.CreateRelease()
 				Expect(err).NotTo(HaveOccurred())
+				Expect(releasePath).To(BeAnExistingFile())
+			})
+		})
 
-				Expect(boshClient.StemcellCall.CallCount).To(Equal(1))
-				Expect(boshClient.StemcellCall.Receives).To(Equal("some-stemcell"))
-				Expect(boshClient.UploadStemcellCall.Receives.Contents).NotTo(BeNil())
-				actualContents, err := ioutil.ReadAll(boshClient.UploadStemcellCall.Receives.Contents)
+		Context("when the version is auto-incremented", func() {
+			var compiledReleasesDir string
+
+			BeforeEach(func() {
+				var err error
+				compiledReleasesDir, err = ioutil.TempDir("", "compiled-releases")
 				Expect(err).NotTo(HaveOccurred())
 
-				expectedContents, err := ioutil.ReadFile(stemcellTarball)
+				for _, name := range []string{
+					"fake-bosh-release-1.9.0-3445.11.0.tgz",
+					"fake-bosh-release-1.10.2-3445.11.0.tgz",
+					"other-release-7.0.0-3445.11.0.tgz",
+				} {
+					err = ioutil.WriteFile(filepath.Join(compiledReleasesDir, name), []byte{}, 0600)
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				request.Params.ReleaseVersion = ""
+				request.Params.ReleaseVersionFrom = "auto"
+				request.Params.CompiledReleasesDir = compiledReleasesDir
+				newCommand()
+			})
+
+			AfterEach(func() {
+				err := os.RemoveAll(compiledReleasesDir)
 				Expect(err).NotTo(HaveOccurred())
+			})
 
-				Expect(actualContents).To(Equal(expectedContents))
+			It("builds the release one patch past the latest compiled version", func() {
+				releasePath, err := command.CreateRelease()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(releasePath).To(HaveSuffix("fake-bosh-release-1.10.3.tgz"))
 			})
 		})
 
-		Context("The stemcell already exists on the bosh director", func() {
+		Context("when a final release is requested", func() {
+			var blobstorePath string
+
 			BeforeEach(func() {
-				boshClient.StemcellCall.Returns.Stemcell = bosh.Stemcell{
-					Name:     "some-stemcell",
-					Versions: []string{"1.2.3"},
-				}
-				boshClient.StemcellCall.Returns.Error = nil
+				var err error
+				blobstorePath, err = ioutil.TempDir("", "local-blobstore")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = ioutil.WriteFile(filepath.Join(releaseDirPath, "config/final.yml"), []byte(fmt.Sprintf(`---
+final_name: fake-bosh-release
+blobstore:
+  provider: local
+  options:
+    blobstore_path: %s
+`, blobstorePath)), 0600)
+				Expect(err).NotTo(HaveOccurred())
+
+				request.Params.Final = true
+				newCommand()
 			})
 
-			It("does not upload the stemcell to the bosh director", func() {
-				err := command.UploadStemcell()
+			AfterEach(func() {
+				err := os.RemoveAll(blobstorePath)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(boshClient.StemcellCall.CallCount).To(Equal(1))
-				Expect(boshClient.StemcellCall.Receives).To(Equal("some-stemcell"))
-				Expect(boshClient.UploadStemcellCall.CallCount).To(Equal(0))
+			})
+
+			It("builds a final release", func() {
+				releasePath, err := command.CreateRelease()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(releasePath).To(Equal(filepath.Join(releaseDirPath, fmt.Sprintf("releases/%s/%s-%s.tgz", releaseName, releaseName, releaseVersion))))
+				Expect(releasePath).To(BeAnExistingFile())
+			})
+
+			It("returns an error when the private config is not valid", func() {
+				request.Source.PrivateYML = "%%%%%"
+				newCommand()
+
+				_, err := command.CreateRelease()
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("returns an error when the cli release builder is used", func() {
+				request.Params.ReleaseBuilder = "cli"
+				newCommand()
+
+				_, err := command.CreateRelease()
+				Expect(err).To(MatchError("final releases are only supported by the native release builder"))
+			})
+		})
+
+		Context("failure cases", func() {
+			It("returns an error when the version is not a valid semver", func() {
+				request.Params.ReleaseVersion = "1.2.3.4"
+				newCommand()
+
+				_, err := command.CreateRelease()
+				Expect(err).To(MatchError("could not parse semver version from 1.2.3.4"))
+			})
+
+			It("returns an error when no version is given", func() {
+				request.Params.ReleaseVersion = ""
+				newCommand()
+
+				_, err := command.CreateRelease()
+				Expect(err).To(MatchError("one of release_version, release_version_file or release_version_from must be given"))
+			})
+
+			It("returns an error when the release config has no name", func() {
+				err := os.Remove(filepath.Join(releaseDirPath, "config/final.yml"))
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = command.CreateRelease()
+				Expect(err).To(MatchError(ContainSubstring("could not find a release name")))
 			})
 		})
 	})
 
-	Describe("CreateRelease", func() {
-		It("creates release with tarball", func() {
-			err := command.CreateRelease()
+	Describe("ReleaseTarball", func() {
+		It("creates a release when no tarball is given", func() {
+			releasePath, err := command.ReleaseTarball()
 			Expect(err).NotTo(HaveOccurred())
-			expectedReleasePath := filepath.Join(releaseDirPath, fmt.Sprintf("dev_releases/%s/%s-%s.tgz", releaseName, releaseName, releaseVersion))
-			Expect(expectedReleasePath).To(BeAnExistingFile())
-			Expect(filepath.Join(releaseDirPath, "dev_releases/foo")).NotTo(BeADirectory())
+			Expect(releasePath).To(Equal(filepath.Join(releaseDirPath, fmt.Sprintf("dev_releases/%s/%s-%s.tgz", releaseName, releaseName, releaseVersion))))
+		})
+
+		Context("when a release tarball is given", func() {
+			var tarballDir string
+
+			BeforeEach(func() {
+				var err error
+				tarballDir, err = ioutil.TempDir("", "release-tarball")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = createReleaseTarball(filepath.Join(tarballDir, "some-release-1.2.3.tgz"), bytes.NewBuffer([]byte(`---
+name: some-release
+version: 1.2.3
+`)))
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				err := os.RemoveAll(tarballDir)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("uses the tarball matching the glob without creating a release", func() {
+				request.Params.ReleaseTarball = filepath.Join(tarballDir, "some-release-*.tgz")
+				newCommand()
+
+				releasePath, err := command.ReleaseTarball()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(releasePath).To(Equal(filepath.Join(tarballDir, "some-release-1.2.3.tgz")))
+				Expect(filepath.Join(releaseDirPath, "dev_releases/foo")).To(BeADirectory())
+			})
+
+			It("returns an error when nothing matches", func() {
+				request.Params.ReleaseTarball = filepath.Join(tarballDir, "other-release-*.tgz")
+				newCommand()
+
+				_, err := command.ReleaseTarball()
+				Expect(err).To(MatchError(fmt.Sprintf("no release tarball matches %q", request.Params.ReleaseTarball)))
+			})
+
+			It("returns an error when more than one tarball matches", func() {
+				err := ioutil.WriteFile(filepath.Join(tarballDir, "some-release-1.2.4.tgz"), []byte{}, 0600)
+				Expect(err).NotTo(HaveOccurred())
+
+				request.Params.ReleaseTarball = filepath.Join(tarballDir, "some-release-*.tgz")
+				newCommand()
+
+				_, err = command.ReleaseTarball()
+				Expect(err).To(MatchError(ContainSubstring("is ambiguous")))
+			})
 		})
 	})
 
-	FDescribe("Run", func() {
+	Describe("Run", func() {
+		var compiledRelease []byte
+
 		BeforeEach(func() {
-			boshClient.InfoCall.Returns.DirectorInfo = bosh.DirectorInfo{
+			compiledRelease = testhelpers.Tarball(map[string]string{
+				"release.MF": `---
+name: fake-bosh-release
+version: "45"
+`,
+			})
+
+			directorClient.InfoCall.Returns.DirectorInfo = compiler.DirectorInfo{
 				UUID: "some-director-uuid",
 			}
 			manifestGenerator.GenerateCall.Returns.Manifest = []byte("deployment-manifest")
 			boshClient.ExportReleaseCall.Returns.ResourceID = "some-resource-guid"
-			boshClient.ResourceCall.Returns.Resource = ioutil.NopCloser(strings.NewReader("compiled-release-contents"))
+			boshClient.ResourceCall.Returns.Resource = ioutil.NopCloser(bytes.NewReader(compiledRelease))
 		})
 
 		It("deletes any pre-existing deployments", func() {
@@ -176,16 +474,54 @@ version: 42
 				{Name: "dep1"},
 				{Name: "dep2"},
 			}
-			err := command.Run()
+			_, err := command.Run()
 			Expect(err).NotTo(HaveOccurred())
 			Expect(boshClient.DeploymentsCall.CallCount).To(Equal(1))
 			Expect(len(boshClient.DeleteDeploymentCall.Receives.Name)).To(Equal(3))
 			Expect(boshClient.DeleteDeploymentCall.Receives.Name[0]).To(Equal("dep1"))
 			Expect(boshClient.DeleteDeploymentCall.Receives.Name[1]).To(Equal("dep2"))
+			Expect(boshClient.Calls).To(fakes.HaveCalledInOrder("DeleteDeployment", "DeleteDeployment", "Cleanup", "UploadStemcell"))
+		})
+
+		It("uploads the stemcell and release before deploying", func() {
+			_, err := command.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(boshClient.Calls).To(fakes.HaveCalledInOrder("Cleanup", "UploadStemcell", "UploadRelease", "Deploy", "ExportRelease"))
+		})
+
+		It("uploads the stemcell to the bosh director", func() {
+			_, err := command.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(boshClient.UploadStemcellCall.Receives.Contents).NotTo(BeNil())
+			actualContents, err := ioutil.ReadAll(boshClient.UploadStemcellCall.Receives.Contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			expectedContents, err := ioutil.ReadFile(stemcellTarball)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(actualContents).To(Equal(expectedContents))
 		})
 
-		It("uploads the release to the bosh director", func() {
-			err := command.Run()
+		It("does not upload a stemcell the bosh director already has", func() {
+			boshClient.StemcellCall.Returns.Stemcell = bosh.Stemcell{
+				Name:     "some-stemcell",
+				Versions: []string{"1.2.3"},
+			}
+			directorClient.StemcellsCall.Returns.Stemcells = []compiler.DirectorStemcell{
+				{Name: "some-stemcell", OperatingSystem: "some-stemcell", Version: "1.2.3"},
+			}
+
+			result, err := command.Run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(boshClient.StemcellCall.Receives).To(Equal("some-stemcell"))
+			Expect(boshClient.UploadStemcellCall.CallCount).To(Equal(0))
+			Expect(result.SkippedUploads).To(Equal([]string{"stemcell some-stemcell 1.2.3"}))
+		})
+
+		It("uploads the created release to the bosh director", func() {
+			_, err := command.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(boshClient.UploadReleaseCall.Receives.Contents).NotTo(BeNil())
@@ -199,7 +535,7 @@ version: 42
 		})
 
 		It("generates a deployment manifest", func() {
-			err := command.Run()
+			_, err := command.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(manifestGenerator.GenerateCall.Receives.DirectorUUID).To(Equal("some-director-uuid"))
@@ -209,14 +545,14 @@ version: 42
 		})
 
 		It("deploys the manifest", func() {
-			err := command.Run()
+			_, err := command.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(boshClient.DeployCall.Receives.Manifest).To(Equal([]byte("deployment-manifest")))
 		})
 
 		It("exports the release", func() {
-			err := command.Run()
+			_, err := command.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(boshClient.ExportReleaseCall.Receives.DeploymentName).To(Equal("compile-release-some-guid"))
@@ -226,13 +562,196 @@ version: 42
 			Expect(boshClient.ExportReleaseCall.Receives.StemcellVersion).To(Equal("1.2.3"))
 		})
 
+		It("writes the compiled release to the output directory", func() {
+			result, err := command.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.CompiledReleasePath).To(Equal(filepath.Join(outputDirPath, "fake-bosh-release-45.0.0-1.2.3.tgz")))
+			compiledReleaseContents, err := ioutil.ReadFile(result.CompiledReleasePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(compiledReleaseContents).To(Equal(compiledRelease))
+			Expect(filepath.Join(outputDirPath, "report.json")).To(BeAnExistingFile())
+			Expect(filepath.Join(outputDirPath, "fake-bosh-release-45.0.0-1.2.3.cdx.json")).To(BeAnExistingFile())
+		})
+
 		It("deletes the deployment", func() {
-			err := command.Run()
+			_, err := command.Run()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(len(boshClient.DeleteDeploymentCall.Receives.Name)).To(Equal(1))
 			Expect(boshClient.DeleteDeploymentCall.Receives.Name[0]).To(Equal("compile-release-some-guid"))
 		})
+
+		It("logs through the application logger", func() {
+			_, err := command.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(logger.Lines[0]).To(Equal("creating release\n"))
+			Expect(logger.Lines).To(ContainElement("cleaning up\n"))
+		})
+
+		Context("when the stemcell and release are given by url", func() {
+			var server *httptest.Server
+
+			BeforeEach(func() {
+				err := createReleaseTarball(filepath.Join(stemcellDirPath, "some-release-1.2.3.tgz"), bytes.NewBuffer([]byte(`---
+name: some-release
+version: 1.2.3
+`)))
+				Expect(err).NotTo(HaveOccurred())
+
+				boshClient.ResourceCall.Returns.Resource = ioutil.NopCloser(bytes.NewReader(testhelpers.Tarball(map[string]string{
+					"release.MF": "---\nname: some-release\nversion: 1.2.3\n",
+				})))
+
+				server = httptest.NewServer(http.FileServer(http.Dir(stemcellDirPath)))
+
+				request.Params.ReleaseDir = ""
+				request.Params.ReleaseURL = server.URL + "/some-release-1.2.3.tgz"
+				request.Params.ReleaseSHA1 = "some-release-sha1"
+				request.Params.StemcellDir = ""
+				request.Params.StemcellURL = server.URL + "/some-stemcell-1.2.3.tgz"
+				request.Params.StemcellSHA1 = "some-stemcell-sha1"
+				newCommand()
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("has the bosh director fetch them without creating a release", func() {
+				_, err := command.Run()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(boshClient.UploadStemcellCall.CallCount).To(Equal(0))
+				Expect(boshClient.UploadStemcellURLCall.Receives.URL).To(Equal(server.URL + "/some-stemcell-1.2.3.tgz"))
+				Expect(boshClient.UploadStemcellURLCall.Receives.SHA1).To(Equal("some-stemcell-sha1"))
+				Expect(boshClient.UploadReleaseURLCall.Receives.URL).To(Equal(server.URL + "/some-release-1.2.3.tgz"))
+				Expect(boshClient.UploadReleaseURLCall.Receives.SHA1).To(Equal("some-release-sha1"))
+				Expect(filepath.Join(releaseDirPath, "dev_releases/foo")).To(BeADirectory())
+			})
+		})
+
+		It("reports the commit hash of the release", func() {
+			result, err := command.Run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.CommitHash).To(Equal("non-git"))
+		})
+
+		Context("failure cases", func() {
+			Context("when the release cannot be created", func() {
+				It("returns an error", func() {
+					request.Params.ReleaseVersion = ""
+					newCommand()
+
+					_, err := command.Run()
+					Expect(err).To(MatchError("one of release_version, release_version_file or release_version_from must be given"))
+				})
+			})
+
+			Context("when there is no stemcell tarball", func() {
+				It("returns an error", func() {
+					err := os.Remove(stemcellTarball)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = command.Run()
+					Expect(err).To(MatchError(fmt.Sprintf("no stemcell tarball matches %q", filepath.Join(stemcellDirPath, "*.tgz"))))
+				})
+			})
+
+			Context("when the application fails", func() {
+				It("returns an error", func() {
+					boshClient.DeployCall.Returns.Error = errors.New("failed to deploy manifest")
+
+					_, err := command.Run()
+					Expect(err).To(MatchError("failed to deploy manifest"))
+				})
+			})
+		})
 	})
 
+	Describe("log_format", func() {
+		It("logs free-form lines by default", func() {
+			command = out.NewOutCommand(request)
+			Expect(command.Application.Logger).To(BeAssignableToTypeOf(&log.Logger{}))
+			Expect(command.Application.EventLogger).To(BeNil())
+		})
+
+		It("logs lines and step events as json when set to json", func() {
+			request.Source.LogFormat = "json"
+
+			command = out.NewOutCommand(request)
+			Expect(command.Application.Logger).To(BeAssignableToTypeOf(&compiler.JSONLogger{}))
+			Expect(command.Application.EventLogger).To(BeIdenticalTo(command.Application.Logger))
+		})
+
+		It("returns an error when it is unknown", func() {
+			request.Source.LogFormat = "xml"
+			newCommand()
+
+			_, err := command.Run()
+			Expect(err).To(MatchError(`unknown log_format "xml", expected text or json`))
+		})
+	})
+
+	Describe("Response", func() {
+		It("emits the compiled release as the version with its commit hash", func() {
+			response := out.Response(compiler.Result{
+				CompiledReleasePath: "/some/output/some-release-42.0.0-1.2.3.tgz",
+				CommitHash:          "abc1234",
+				SkippedUploads:      []string{"stemcell some-stemcell 1.2.3"},
+				RuntimeConfigAddons: []string{"default/some-agent"},
+			})
+
+			Expect(response).To(Equal(out.OutResponse{
+				Version: out.Version{CompiledRelease: "some-release-42.0.0-1.2.3.tgz"},
+				Metadata: []out.MetadataField{
+					{Name: "commit_hash", Value: "abc1234"},
+					{Name: "skipped_upload", Value: "stemcell some-stemcell 1.2.3"},
+					{Name: "runtime_config_addon", Value: "default/some-agent"},
+				},
+			}))
+		})
+
+		It("emits the sbom of the compiled release", func() {
+			response := out.Response(compiler.Result{
+				CompiledReleasePath: "/some/output/some-release-42.0.0-1.2.3.tgz",
+				SBOMPath:            "/some/output/some-release-42.0.0-1.2.3.cdx.json",
+				CommitHash:          "abc1234",
+			})
+
+			Expect(response.Metadata).To(Equal([]out.MetadataField{
+				{Name: "commit_hash", Value: "abc1234"},
+				{Name: "sbom", Value: "some-release-42.0.0-1.2.3.cdx.json"},
+			}))
+		})
+
+		It("emits the signature of a signed compiled release", func() {
+			response := out.Response(compiler.Result{
+				CompiledReleasePath: "/some/output/some-release-42.0.0-1.2.3.tgz",
+				SignaturePath:       "/some/output/some-release-42.0.0-1.2.3.tgz.sig",
+				CommitHash:          "abc1234",
+			})
+
+			Expect(response.Metadata).To(Equal([]out.MetadataField{
+				{Name: "commit_hash", Value: "abc1234"},
+				{Name: "signature", Value: "some-release-42.0.0-1.2.3.tgz.sig"},
+			}))
+		})
+
+		It("emits the planned actions of a dry run without a version", func() {
+			response := out.Response(compiler.Result{
+				CommitHash: "abc1234",
+				Plan:       []string{"delete deployment dep1", "clean up the director"},
+			})
+
+			Expect(response).To(Equal(out.OutResponse{
+				Metadata: []out.MetadataField{
+					{Name: "commit_hash", Value: "abc1234"},
+					{Name: "planned_action", Value: "delete deployment dep1"},
+					{Name: "planned_action", Value: "clean up the director"},
+				},
+			}))
+		})
+	})
 })
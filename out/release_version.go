@@ -0,0 +1,104 @@
+package out
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+)
+
+// releaseVersion resolves the version to build the release at, from
+// release_version, release_version_file, or release_version_from "git" (the
+// latest tag of the release repository) or "auto" (one patch version past the
+// latest release compiled into compiled_releases_dir).
+func (o *OutCommand) releaseVersion(releaseName string) (string, error) {
+	var (
+		version string
+		err     error
+	)
+
+	switch {
+	case o.version != "":
+		version = o.version
+	case o.versionFile != "":
+		version, err = o.versionFromFile()
+	case o.versionFrom == "git":
+		version, err = o.versionFromGit()
+	case o.versionFrom == "auto":
+		version, err = o.versionFromCompiledReleases(releaseName)
+	case o.versionFrom != "":
+		return "", fmt.Errorf("unknown release_version_from %q, expected git or auto", o.versionFrom)
+	default:
+		return "", fmt.Errorf("one of release_version, release_version_file or release_version_from must be given")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = compiler.ParseSemver(version)
+	if err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
+func (o *OutCommand) versionFromFile() (string, error) {
+	content, err := ioutil.ReadFile(o.versionFile)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+func (o *OutCommand) versionFromGit() (string, error) {
+	describe := exec.Command("git", "describe", "--tags", "--abbrev=0")
+	describe.Dir = o.releaseDir
+	output, err := describe.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("could not describe the release repository: %s", strings.TrimSpace(string(output)))
+	}
+
+	return strings.TrimPrefix(strings.TrimSpace(string(output)), "v"), nil
+}
+
+func (o *OutCommand) versionFromCompiledReleases(releaseName string) (string, error) {
+	files, err := ioutil.ReadDir(o.compiledReleasesDir)
+	if err != nil {
+		return "", err
+	}
+
+	compiledRegex := regexp.MustCompile(fmt.Sprintf(`^%s-(\d+\.\d+\.\d+)-.+\.tgz$`, regexp.QuoteMeta(releaseName)))
+
+	var (
+		latest compiler.Semver
+		found  bool
+	)
+	for _, file := range files {
+		matches := compiledRegex.FindStringSubmatch(file.Name())
+		if matches == nil {
+			continue
+		}
+
+		semver, err := compiler.ParseSemver(matches[1])
+		if err != nil {
+			continue
+		}
+
+		if !found || latest.LessThan(semver) {
+			latest = semver
+			found = true
+		}
+	}
+
+	if !found {
+		return "1", nil
+	}
+
+	latest.Patch++
+	return latest.String(), nil
+}
@@ -0,0 +1,392 @@
+package precompile
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"github.com/pivotal-cf-experimental/bosh-test/bosh"
+	"gopkg.in/yaml.v2"
+)
+
+const usage = `usage: precompile compile --release RELEASE --stemcell STEMCELL --director URL [--output DIR] [options]
+       precompile diff [--format text|json] FROM_TARBALL TO_TARBALL
+       precompile verify --public-key PEM [--public-key PEM ...] TARBALL
+
+RELEASE and STEMCELL are tarball paths or http(s) urls. The director and its
+credentials are read from flags, then the BOSH_ENVIRONMENT, BOSH_CLIENT and
+BOSH_CLIENT_SECRET environment variables, then the config file given by
+--config or PRECOMPILE_CONFIG. --signing-key signs the compiled release with
+the PEM encoded ed25519 private key in the given file.
+
+diff compares the jobs, packages and stemcell of two source or compiled
+release tarballs.
+
+verify checks TARBALL.sig, as written by --signing-key, against the PEM encoded
+ed25519 public keys in the given files and succeeds when any of them verifies
+it.`
+
+// CLI compiles releases outside of Concourse with the same Application the
+// out command uses.
+type CLI struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Getenv func(key string) string
+}
+
+// Config is the config file of the CLI. It holds the director credentials and
+// the placement of the compile deployment.
+type Config struct {
+	Director           string   `yaml:"director"`
+	Username           string   `yaml:"username"`
+	Password           string   `yaml:"password"`
+	Insecure           bool     `yaml:"insecure"`
+	VMType             string   `yaml:"vm_type"`
+	Network            string   `yaml:"network"`
+	AZs                []string `yaml:"azs"`
+	CompilationWorkers int      `yaml:"compilation_workers"`
+}
+
+type compileOptions struct {
+	Config
+	release                    string
+	releaseSHA1                string
+	releaseManifest            string
+	stemcell                   string
+	stemcellSHA1               string
+	stemcellManifest           string
+	output                     string
+	manifest                   string
+	opsFiles                   stringSlice
+	forceUpload                bool
+	allowDirty                 bool
+	excludeRuntimeConfigAddons bool
+	dryRun                     bool
+	logFormat                  string
+	signingKey                 string
+}
+
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func (c CLI) Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "compile":
+		return c.compile(args[1:])
+	case "diff":
+		return c.diff(args[1:])
+	case "verify":
+		return c.verify(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+func (c CLI) compile(args []string) error {
+	options, err := c.parseCompile(args)
+	if err != nil {
+		return err
+	}
+
+	result, err := options.application(c.Stderr).Run()
+	if err != nil {
+		return err
+	}
+
+	if options.dryRun {
+		for _, action := range result.Plan {
+			fmt.Fprintln(c.Stdout, action)
+		}
+
+		return nil
+	}
+
+	fmt.Fprintln(c.Stdout, result.CompiledReleasePath)
+
+	return nil
+}
+
+func (c CLI) diff(args []string) error {
+	var format string
+
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(c.Stderr)
+	flags.StringVar(&format, "format", "text", "format of the diff, text or json")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown --format %q, expected text or json", format)
+	}
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("diff takes two release tarballs\n%s", usage)
+	}
+
+	from, err := compiler.ReadReleaseManifest(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	to, err := compiler.ReadReleaseManifest(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	diff := compiler.DiffReleases(from, to)
+
+	if format == "json" {
+		content, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(c.Stdout, string(content))
+		return nil
+	}
+
+	fmt.Fprint(c.Stdout, diff.Text())
+
+	return nil
+}
+
+func (c CLI) verify(args []string) error {
+	var publicKeyPaths stringSlice
+
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	flags.SetOutput(c.Stderr)
+	flags.Var(&publicKeyPaths, "public-key", "PEM file with an ed25519 public key, may be given more than once")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("verify takes one release tarball\n%s", usage)
+	}
+
+	if len(publicKeyPaths) == 0 {
+		return fmt.Errorf("--public-key is required\n%s", usage)
+	}
+
+	var publicKeys []string
+	for _, path := range publicKeyPaths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read --public-key: %s", err)
+		}
+
+		publicKeys = append(publicKeys, string(content))
+	}
+
+	tarballPath := flags.Arg(0)
+	err = compiler.VerifyTarballSignature(tarballPath, tarballPath+".sig", publicKeys)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Stdout, "%s: signature verified\n", tarballPath)
+
+	return nil
+}
+
+func (c CLI) parseCompile(args []string) (compileOptions, error) {
+	var (
+		options        compileOptions
+		configPath     string
+		signingKeyPath string
+		azs            stringSlice
+	)
+
+	flags := flag.NewFlagSet("compile", flag.ContinueOnError)
+	flags.SetOutput(c.Stderr)
+	flags.StringVar(&options.release, "release", "", "release tarball path or url")
+	flags.StringVar(&options.releaseSHA1, "release-sha1", "", "sha1 of the release at --release url")
+	flags.StringVar(&options.releaseManifest, "release-manifest", "", "release.MF of the release at --release url")
+	flags.StringVar(&options.stemcell, "stemcell", "", "stemcell tarball path or url")
+	flags.StringVar(&options.stemcellSHA1, "stemcell-sha1", "", "sha1 of the stemcell at --stemcell url")
+	flags.StringVar(&options.stemcellManifest, "stemcell-manifest", "", "stemcell.MF of the stemcell at --stemcell url")
+	flags.StringVar(&options.output, "output", ".", "directory to write the compiled release to")
+	flags.StringVar(&options.Director, "director", "", "director url")
+	flags.StringVar(&options.Username, "username", "", "director username")
+	flags.StringVar(&options.Password, "password", "", "director password")
+	flags.BoolVar(&options.Insecure, "insecure", false, "skip verifying the director certificate")
+	flags.StringVar(&configPath, "config", "", "config file with the director and its credentials")
+	flags.StringVar(&options.VMType, "vm-type", "", "vm type of the compile deployment")
+	flags.StringVar(&options.Network, "network", "", "network of the compile deployment")
+	flags.Var(&azs, "az", "az of the compile deployment, may be repeated")
+	flags.IntVar(&options.CompilationWorkers, "compilation-workers", 0, "compilation workers the cloud config must provide")
+	flags.StringVar(&options.manifest, "manifest", "", "manifest template of the compile deployment")
+	flags.Var(&options.opsFiles, "ops-file", "ops file applied to the compile deployment manifest, may be repeated")
+	flags.BoolVar(&options.forceUpload, "force-upload", false, "upload the release and stemcell even if the director has them")
+	flags.BoolVar(&options.allowDirty, "allow-dirty", false, "compile a release built with uncommitted changes")
+	flags.BoolVar(&options.excludeRuntimeConfigAddons, "exclude-runtime-config-addons", false, "exclude the compile deployment from runtime config addons by changing the runtime configs while it compiles; they are not restored if precompile is killed")
+	flags.StringVar(&options.logFormat, "log-format", "text", "format of the log written to stderr, text or json")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "print the changes to the director that compiling would make, without making them")
+	flags.StringVar(&signingKeyPath, "signing-key", "", "PEM file with the ed25519 private key to sign the compiled release with")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return compileOptions{}, err
+	}
+	options.AZs = azs
+
+	if flags.NArg() > 0 {
+		return compileOptions{}, fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+
+	if options.logFormat != "text" && options.logFormat != "json" {
+		return compileOptions{}, fmt.Errorf("unknown --log-format %q, expected text or json", options.logFormat)
+	}
+
+	if signingKeyPath != "" {
+		content, err := ioutil.ReadFile(signingKeyPath)
+		if err != nil {
+			return compileOptions{}, fmt.Errorf("could not read --signing-key: %s", err)
+		}
+
+		options.signingKey = string(content)
+	}
+
+	if configPath == "" {
+		configPath = c.Getenv("PRECOMPILE_CONFIG")
+	}
+
+	var config Config
+	if configPath != "" {
+		content, err := ioutil.ReadFile(configPath)
+		if err != nil {
+			return compileOptions{}, err
+		}
+
+		err = yaml.UnmarshalStrict(content, &config)
+		if err != nil {
+			return compileOptions{}, fmt.Errorf("could not parse config file %s: %s", configPath, err)
+		}
+	}
+
+	options.Director = firstOf(options.Director, c.Getenv("BOSH_ENVIRONMENT"), config.Director)
+	options.Username = firstOf(options.Username, c.Getenv("BOSH_CLIENT"), config.Username)
+	options.Password = firstOf(options.Password, c.Getenv("BOSH_CLIENT_SECRET"), config.Password)
+	options.Insecure = options.Insecure || config.Insecure
+	options.VMType = firstOf(options.VMType, config.VMType)
+	options.Network = firstOf(options.Network, config.Network)
+
+	if len(options.AZs) == 0 {
+		options.AZs = config.AZs
+	}
+
+	if options.CompilationWorkers == 0 {
+		options.CompilationWorkers = config.CompilationWorkers
+	}
+
+	required := []struct{ name, value string }{
+		{"--release", options.release},
+		{"--stemcell", options.stemcell},
+		{"--director", options.Director},
+	}
+	for _, option := range required {
+		if option.value == "" {
+			return compileOptions{}, fmt.Errorf("%s is required\n%s", option.name, usage)
+		}
+	}
+
+	if !strings.Contains(options.Director, "://") {
+		options.Director = "https://" + options.Director
+	}
+
+	return options, nil
+}
+
+func (o compileOptions) application(stderr io.Writer) compiler.Application {
+	boshConfig := bosh.Config{
+		URL:              o.Director,
+		Username:         o.Username,
+		Password:         o.Password,
+		AllowInsecureSSL: o.Insecure,
+	}
+
+	app := compiler.Application{
+		ReleaseSHA1:          o.releaseSHA1,
+		ReleaseManifestPath:  o.releaseManifest,
+		StemcellSHA1:         o.stemcellSHA1,
+		StemcellManifestPath: o.stemcellManifest,
+		OutputDirectory:      o.output,
+		ForceUpload:          o.forceUpload,
+		AllowDirty:           o.allowDirty,
+		Deployment: compiler.DeploymentSettings{
+			VMType:             o.VMType,
+			Network:            o.Network,
+			AZs:                o.AZs,
+			CompilationWorkers: o.CompilationWorkers,
+		},
+		ManifestTemplatePath:       o.manifest,
+		OpsFilePaths:               o.opsFiles,
+		ExcludeRuntimeConfigAddons: o.excludeRuntimeConfigAddons,
+		DryRun:                     o.dryRun,
+		SigningKey:                 o.signingKey,
+		BOSHClient:                 compiler.NewBOSHClient(boshConfig),
+		DirectorClient:             compiler.NewDirectorClient(boshConfig),
+		ManifestGenerator:          compiler.NewManifestGenerator(),
+		GUIDGenerator:              compiler.NewGUIDGenerator(rand.Reader).Generate,
+		Logger:                     log.New(stderr, "", 0),
+	}
+
+	if o.logFormat == "json" {
+		logger := compiler.NewJSONLogger(stderr, time.Now)
+		app.Logger = logger
+		app.EventLogger = logger
+	}
+
+	if isURL(o.release) {
+		app.ReleaseURL = o.release
+	} else {
+		app.ReleaseTarballPath = o.release
+	}
+
+	if isURL(o.stemcell) {
+		app.StemcellURL = o.stemcell
+	} else {
+		app.StemcellTarballPath = o.stemcell
+	}
+
+	return app
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,402 @@
+package precompile_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler"
+	"github.com/aditya87/precompiled-bosh-release-resource/compiler/fakes"
+	"github.com/aditya87/precompiled-bosh-release-resource/internal/testhelpers"
+	"github.com/aditya87/precompiled-bosh-release-resource/precompile"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CLI", func() {
+	var (
+		director     *fakes.Director
+		cli          precompile.CLI
+		stdout       *bytes.Buffer
+		stderr       *bytes.Buffer
+		env          map[string]string
+		tempDir      string
+		outputDir    string
+		releasePath  string
+		stemcellPath string
+		compiled     []byte
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		outputDir = filepath.Join(tempDir, "output")
+		err = os.Mkdir(outputDir, os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		releasePath = filepath.Join(tempDir, "some-release-42.tgz")
+		err = createReleaseTarball(releasePath, bytes.NewBuffer([]byte(`---
+name: some-release
+version: 42
+packages:
+- name: some-package
+  version: some-fingerprint
+  fingerprint: some-fingerprint
+  sha1: some-source-sha1
+`)))
+		Expect(err).NotTo(HaveOccurred())
+
+		stemcellPath = filepath.Join(tempDir, "some-stemcell-1.2.3.tgz")
+		err = createStemcellTarball(stemcellPath, bytes.NewBuffer([]byte(`---
+operating_system: some-stemcell
+version: 1.2.3
+`)))
+		Expect(err).NotTo(HaveOccurred())
+
+		director = fakes.NewDirector("some-user", "some-password")
+		compiled = testhelpers.Tarball(map[string]string{
+			"release.MF": `---
+name: some-release
+version: "42"
+compiled_packages:
+- name: some-package
+  version: some-fingerprint
+  fingerprint: some-fingerprint
+  sha1: some-sha1
+  stemcell: some-stemcell/1.2.3
+`,
+			"compiled_packages/some-package.tgz": "some-compiled-package",
+		})
+		director.ExportedRelease = compiled
+
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+		env = map[string]string{}
+		cli = precompile.CLI{
+			Stdout: stdout,
+			Stderr: stderr,
+			Getenv: func(key string) string { return env[key] },
+		}
+	})
+
+	AfterEach(func() {
+		director.Close()
+
+		err := os.RemoveAll(tempDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	compileArgs := func(extra ...string) []string {
+		return append([]string{
+			"compile",
+			"--release", releasePath,
+			"--stemcell", stemcellPath,
+			"--output", outputDir,
+		}, extra...)
+	}
+
+	Describe("compile", func() {
+		It("compiles the release on the director and prints its path", func() {
+			err := cli.Run(compileArgs("--director", director.URL(), "--username", "some-user", "--password", "some-password"))
+			Expect(err).NotTo(HaveOccurred())
+
+			compiledReleasePath := strings.TrimSpace(stdout.String())
+			Expect(compiledReleasePath).To(Equal(filepath.Join(outputDir, "some-release-42.0.0-1.2.3.tgz")))
+
+			contents, err := ioutil.ReadFile(compiledReleasePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contents).To(Equal(compiled))
+			Expect(filepath.Join(outputDir, "report.json")).To(BeAnExistingFile())
+
+			Expect(stderr.String()).To(ContainSubstring("uploading release some-release 42"))
+			Expect(director.Releases()).To(HaveKey("some-release"))
+		})
+
+		It("prints the changes it would make in a dry run without making them", func() {
+			director.AddDeployment("some-old-deployment", []byte("name: some-old-deployment"))
+
+			err := cli.Run(compileArgs("--director", director.URL(), "--username", "some-user", "--password", "some-password", "--dry-run"))
+			Expect(err).NotTo(HaveOccurred())
+
+			plan := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			Expect(plan[0]).To(Equal("delete deployment some-old-deployment"))
+			Expect(plan).To(ContainElement("upload release some-release 42"))
+
+			Expect(director.Deployments()).To(HaveKey("some-old-deployment"))
+			Expect(director.Releases()).To(BeEmpty())
+			Expect(filepath.Join(outputDir, "some-release-42.0.0-1.2.3.tgz")).NotTo(BeAnExistingFile())
+		})
+
+		It("logs json lines with step events when the log format is json", func() {
+			err := cli.Run(compileArgs("--director", director.URL(), "--username", "some-user", "--password", "some-password", "--log-format", "json"))
+			Expect(err).NotTo(HaveOccurred())
+
+			lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+			var last map[string]interface{}
+			for _, line := range lines {
+				last = map[string]interface{}{}
+				Expect(json.Unmarshal([]byte(line), &last)).To(Succeed())
+			}
+
+			Expect(last["step"]).To(Equal("compile"))
+			Expect(last["release"]).To(Equal("some-release/42"))
+			Expect(last["stemcell"]).To(Equal("some-stemcell/1.2.3"))
+			Expect(last["outcome"]).To(Equal("succeeded"))
+			Expect(last).To(HaveKey("duration"))
+		})
+
+		It("signs the compiled release with the signing key", func() {
+			privateKey, publicKey := testhelpers.GenerateKeys()
+
+			signingKeyPath := filepath.Join(tempDir, "signing-key.pem")
+			err := ioutil.WriteFile(signingKeyPath, []byte(privateKey), 0600)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = cli.Run(compileArgs("--director", director.URL(), "--username", "some-user", "--password", "some-password", "--signing-key", signingKeyPath))
+			Expect(err).NotTo(HaveOccurred())
+
+			compiledReleasePath := strings.TrimSpace(stdout.String())
+			err = compiler.VerifyTarballSignature(compiledReleasePath, compiledReleasePath+".sig", []string{publicKey})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns an error when the signing key cannot be read", func() {
+			err := cli.Run(compileArgs("--director", director.URL(), "--signing-key", filepath.Join(tempDir, "missing.pem")))
+			Expect(err).To(MatchError(ContainSubstring("could not read --signing-key")))
+		})
+
+		It("returns an error for an unknown log format", func() {
+			err := cli.Run(compileArgs("--director", director.URL(), "--log-format", "xml"))
+			Expect(err).To(MatchError(`unknown --log-format "xml", expected text or json`))
+		})
+
+		It("reads the director and credentials from the environment", func() {
+			env["BOSH_ENVIRONMENT"] = director.URL()
+			env["BOSH_CLIENT"] = "some-user"
+			env["BOSH_CLIENT_SECRET"] = "some-password"
+
+			err := cli.Run(compileArgs())
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when a config file is given", func() {
+			var configPath string
+
+			BeforeEach(func() {
+				configPath = filepath.Join(tempDir, "config.yml")
+				err := ioutil.WriteFile(configPath, []byte(`---
+director: `+director.URL()+`
+username: some-user
+password: some-password
+`), os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("reads the director and credentials from it", func() {
+				err := cli.Run(compileArgs("--config", configPath))
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("reads it from the path in PRECOMPILE_CONFIG", func() {
+				env["PRECOMPILE_CONFIG"] = configPath
+
+				err := cli.Run(compileArgs())
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("prefers the environment to it", func() {
+				env["BOSH_CLIENT_SECRET"] = "some-wrong-password"
+
+				err := cli.Run(compileArgs("--config", configPath))
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("prefers flags to the environment", func() {
+				env["BOSH_CLIENT_SECRET"] = "some-wrong-password"
+
+				err := cli.Run(compileArgs("--config", configPath, "--password", "some-password"))
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns an error when it cannot be parsed", func() {
+				err := ioutil.WriteFile(configPath, []byte("some-unknown-key: value"), os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = cli.Run(compileArgs("--config", configPath))
+				Expect(err).To(MatchError(ContainSubstring("could not parse config file")))
+			})
+		})
+
+		It("returns an error when the credentials are wrong", func() {
+			err := cli.Run(compileArgs("--director", director.URL(), "--username", "some-user", "--password", "some-wrong-password"))
+			Expect(err).To(HaveOccurred())
+			Expect(stdout.String()).To(BeEmpty())
+		})
+
+		It("returns an error when the release is not given", func() {
+			err := cli.Run([]string{"compile", "--stemcell", stemcellPath, "--director", director.URL()})
+			Expect(err).To(MatchError(ContainSubstring("--release is required")))
+		})
+
+		It("returns an error when the stemcell is not given", func() {
+			err := cli.Run([]string{"compile", "--release", releasePath, "--director", director.URL()})
+			Expect(err).To(MatchError(ContainSubstring("--stemcell is required")))
+		})
+
+		It("returns an error when the director is not given", func() {
+			err := cli.Run(compileArgs())
+			Expect(err).To(MatchError(ContainSubstring("--director is required")))
+		})
+
+		It("returns an error for an unknown flag", func() {
+			err := cli.Run(compileArgs("--some-flag"))
+			Expect(err).To(MatchError(ContainSubstring("some-flag")))
+		})
+	})
+
+	Describe("diff", func() {
+		var fromPath, toPath string
+
+		BeforeEach(func() {
+			fromPath = filepath.Join(tempDir, "from.tgz")
+			err := ioutil.WriteFile(fromPath, compiled, os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			toPath = filepath.Join(tempDir, "to.tgz")
+			err = ioutil.WriteFile(toPath, testhelpers.Tarball(map[string]string{
+				"release.MF": `---
+name: some-release
+version: "43"
+compiled_packages:
+- name: some-package
+  version: other-fingerprint
+  fingerprint: other-fingerprint
+  sha1: other-sha1
+  stemcell: some-stemcell/1.2.3
+`,
+			}), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("prints the differences between two release tarballs", func() {
+			err := cli.Run([]string{"diff", fromPath, toPath})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stdout.String()).To(Equal(`some-release/42 -> some-release/43
+package some-package changed
+  version: some-fingerprint -> other-fingerprint
+  fingerprint: some-fingerprint -> other-fingerprint
+  sha1: some-sha1 -> other-sha1
+`))
+		})
+
+		It("prints the differences as json", func() {
+			err := cli.Run([]string{"diff", "--format", "json", fromPath, toPath})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stdout.String()).To(MatchJSON(`{
+				"from": "some-release/42",
+				"to": "some-release/43",
+				"jobs": [],
+				"packages": [
+					{
+						"name": "some-package",
+						"change": "changed",
+						"version": {"from": "some-fingerprint", "to": "other-fingerprint"},
+						"fingerprint": {"from": "some-fingerprint", "to": "other-fingerprint"},
+						"sha1": {"from": "some-sha1", "to": "other-sha1"}
+					}
+				]
+			}`))
+		})
+
+		It("returns an error for an unknown format", func() {
+			err := cli.Run([]string{"diff", "--format", "xml", fromPath, toPath})
+			Expect(err).To(MatchError(`unknown --format "xml", expected text or json`))
+		})
+
+		It("returns an error unless given two tarballs", func() {
+			err := cli.Run([]string{"diff", fromPath})
+			Expect(err).To(MatchError(ContainSubstring("diff takes two release tarballs")))
+		})
+
+		It("returns an error when a tarball cannot be read", func() {
+			err := cli.Run([]string{"diff", fromPath, filepath.Join(tempDir, "missing.tgz")})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("verify", func() {
+		var (
+			tarballPath        string
+			publicKeyPath      string
+			otherPublicKeyPath string
+		)
+
+		writeKeys := func(name string) (string, string) {
+			privateKey, publicKey := testhelpers.GenerateKeys()
+
+			publicKeyPath := filepath.Join(tempDir, name+".pem")
+			err := ioutil.WriteFile(publicKeyPath, []byte(publicKey), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			return privateKey, publicKeyPath
+		}
+
+		BeforeEach(func() {
+			tarballPath = filepath.Join(tempDir, "some-release-42-some-stemcell-1.2.3.tgz")
+			err := ioutil.WriteFile(tarballPath, compiled, os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			var privateKey string
+			privateKey, publicKeyPath = writeKeys("public-key")
+			_, otherPublicKeyPath = writeKeys("other-public-key")
+
+			_, err = compiler.SignTarball(tarballPath, privateKey)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("verifies the signature of the tarball with any of the public keys", func() {
+			err := cli.Run([]string{"verify", "--public-key", otherPublicKeyPath, "--public-key", publicKeyPath, tarballPath})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stdout.String()).To(Equal(tarballPath + ": signature verified\n"))
+		})
+
+		It("returns an error when none of the public keys verifies the signature", func() {
+			err := cli.Run([]string{"verify", "--public-key", otherPublicKeyPath, tarballPath})
+			Expect(err).To(MatchError(ContainSubstring("does not match")))
+		})
+
+		It("returns an error when no public key is given", func() {
+			err := cli.Run([]string{"verify", tarballPath})
+			Expect(err).To(MatchError(ContainSubstring("--public-key is required")))
+		})
+
+		It("returns an error when a public key cannot be read", func() {
+			err := cli.Run([]string{"verify", "--public-key", filepath.Join(tempDir, "missing.pem"), tarballPath})
+			Expect(err).To(MatchError(ContainSubstring("could not read --public-key")))
+		})
+
+		It("returns an error unless given one tarball", func() {
+			err := cli.Run([]string{"verify", "--public-key", publicKeyPath})
+			Expect(err).To(MatchError(ContainSubstring("verify takes one release tarball")))
+		})
+	})
+
+	It("returns the usage when no command is given", func() {
+		err := cli.Run([]string{})
+		Expect(err).To(MatchError(ContainSubstring("usage: precompile compile")))
+	})
+
+	It("returns an error for an unknown command", func() {
+		err := cli.Run([]string{"some-command"})
+		Expect(err).To(MatchError(ContainSubstring(`unknown command "some-command"`)))
+	})
+})